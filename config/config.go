@@ -0,0 +1,453 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Log formats
+const (
+	LogFormatText        = "text"
+	LogFormatJSON        = "json"
+	LogFormatStackdriver = "stackdriver"
+)
+
+// Trace exporters
+const (
+	TraceExporterNone   = "none"
+	TraceExporterStdout = "stdout"
+	TraceExporterOTLP   = "otlp"
+)
+
+// Firewall backends managing rules of host projects
+const (
+	BackendVPCFirewall                = "vpc_firewall"
+	BackendNetworkFirewallPolicy      = "network_firewall_policy"
+	BackendHierarchicalFirewallPolicy = "hierarchical_firewall_policy"
+	BackendMemory                     = "memory"
+)
+
+// Secure tag values, tagValues/<ID>
+var secureTagValueFormat = regexp.MustCompile(`^tagValues/[0-9]+$`)
+
+// Redacted replaces values of secret fields, tagged with redact:"true", in exposed configuration
+const Redacted = "REDACTED"
+
+// Config describe the configuration of the API
+type Config struct {
+	ListenAddress string `yaml:"listen_address" json:"listen_address"`
+	// Disables access logs when false
+	AccessLog bool     `yaml:"access_log" json:"access_log"`
+	Log       Log      `yaml:"log" json:"log"`
+	Timeouts  Timeouts `yaml:"timeouts" json:"timeouts"`
+
+	// Host projects whose rules may be managed. All when empty
+	HostProjects []HostProject `yaml:"host_projects" json:"host_projects"`
+	// Secure tag values, as tagValues/<ID>, of network tags of rules managed on firewall policy backends
+	SecureTags map[string]string `yaml:"secure_tags" json:"secure_tags"`
+	// Issuers of accepted bearer tokens
+	TrustedIssuers []string `yaml:"trusted_issuers" json:"trusted_issuers"`
+	// Emails of users allowed to read this configuration
+	Admins []string `yaml:"admins" json:"admins"`
+
+	ServiceCatalogFile  string `yaml:"service_catalog_file" json:"service_catalog_file"`
+	GuardrailPolicyFile string `yaml:"guardrail_policy_file" json:"guardrail_policy_file"`
+	// Directory where state managed through the API is saved, to survive restarts. Kept in memory only when empty
+	StateDir string `yaml:"state_dir" json:"state_dir"`
+
+	Cache   Cache   `yaml:"cache" json:"cache"`
+	Tracing Tracing `yaml:"tracing" json:"tracing"`
+}
+
+// Log describe logs level and format
+type Log struct {
+	Level  string `yaml:"level" json:"level"`
+	Format string `yaml:"format" json:"format"`
+}
+
+// HostProject describe a host project managed by the API
+type HostProject struct {
+	Project string `yaml:"project" json:"project"`
+	// Networks whose rules may be managed. All when empty
+	Networks []string `yaml:"networks" json:"networks"`
+	// Service account impersonated to manage rules. Default credentials when empty
+	ServiceAccount string `yaml:"service_account" json:"service_account"`
+	// Backend managing rules, VPC firewall rules when empty
+	Backend string `yaml:"backend" json:"backend"`
+	// Network firewall policy name, or hierarchical firewall policy ID, of firewall policy backends
+	FirewallPolicy string `yaml:"firewall_policy" json:"firewall_policy"`
+	// Priority bands, which cannot be changed through the API when set. Managed through the API when empty
+	PriorityBands []PriorityBand `yaml:"priority_bands" json:"priority_bands"`
+}
+
+// PriorityBand describe a priority band of a host project and its applications
+type PriorityBand struct {
+	Name    string `yaml:"name" json:"name"`
+	Base    int64  `yaml:"base" json:"base"`
+	Default bool   `yaml:"default" json:"default"`
+	// Applications of the band, as <SERVICE_PROJECT>/<APPLICATION>
+	Applications []string `yaml:"applications" json:"applications"`
+}
+
+// Timeouts describe HTTP server timeouts
+type Timeouts struct {
+	Read     Duration `yaml:"read" json:"read"`
+	Write    Duration `yaml:"write" json:"write"`
+	Idle     Duration `yaml:"idle" json:"idle"`
+	Shutdown Duration `yaml:"shutdown" json:"shutdown"`
+}
+
+// Cache describe how long results are kept
+type Cache struct {
+	Readiness   Duration `yaml:"readiness" json:"readiness"`
+	Idempotency Duration `yaml:"idempotency" json:"idempotency"`
+	Networks    Duration `yaml:"networks" json:"networks"`
+}
+
+// Tracing describe where spans are exported
+type Tracing struct {
+	Exporter     string            `yaml:"exporter" json:"exporter"`
+	OTLPEndpoint string            `yaml:"otlp_endpoint" json:"otlp_endpoint"`
+	OTLPHeaders  map[string]string `yaml:"otlp_headers" json:"otlp_headers" redact:"true"`
+}
+
+// Default returns the configuration of the API when nothing is set, reading the environment with given getenv.
+// Logs are Stackdriver compliant when runtime is GCP
+// https://cloud.google.com/run/docs/reference/container-contract#env-vars
+func Default(getenv func(string) string) *Config {
+	format := LogFormatText
+	if getenv("K_SERVICE") != "" {
+		format = LogFormatStackdriver
+	}
+
+	return &Config{
+		ListenAddress:  ":8080",
+		AccessLog:      true,
+		Log:            Log{Level: "debug", Format: format},
+		Timeouts:       Timeouts{Read: Duration(15 * time.Second), Write: Duration(15 * time.Second), Idle: Duration(60 * time.Second), Shutdown: Duration(10 * time.Second)},
+		TrustedIssuers: []string{"https://accounts.google.com"},
+		Cache:          Cache{Readiness: Duration(5 * time.Second), Idempotency: Duration(24 * time.Hour), Networks: Duration(time.Minute)},
+		Tracing:        Tracing{Exporter: TraceExporterNone},
+	}
+}
+
+// Load returns the default configuration, overridden by the YAML file given by CONFIG_FILE if any,
+// then by environment variables. Returns an error if the configuration is invalid
+func Load(getenv func(string) string) (*Config, error) {
+	c := Default(getenv)
+
+	if path := getenv("CONFIG_FILE"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = yaml.UnmarshalStrict(data, c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+	}
+
+	c.overrideFromEnv(getenv)
+
+	err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Environment variables override file settings
+func (c *Config) overrideFromEnv(getenv func(string) string) {
+	if port := getenv("PORT"); port != "" {
+		c.ListenAddress = ":" + port
+	}
+	if getenv("CI") != "" {
+		c.AccessLog = false
+	}
+
+	values := map[string]*string{
+		"LOG_LEVEL":                   &c.Log.Level,
+		"LOG_FORMAT":                  &c.Log.Format,
+		"SERVICE_CATALOG_FILE":        &c.ServiceCatalogFile,
+		"GUARDRAIL_POLICY_FILE":       &c.GuardrailPolicyFile,
+		"STATE_DIR":                   &c.StateDir,
+		"TRACE_EXPORTER":              &c.Tracing.Exporter,
+		"OTEL_EXPORTER_OTLP_ENDPOINT": &c.Tracing.OTLPEndpoint,
+	}
+	for name, field := range values {
+		if value := getenv(name); value != "" {
+			*field = value
+		}
+	}
+
+	// Listed host projects keep their file settings, others being left out
+	if value := getenv("HOST_PROJECTS"); value != "" {
+		settings := make(map[string]HostProject)
+		for _, hostProject := range c.HostProjects {
+			settings[hostProject.Project] = hostProject
+		}
+
+		c.HostProjects = nil
+		for _, project := range splitList(value) {
+			hostProject, ok := settings[project]
+			if !ok {
+				hostProject = HostProject{Project: project}
+			}
+			c.HostProjects = append(c.HostProjects, hostProject)
+		}
+	}
+
+	lists := map[string]*[]string{
+		"TRUSTED_ISSUERS": &c.TrustedIssuers,
+		"ADMINS":          &c.Admins,
+	}
+	for name, field := range lists {
+		if value := getenv(name); value != "" {
+			*field = splitList(value)
+		}
+	}
+}
+
+// Validate returns all invalid settings of the configuration, if any
+func (c *Config) Validate() error {
+	var errs []string
+	invalid := func(setting, format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf("%s: %s", setting, fmt.Sprintf(format, args...)))
+	}
+
+	_, _, err := net.SplitHostPort(c.ListenAddress)
+	if err != nil {
+		invalid("listen_address", "expected [host]:port, got [%s]", c.ListenAddress)
+	}
+
+	_, err = logrus.ParseLevel(c.Log.Level)
+	if err != nil {
+		invalid("log.level", "unknown level [%s]", c.Log.Level)
+	}
+
+	switch c.Log.Format {
+	case LogFormatText, LogFormatJSON, LogFormatStackdriver:
+	default:
+		invalid("log.format", "expected %s, %s or %s, got [%s]", LogFormatText, LogFormatJSON, LogFormatStackdriver, c.Log.Format)
+	}
+
+	durations := map[string]Duration{
+		"timeouts.read":     c.Timeouts.Read,
+		"timeouts.write":    c.Timeouts.Write,
+		"timeouts.idle":     c.Timeouts.Idle,
+		"timeouts.shutdown": c.Timeouts.Shutdown,
+		"cache.readiness":   c.Cache.Readiness,
+		"cache.idempotency": c.Cache.Idempotency,
+		"cache.networks":    c.Cache.Networks,
+	}
+	for setting, d := range durations {
+		if d <= 0 {
+			invalid(setting, "must be positive, got [%s]", time.Duration(d))
+		}
+	}
+
+	hostProjects := make(map[string]bool)
+	for i, hostProject := range c.HostProjects {
+		if hostProject.Project == "" {
+			invalid(fmt.Sprintf("host_projects[%d].project", i), "is required")
+		} else if hostProjects[hostProject.Project] {
+			invalid(fmt.Sprintf("host_projects[%d].project", i), "duplicated host project [%s]", hostProject.Project)
+		}
+		hostProjects[hostProject.Project] = true
+
+		if hostProject.ServiceAccount != "" && !strings.HasSuffix(hostProject.ServiceAccount, ".gserviceaccount.com") {
+			invalid(fmt.Sprintf("host_projects[%d].service_account", i), "expected a service account email, got [%s]", hostProject.ServiceAccount)
+		}
+
+		switch hostProject.Backend {
+		case "", BackendVPCFirewall, BackendMemory:
+			if hostProject.FirewallPolicy != "" {
+				invalid(fmt.Sprintf("host_projects[%d].firewall_policy", i), "only supported by firewall policy backends")
+			}
+		case BackendNetworkFirewallPolicy, BackendHierarchicalFirewallPolicy:
+			if hostProject.FirewallPolicy == "" {
+				invalid(fmt.Sprintf("host_projects[%d].firewall_policy", i), "is required by [%s] backend", hostProject.Backend)
+			}
+			// Rules of network firewall policies apply to the networks the policy is associated with, not to one of them
+			if hostProject.Backend == BackendNetworkFirewallPolicy && len(hostProject.Networks) > 0 {
+				invalid(fmt.Sprintf("host_projects[%d].networks", i), "not supported by [%s] backend, whose rules apply to all networks associated with the policy", hostProject.Backend)
+			}
+		default:
+			invalid(fmt.Sprintf("host_projects[%d].backend", i), "expected %s, %s, %s or %s, got [%s]", BackendVPCFirewall, BackendNetworkFirewallPolicy, BackendHierarchicalFirewallPolicy, BackendMemory, hostProject.Backend)
+		}
+
+		for j, network := range hostProject.Networks {
+			if network == "" || strings.Contains(network, "/") {
+				invalid(fmt.Sprintf("host_projects[%d].networks[%d]", i, j), "expected a network name, got [%s]", network)
+			}
+		}
+
+		// Bases and names are checked as on creation through the API, when loading bands
+		applications := make(map[string]bool)
+		for j, band := range hostProject.PriorityBands {
+			for k, application := range band.Applications {
+				parts := strings.Split(application, "/")
+				if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					invalid(fmt.Sprintf("host_projects[%d].priority_bands[%d].applications[%d]", i, j, k), "expected <SERVICE_PROJECT>/<APPLICATION>, got [%s]", application)
+				} else if applications[application] {
+					invalid(fmt.Sprintf("host_projects[%d].priority_bands[%d].applications[%d]", i, j, k), "application [%s] already has a band", application)
+				}
+				applications[application] = true
+			}
+		}
+	}
+
+	for tag, value := range c.SecureTags {
+		if !secureTagValueFormat.MatchString(value) {
+			invalid(fmt.Sprintf("secure_tags.%s", tag), "expected tagValues/<ID>, got [%s]", value)
+		}
+	}
+
+	if len(c.TrustedIssuers) == 0 {
+		invalid("trusted_issuers", "at least one issuer is required")
+	}
+
+	for _, path := range []string{c.ServiceCatalogFile, c.GuardrailPolicyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			invalid("files", "%v", err)
+		}
+	}
+
+	if c.StateDir != "" {
+		info, err := os.Stat(c.StateDir)
+		if err != nil {
+			invalid("state_dir", "%v", err)
+		} else if !info.IsDir() {
+			invalid("state_dir", "[%s] is not a directory", c.StateDir)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case TraceExporterNone, TraceExporterStdout, TraceExporterOTLP:
+	default:
+		invalid("tracing.exporter", "expected %s, %s or %s, got [%s]", TraceExporterNone, TraceExporterStdout, TraceExporterOTLP, c.Tracing.Exporter)
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid configuration:\n- %s", strings.Join(errs, "\n- "))
+	}
+	return nil
+}
+
+// ManagesHostProject returns if rules of the given host project may be managed
+func (c *Config) ManagesHostProject(project string) bool {
+	if len(c.HostProjects) == 0 {
+		return true
+	}
+	_, ok := c.hostProject(project)
+	return ok
+}
+
+// ManagesNetwork returns if rules of the given network of the given host project may be managed
+func (c *Config) ManagesNetwork(project, network string) bool {
+	if len(c.HostProjects) == 0 {
+		return true
+	}
+
+	hostProject, ok := c.hostProject(project)
+	if !ok {
+		return false
+	}
+	if len(hostProject.Networks) == 0 {
+		return true
+	}
+
+	for _, n := range hostProject.Networks {
+		if n == network {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfiguresPriorityBands returns if priority bands of the given host project are set by configuration
+func (c *Config) ConfiguresPriorityBands(project string) bool {
+	hostProject, ok := c.hostProject(project)
+	return ok && len(hostProject.PriorityBands) > 0
+}
+
+func (c *Config) hostProject(project string) (HostProject, bool) {
+	for _, hostProject := range c.HostProjects {
+		if hostProject.Project == project {
+			return hostProject, true
+		}
+	}
+	return HostProject{}, false
+}
+
+// ServiceAccounts returns service accounts impersonated to reach host projects, by host project
+func (c *Config) ServiceAccounts() map[string]string {
+	serviceAccounts := make(map[string]string)
+	for _, hostProject := range c.HostProjects {
+		if hostProject.ServiceAccount != "" {
+			serviceAccounts[hostProject.Project] = hostProject.ServiceAccount
+		}
+	}
+	return serviceAccounts
+}
+
+// Redact returns a copy of the configuration whose secret fields are replaced by Redacted
+func (c *Config) Redact() *Config {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if v.Type().Field(i).Tag.Get("redact") != "true" {
+			if field.Kind() == reflect.Struct {
+				redact(field)
+			}
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			if field.Len() > 0 {
+				field.SetString(Redacted)
+			}
+		case reflect.Map:
+			if field.Len() > 0 {
+				// Keep keys, never share the original map
+				m := reflect.MakeMap(field.Type())
+				for _, key := range field.MapKeys() {
+					m.SetMapIndex(key, reflect.ValueOf(Redacted))
+				}
+				field.Set(m)
+			}
+		}
+	}
+}
+
+// Split a comma separated list, ignoring blanks
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
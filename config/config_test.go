@@ -0,0 +1,210 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Environment lookup from the given variables
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, `
+listen_address: ":9090"
+log:
+  level: info
+timeouts:
+  write: 30s
+host_projects:
+  - project: host-a
+    networks: [lh-network]
+    service_account: firewall@host-a.iam.gserviceaccount.com
+    backend: hierarchical_firewall_policy
+    firewall_policy: "123"
+secure_tags:
+  sp-web-https: tagValues/281474976710656
+admins: [admin@example.com]
+tracing:
+  exporter: otlp
+  otlp_headers:
+    api-key: secret
+`)
+
+	c, err := Load(env(map[string]string{"CONFIG_FILE": path, "LOG_LEVEL": "warn", "TRUSTED_ISSUERS": "issuer-a, ,issuer-b", "CI": "true"}))
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if c.ListenAddress != ":9090" || time.Duration(c.Timeouts.Write) != 30*time.Second || len(c.Admins) != 1 {
+		t.Errorf("Expected file settings, got %+v", c)
+	}
+	if len(c.HostProjects) != 1 || c.HostProjects[0].Networks[0] != "lh-network" {
+		t.Errorf("Expected file host projects, got %+v", c.HostProjects)
+	}
+	if c.ServiceAccounts()["host-a"] != "firewall@host-a.iam.gserviceaccount.com" || c.HostProjects[0].Backend != BackendHierarchicalFirewallPolicy {
+		t.Errorf("Expected host-a service account and backend, got %v and %+v", c.ServiceAccounts(), c.HostProjects[0])
+	}
+	if c.SecureTags["sp-web-https"] != "tagValues/281474976710656" {
+		t.Errorf("Expected file secure tags, got %v", c.SecureTags)
+	}
+	if time.Duration(c.Timeouts.Read) != 15*time.Second || c.Cache.Readiness == 0 {
+		t.Errorf("Expected defaults for unset settings, got %+v", c)
+	}
+	if c.Log.Level != "warn" || strings.Join(c.TrustedIssuers, ",") != "issuer-a,issuer-b" || c.AccessLog {
+		t.Errorf("Expected environment to override file settings, got %+v", c)
+	}
+}
+
+func TestLoadHostProjectsFromEnv(t *testing.T) {
+	path := writeFile(t, `
+host_projects:
+  - project: host-a
+    service_account: firewall@host-a.iam.gserviceaccount.com
+    backend: hierarchical_firewall_policy
+    firewall_policy: "123"
+  - project: host-c
+`)
+
+	c, err := Load(env(map[string]string{"CONFIG_FILE": path, "HOST_PROJECTS": "host-a,host-b"}))
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	// Listed host projects keep their file settings, others are left out
+	expected := []HostProject{
+		{Project: "host-a", ServiceAccount: "firewall@host-a.iam.gserviceaccount.com", Backend: BackendHierarchicalFirewallPolicy, FirewallPolicy: "123"},
+		{Project: "host-b"},
+	}
+	if !reflect.DeepEqual(c.HostProjects, expected) {
+		t.Errorf("Unexpected host projects. Got %+v want %+v", c.HostProjects, expected)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		Title    string
+		File     string
+		Env      map[string]string
+		Expected []string
+	}{
+		{Title: "Unknown setting", File: "listen_adress: :9090", Expected: []string{"field listen_adress not found"}},
+		{Title: "Invalid duration", File: "timeouts:\n  read: 15", Expected: []string{"missing unit in duration"}},
+		{
+			Title: "Invalid settings",
+			File:  "log:\n  format: xml\ntimeouts:\n  idle: -1s\ntrusted_issuers: []\nhost_projects:\n  - project: host\n  - project: host\n    networks: [global/networks/lh-network]\n    service_account: sa@example.com\n  - project: host-b\n    networks: [lh-network]\n    backend: network_firewall_policy\n  - project: host-c\n    backend: nsx\n  - project: host-d\n    firewall_policy: lh-policy\n  - project: host-e\n    priority_bands:\n      - name: apps\n        applications: [sp/web, sp]\n      - name: critical\n        applications: [sp/web]\nsecure_tags:\n  sp-web-https: sp-web-https",
+			Env:   map[string]string{"PORT": "a:b", "LOG_LEVEL": "verbose", "GUARDRAIL_POLICY_FILE": "/nonexistent.json", "STATE_DIR": "/nonexistent-state"},
+			Expected: []string{"state_dir: stat /nonexistent-state", "listen_address: expected [host]:port", "log.level: unknown level [verbose]", "log.format: expected text, json or stackdriver, got [xml]", "timeouts.idle: must be positive", "trusted_issuers: at least one issuer", "/nonexistent.json", "host_projects[1].project: duplicated host project [host]", "host_projects[1].networks[0]: expected a network name", "host_projects[1].service_account: expected a service account email",
+				"host_projects[2].firewall_policy: is required by [network_firewall_policy] backend", "host_projects[2].networks: not supported by [network_firewall_policy] backend", "host_projects[3].backend: expected vpc_firewall", "host_projects[4].firewall_policy: only supported by firewall policy backends",
+				"host_projects[5].priority_bands[0].applications[1]: expected <SERVICE_PROJECT>/<APPLICATION>, got [sp]", "host_projects[5].priority_bands[1].applications[0]: application [sp/web] already has a band",
+				"secure_tags.sp-web-https: expected tagValues/<ID>, got [sp-web-https]"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			vars := map[string]string{"CONFIG_FILE": writeFile(t, test.File)}
+			for name, value := range test.Env {
+				vars[name] = value
+			}
+
+			_, err := Load(env(vars))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, expected := range test.Expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error to contain '%s', got '%v'", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	c := Default(env(nil))
+	c.Tracing.OTLPHeaders = map[string]string{"api-key": "secret"}
+
+	redacted := c.Redact()
+	if redacted.Tracing.OTLPHeaders["api-key"] != Redacted {
+		t.Errorf("Expected secret to be redacted, got %v", redacted.Tracing.OTLPHeaders)
+	}
+	if c.Tracing.OTLPHeaders["api-key"] != "secret" {
+		t.Errorf("Expected original configuration to be kept, got %v", c.Tracing.OTLPHeaders)
+	}
+	if redacted.ListenAddress != c.ListenAddress {
+		t.Errorf("Expected other settings to be kept, got %+v", redacted)
+	}
+}
+
+func TestManagesHostProject(t *testing.T) {
+	c := Default(env(nil))
+	if !c.ManagesHostProject("host") || !c.ManagesNetwork("host", "lh-network") {
+		t.Error("Expected all host projects and networks to be managed by default")
+	}
+
+	c, err := Load(env(map[string]string{"HOST_PROJECTS": "host-a,host-b"}))
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	c.HostProjects[0].Networks = []string{"lh-network"}
+
+	tests := []struct {
+		Project  string
+		Network  string
+		Expected bool
+	}{
+		{Project: "host-a", Network: "lh-network", Expected: true},
+		{Project: "host-a", Network: "other-network", Expected: false},
+		{Project: "host-b", Network: "other-network", Expected: true},
+		{Project: "host-c", Network: "lh-network", Expected: false},
+	}
+
+	for _, test := range tests {
+		if c.ManagesNetwork(test.Project, test.Network) != test.Expected {
+			t.Errorf("Wrong management of network %s of %s. Want %v", test.Network, test.Project, test.Expected)
+		}
+	}
+	if c.ManagesHostProject("host-c") {
+		t.Error("Expected host-c not to be managed")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	if format := Default(env(nil)).Log.Format; format != LogFormatText {
+		t.Errorf("Expected text logs, got %s", format)
+	}
+
+	c, err := Load(env(map[string]string{"K_SERVICE": "firewall-api"}))
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if c.Log.Format != LogFormatStackdriver {
+		t.Errorf("Expected Stackdriver logs on Cloud Run, got %s", c.Log.Format)
+	}
+}
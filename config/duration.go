@@ -0,0 +1,40 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a time.Duration written as a string such as "15s" in YAML and JSON
+type Duration time.Duration
+
+// String returns the duration such as "15s"
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalYAML reads a duration such as "15s"
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalYAML writes the duration such as "15s"
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// MarshalJSON writes the duration such as "15s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"github.com/adeo/iwc-gcp-firewall-api/tracing"
+	"github.com/gorilla/mux"
+)
+
+// ListAddressGroupsHandler returns address groups of the given host project
+func (s *Server) ListAddressGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	addressGroups, err := services.ListAddressGroups(s.addressGroupStore, project)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(addressGroups)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// GetAddressGroupHandler returns the given address group
+func (s *Server) GetAddressGroupHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	addressGroup, err := s.addressGroupStore.GetAddressGroup(project, mux.Vars(r)["address_group"])
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(addressGroup)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// SaveAddressGroupHandler creates or replaces the given address group and re-applies it to referencing rules
+func (s *Server) SaveAddressGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.AddressGroup
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validateHostProject(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	body.Name = mux.Vars(r)["address_group"]
+	addressGroupResult, err := services.SaveAddressGroup(r.Context(), s.tracedManager(r.Context()), s.addressGroupStore, s.tracedGoogleClient(r.Context(), project), s.guardrailPolicy, project, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	err = services.RelativeBatchPriorities(s.priorityBandStore, project, "", "", addressGroupResult.Results)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(addressGroupResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// DeleteAddressGroupHandler deletes the given address group
+func (s *Server) DeleteAddressGroupHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validateHostProject(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	err = services.DeleteAddressGroup(r.Context(), s.addressGroupStore, project, mux.Vars(r)["address_group"])
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The function valid if
+// - provided Bearer token is okay
+// - consumer is owner of the host project
+func (s *Server) validateHostProject(r *http.Request) (err error) {
+	ctx, span := tracing.Start(r.Context(), "validateHostProject")
+	defer func() { tracing.End(span, err) }()
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+
+	user, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialInvalidToken)
+		return err
+	}
+
+	err = s.tracedGoogleClient(ctx, project).IsProjectOwner(user, project)
+	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialNotHostOwner)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// AnalyzeApplicationRulesHandler reports shadowed, duplicate and overlapping rules involving the given application rules
+func (s *Server) AnalyzeApplicationRulesHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	analysis, err := services.AnalyzeApplicationRules(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(analysis)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// AnalyzeProjectRulesHandler reports shadowed, duplicate and overlapping rules of the given host project
+func (s *Server) AnalyzeProjectRulesHandler(w http.ResponseWriter, r *http.Request) {
+	// Host project rules are not limited to the caller's applications
+	err := s.validateHostProject(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	analysis, err := services.AnalyzeProjectRules(r.Context(), s.tracedManager(r.Context()), project)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(analysis)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
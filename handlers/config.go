@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// GetConfigHandler returns the configuration of the API, without its secrets
+func (s *Server) GetConfigHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validateAdmin(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(s.config.Redact())
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// The function valid if
+// - provided Bearer token is okay
+// - consumer is one of the configured admins
+func (s *Server) validateAdmin(r *http.Request) error {
+	user, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialInvalidToken)
+		return err
+	}
+
+	for _, admin := range s.config.Admins {
+		if admin == user {
+			return nil
+		}
+	}
+
+	metrics.DenyAuthorization(metrics.DenialNotAdmin)
+	return models.NewForbiddenError(fmt.Sprintf("User [%s] is not an admin of this API", user))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// CheckConnectivityHandler returns whether given traffic to or from the given application is allowed, and the deciding rule
+func (s *Server) CheckConnectivityHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.ConnectivityQuery
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	result, err := services.CheckConnectivity(r.Context(), s.tracedManager(r.Context()), s.networkManagerOf(project), project, serviceProject, application, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(result)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
@@ -1,156 +1,662 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
 	"github.com/adeo/iwc-gcp-firewall-api/models"
 	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"github.com/adeo/iwc-gcp-firewall-api/tracing"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	compute "google.golang.org/api/compute/v1"
 )
 
-var (
-	manager      models.FirewallRuleManager
-	googleClient models.GoogleClientInterface
-)
-
-func init() {
-	manager, _ = models.NewFirewallRuleClient()
-	googleClient, _ = models.NewGoogleClient()
+// References of a rule request to other resources, to track once the rule is written
+type ruleReferences struct {
+	addressGroups *models.AddressGroupReference
+	dependency    *models.ApplicationDependency
 }
 
 // ListFirewallRuleHandler returns a set of firewall rules
-func ListFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) ListFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate needed permissions
-	err := validate(r)
+	err := s.validate(r)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	project, serviceProject, application, _ := helpers.GetMuxVars(r)
-	applicationRule, err := services.ListFirewallRule(manager, project, serviceProject, application)
+	applicationRule, err := services.ListFirewallRule(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	etag := services.ETag(applicationRule)
+	err = services.RelativePriorities(s.priorityBandStore, applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	res, err := json.Marshal(applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
+	w.Header().Set("ETag", etag)
 	fmt.Fprint(w, string(res))
 }
 
 // GetFirewallRuleHandler return mathing firewall rule
-func GetFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
-	err := validate(r)
+func (s *Server) GetFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	project, serviceProject, application, rule := helpers.GetMuxVars(r)
-	applicationRule, err := services.GetFirewallRule(manager, project, serviceProject, application, rule)
+	applicationRule, err := services.GetFirewallRule(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	etag := services.ETag(applicationRule)
+	err = services.RelativePriorities(s.priorityBandStore, applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	res, err := json.Marshal(applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
+	w.Header().Set("ETag", etag)
 	fmt.Fprint(w, string(res))
 }
 
 // CreateFirewallRuleHandler create a given rule
-func CreateFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
 	// Decode given rule in order to create it
-	var body compute.Firewall
+	var body models.FirewallRuleRequest
 	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		handleError(models.NewBadRequestError("Fail to decode body"), w)
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
 		return
 	}
 
 	// Validate needed permissions
-	err = validate(r)
+	err = s.validate(r)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	project, serviceProject, application, rule := helpers.GetMuxVars(r)
-	applicationRule, err := services.CreateFirewallRule(manager, project, serviceProject, application, rule, body)
+
+	// Allow safe creation when rule must not exist yet
+	err = services.CheckIfNoneMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-None-Match"))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	references, err := s.prepareRules(r, project, serviceProject, application, &body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	applicationRule, err := services.CreateFirewallRule(r.Context(), s.tracedManager(r.Context()), s.tracedGoogleClient(r.Context(), project), s.guardrailPolicy, project, serviceProject, application, rule, body.Firewall)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+	s.trackReferences(r.Context(), project, serviceProject, application, rule, &references[0])
+
+	etag := services.ETag(applicationRule)
+	err = services.RelativePriorities(s.priorityBandStore, applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	res, err := json.Marshal(applicationRule)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
+	w.Header().Set("ETag", etag)
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprint(w, string(res))
 }
 
 // DeleteFirewallRuleHandler delete the given firewall rule
-func DeleteFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
-	err := validate(r)
+func (s *Server) DeleteFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
 	project, serviceProject, application, rule := helpers.GetMuxVars(r)
-	err = services.DeleteFirewallRule(manager, project, serviceProject, application, rule)
+
+	// Ensure rule has not been modified since client read it
+	err = services.CheckIfMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-Match"))
 	if err != nil {
-		handleError(err, w)
+		handleError(err, w, r)
 		return
 	}
 
+	err = services.DeleteFirewallRule(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+	s.trackReferences(r.Context(), project, serviceProject, application, rule, nil)
+	s.warnDependentRules(r.Context(), w, project, serviceProject, application, rule)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// BatchCreateFirewallRuleHandler create a given list of named rules
+func (s *Server) BatchCreateFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode given rules in order to create them
+	var body []models.BatchRuleRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	// Validate needed permissions once for the whole batch
+	err = s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+
+	// Each rule is prepared as on creation, failures being reported in its result
+	references := make(map[string]ruleReferences)
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		ruleReferences, err := s.prepareRules(r, project, serviceProject, application, request)
+		if err != nil {
+			return err
+		}
+		references[customName] = ruleReferences[0]
+		return nil
+	}
+
+	batchResult := services.BatchCreateFirewallRules(r.Context(), s.tracedManager(r.Context()), s.tracedGoogleClient(r.Context(), project), s.guardrailPolicy, prepare, project, serviceProject, application, body)
+	for _, result := range batchResult.Results {
+		if result.Code == http.StatusCreated {
+			rr := references[result.CustomName]
+			s.trackReferences(r.Context(), project, serviceProject, application, result.CustomName, &rr)
+		}
+	}
+
+	err = services.RelativeBatchPriorities(s.priorityBandStore, project, serviceProject, application, batchResult.Results)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(batchResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, string(res))
+}
+
+// DeleteApplicationFirewallRuleHandler delete all rules of the given application
+func (s *Server) DeleteApplicationFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	batchResult, err := services.DeleteApplicationFirewallRules(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+	for _, result := range batchResult.Results {
+		if result.Code == http.StatusNoContent {
+			s.trackReferences(r.Context(), project, serviceProject, application, result.CustomName, nil)
+		}
+	}
+	s.warnDependentRules(r.Context(), w, project, serviceProject, application, "")
+
+	res, err := json.Marshal(batchResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, string(res))
+}
+
+// RenameFirewallRuleHandler rename the given rule
+func (s *Server) RenameFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.RenameRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+
+	// Ensure rule has not been modified since client read it
+	err = services.CheckIfMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	// The new rule is prepared as on creation, for its application
+	newApplication := body.Application
+	if newApplication == "" {
+		newApplication = application
+	}
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		_, err := s.prepareRules(r, project, serviceProject, newApplication, request)
+		return err
+	}
+
+	renameResult, err := services.RenameFirewallRule(r.Context(), s.tracedManager(r.Context()), s.instanceManagerOf(project), s.priorityBandStore, s.tracedGoogleClient(r.Context(), project), s.guardrailPolicy, prepare, project, serviceProject, application, rule, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	s.copyReferences(r.Context(), project, serviceProject, application, rule, renameResult.Application, renameResult.Rules[0].CustomName)
+	if renameResult.OldRuleDeleted {
+		s.trackReferences(r.Context(), project, serviceProject, application, rule, nil)
+	}
+
+	etag := services.ETag(&renameResult.ApplicationRule)
+	err = services.RelativePriorities(s.priorityBandStore, &renameResult.ApplicationRule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(renameResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	if renameResult.Error != nil {
+		w.WriteHeader(http.StatusMultiStatus)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	fmt.Fprint(w, string(res))
+}
+
+// CompleteRenameHandler ends the rename which created the given rule
+func (s *Server) CompleteRenameHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+
+	// Ensure rule has not been modified since client read it
+	err = services.CheckIfMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	renameResult, err := services.CompleteRename(r.Context(), s.tracedManager(r.Context()), s.instanceManagerOf(project), project, serviceProject, application, rule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+	s.trackReferences(r.Context(), project, serviceProject, renameResult.OldApplication, renameResult.OldCustomName, nil)
+
+	etag := services.ETag(&renameResult.ApplicationRule)
+	err = services.RelativePriorities(s.priorityBandStore, &renameResult.ApplicationRule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(renameResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	if renameResult.Error != nil {
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	fmt.Fprint(w, string(res))
+}
+
+// ListRuleTargetsHandler returns instances targeted by the given rule
+func (s *Server) ListRuleTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+	ruleTargets, err := services.ListRuleTargets(r.Context(), s.tracedManager(r.Context()), s.instanceManagerOf(project), project, serviceProject, application, rule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(ruleTargets)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// AttachRuleTargetHandler adds the given rule's target tag to the given instance
+func (s *Server) AttachRuleTargetHandler(w http.ResponseWriter, r *http.Request) {
+	s.setRuleTargetHandler(w, r, services.AttachRuleTarget)
+}
+
+// DetachRuleTargetHandler removes the given rule's target tag from the given instance
+func (s *Server) DetachRuleTargetHandler(w http.ResponseWriter, r *http.Request) {
+	s.setRuleTargetHandler(w, r, services.DetachRuleTarget)
+}
+
+type setRuleTargetFunc func(context.Context, models.FirewallRuleManager, models.InstanceManager, string, string, string, string, string) (*models.TargetInstance, error)
+
+func (s *Server) setRuleTargetHandler(w http.ResponseWriter, r *http.Request, set setRuleTargetFunc) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+	target, err := set(r.Context(), s.tracedManager(r.Context()), s.instanceManagerOf(project), project, serviceProject, application, rule, mux.Vars(r)["instance"])
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(target)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// CloneFirewallRuleHandler copy all rules of the given application to another service project/host project pair
+func (s *Server) CloneFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.CloneRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	if body.Project == "" || body.ServiceProject == "" {
+		handleError(models.NewBadRequestError("Missing destination project or service_project"), w, r)
+		return
+	}
+
+	err = s.validateManagedProject(body.Project)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	// Caller must be allowed on both sides
+	err = s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	err = s.validateProjects(r, body.Project, body.ServiceProject)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	if body.Application == "" {
+		body.Application = application
+	}
+
+	// Cloned rules are prepared for the destination as on creation
+	references := make(map[string]ruleReferences)
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		ruleReferences, err := s.prepareRules(r, body.Project, body.ServiceProject, body.Application, request)
+		if err != nil {
+			return err
+		}
+		references[customName] = ruleReferences[0]
+		return nil
+	}
+
+	batchResult, err := services.CloneFirewallRules(r.Context(), s.tracedManager(r.Context()), s.addressGroupStore, s.dependencyStore, s.priorityBandStore, s.tracedGoogleClient(r.Context(), body.Project), s.guardrailPolicy, prepare, project, serviceProject, application, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+	for _, result := range batchResult.Results {
+		if !body.DryRun && result.Error == nil && result.Action != models.CloneActionSkip {
+			rr := references[result.CustomName]
+			s.trackReferences(r.Context(), body.Project, body.ServiceProject, body.Application, result.CustomName, &rr)
+		}
+	}
+
+	err = services.RelativeBatchPriorities(s.priorityBandStore, body.Project, body.ServiceProject, body.Application, batchResult.Results)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(batchResult)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, string(res))
+}
+
 // The function valid if
 // - provided Bearer token is okay
 // - provided service project is a host project's service project
 // - consumer is owner of the service project
-func validate(r *http.Request) error {
+func (s *Server) validate(r *http.Request) error {
 	project, serviceProject, _, _ := helpers.GetMuxVars(r)
+	return s.validateProjects(r, project, serviceProject)
+}
 
-	user, err := services.GetUserEmailFromJWT(r.Header.Get("Authorization"))
+// Same as validate for the given host project and service project
+func (s *Server) validateProjects(r *http.Request, project, serviceProject string) (err error) {
+	ctx, span := tracing.Start(r.Context(), "validate")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
 	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialInvalidToken)
 		return err
 	}
 
 	// Test owner rights
-	err = googleClient.IsProjectOwner(user, serviceProject)
+	err = s.tracedGoogleClient(ctx, project).IsProjectOwner(user, serviceProject)
 	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialNotOwner)
 		return err
 	}
 
 	// Test if service project/project
-	err = googleClient.IsAServiceProjectOf(serviceProject, project)
+	err = s.tracedGoogleClient(ctx, project).IsAServiceProjectOf(serviceProject, project)
 	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialNotServiceProject)
 		return err
 	}
 
 	return nil
 }
 
-func handleError(err error, w http.ResponseWriter) {
+// Turn given rule requests into Google rules, in place.
+// Returns for each rule the resources it references, to track once the rule is written
+func (s *Server) prepareRules(r *http.Request, project, serviceProject, application string, requests ...*models.FirewallRuleRequest) ([]ruleReferences, error) {
+	references := make([]ruleReferences, len(requests))
+	rules := make([]*compute.Firewall, len(requests))
+	for i, request := range requests {
+		err := services.ExpandServices(s.serviceCatalog, request)
+		if err != nil {
+			return nil, err
+		}
+
+		references[i].addressGroups, err = services.ExpandAddressGroups(s.addressGroupStore, project, request)
+		if err != nil {
+			return nil, err
+		}
+
+		// Caller must also own source applications of other service projects
+		for _, source := range request.SourceApplications {
+			if source.ServiceProject != "" && source.ServiceProject != serviceProject {
+				err = s.validateProjects(r, project, source.ServiceProject)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		references[i].dependency, err = services.ExpandSourceApplications(r.Context(), s.tracedManager(r.Context()), project, serviceProject, request)
+		if err != nil {
+			return nil, err
+		}
+
+		err = services.ApplyPriorityBand(s.priorityBandStore, project, serviceProject, application, request)
+		if err != nil {
+			return nil, err
+		}
+
+		err = services.ApplyGuardrails(s.guardrailPolicy, project, &request.Firewall)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = &request.Firewall
+	}
+
+	err := services.ResolveNetwork(r.Context(), s.networkManagerOf(project), project, serviceProject, rules...)
+	if err != nil {
+		return nil, err
+	}
+
+	return references, nil
+}
+
+// Record the resources referenced by a written rule. A nil references forgets them.
+// The rule is written anyway, so failures are only logged
+func (s *Server) trackReferences(ctx context.Context, project, serviceProject, application, rule string, references *ruleReferences) {
+	if references == nil {
+		references = &ruleReferences{}
+	}
+
+	logger := helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule":            rule,
+	})
+
+	err := services.TrackAddressGroups(s.addressGroupStore, project, serviceProject, application, rule, references.addressGroups)
+	if err != nil {
+		logger.WithField("go-err", err.Error()).Error("Fail to track address groups")
+	}
+
+	err = services.TrackSourceApplications(s.dependencyStore, project, serviceProject, application, rule, references.dependency)
+	if err != nil {
+		logger.WithField("go-err", err.Error()).Error("Fail to track source applications")
+	}
+}
+
+// Make a renamed rule reference the resources of its old name. The rule is renamed anyway, so failures are only logged
+func (s *Server) copyReferences(ctx context.Context, project, serviceProject, application, rule, newApplication, newRule string) {
+	logger := helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule":            rule,
+	})
+
+	err := services.CopyAddressGroups(s.addressGroupStore, project, serviceProject, application, rule, newApplication, newRule)
+	if err != nil {
+		logger.WithField("go-err", err.Error()).Error("Fail to copy address groups")
+	}
+
+	err = services.CopySourceApplications(s.dependencyStore, project, serviceProject, application, rule, newApplication, newRule)
+	if err != nil {
+		logger.WithField("go-err", err.Error()).Error("Fail to copy source applications")
+	}
+}
+
+// Warn the caller about rules of other applications allowing traffic from the deleted application rules
+func (s *Server) warnDependentRules(ctx context.Context, w http.ResponseWriter, project, serviceProject, application, rule string) {
+	names, err := services.ListDependentRules(s.dependencyStore, project, serviceProject, application, rule)
+	if err != nil {
+		helpers.Logger(ctx).WithFields(logrus.Fields{
+			"go-err":          err.Error(),
+			"project":         project,
+			"service_project": serviceProject,
+			"application":     application,
+		}).Error("Fail to list dependent rules")
+		return
+	}
+
+	if len(names) > 0 {
+		w.Header().Set("Warning", fmt.Sprintf(`199 - "Rules [%s] allow traffic from application [%s]"`, strings.Join(names, ", "), application))
+	}
+}
+
+func handleError(err error, w http.ResponseWriter, r *http.Request) {
 	if v, ok := err.(*models.ApplicationError); ok {
 		w.WriteHeader(v.Code)
 		fmt.Fprint(w, v.Error())
@@ -158,8 +664,8 @@ func handleError(err error, w http.ResponseWriter) {
 	}
 
 	// Else, throw error 500
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(r.Context()).WithFields(logrus.Fields{
 		"go-err": err.Error(),
 	}).Error("Unexpected error")
-	handleError(models.NewInternalError(), w)
+	handleError(models.NewInternalError(), w, r)
 }
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/gorilla/mux"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestCreateFirewallRuleHandler(t *testing.T) {
+	tests := []struct {
+		Title    string
+		User     string
+		Body     string
+		Expected int
+	}{
+		{Title: "Created", User: "user@example.com", Body: `{"sourceRanges":["10.0.0.0/8"],"services":["https"]}`, Expected: http.StatusCreated},
+		{Title: "Not owner", User: "other@example.com", Body: `{"sourceRanges":["10.0.0.0/8"]}`, Expected: http.StatusForbidden},
+		{Title: "Invalid body", User: "user@example.com", Body: `[`, Expected: http.StatusBadRequest},
+	}
+
+	server, manager := newTestServer(t)
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/web/firewall_rule/https", strings.NewReader(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", testToken(test.User))
+			req = mux.SetURLVars(req, map[string]string{"project": "host", "service_project": "sp", "application": "web", "rule": "https"})
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.CreateFirewallRuleHandler).ServeHTTP(rr, req)
+
+			if rr.Code != test.Expected {
+				t.Errorf("handler returned wrong status code: got %v want %v. Body %s", rr.Code, test.Expected, rr.Body.String())
+			}
+		})
+	}
+
+	if len(manager.Rules["host"]) != 1 {
+		t.Fatalf("Expected 1 rule, got %d", len(manager.Rules["host"]))
+	}
+	rule := manager.Rules["host"][0]
+	if rule.Name != "sp-web-https" || rule.Network != "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network" || len(rule.Allowed) != 1 {
+		t.Errorf("Unexpected rule %+v", rule)
+	}
+}
+
+func TestListFirewallRuleHandler(t *testing.T) {
+	server, manager := newTestServer(t)
+	manager.Rules["host"] = []*compute.Firewall{
+		&compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https"}},
+		&compute.Firewall{Name: "sp-api-https", TargetTags: []string{"sp-api-https"}},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/project/host/service_project/sp/application/web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", testToken("user@example.com"))
+	req = mux.SetURLVars(req, map[string]string{"project": "host", "service_project": "sp", "application": "web"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.ListFirewallRuleHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var applicationRule models.ApplicationRule
+	err = json.Unmarshal(rr.Body.Bytes(), &applicationRule)
+	if err != nil || len(applicationRule.Rules) != 1 || applicationRule.Rules[0].CustomName != "https" || rr.Header().Get("ETag") == "" {
+		t.Errorf("handler returned unexpected body: got %s", rr.Body.String())
+	}
+}
+
+func TestFirewallRulePriorityBand(t *testing.T) {
+	server, manager := newTestServer(t)
+	server.priorityBandStore.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 1000, Default: true})
+	vars := map[string]string{"project": "host", "service_project": "sp", "application": "web", "rule": "https"}
+
+	// Without priority, rule takes the middle of the band
+	req, _ := http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/web/firewall_rule/https", strings.NewReader(`{"sourceRanges":["10.0.0.0/8"],"services":["https"]}`))
+	req.Header.Set("Authorization", testToken("user@example.com"))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.CreateFirewallRuleHandler).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	if rr.Code != http.StatusCreated || manager.Rules["host"][0].Priority != 1050 {
+		t.Fatalf("Unexpected creation: got %v, %s", rr.Code, rr.Body.String())
+	}
+
+	// Reads give the priority relative to the band
+	req, _ = http.NewRequest(http.MethodGet, "/project/host/service_project/sp/application/web/firewall_rule/https", nil)
+	req.Header.Set("Authorization", testToken("user@example.com"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.GetFirewallRuleHandler).ServeHTTP(rr, mux.SetURLVars(req, vars))
+
+	var applicationRule models.ApplicationRule
+	err := json.Unmarshal(rr.Body.Bytes(), &applicationRule)
+	if err != nil || len(applicationRule.Rules) != 1 || applicationRule.Rules[0].Rule.Priority != 50 {
+		t.Fatalf("handler returned unexpected body: got %s", rr.Body.String())
+	}
+
+	// The tag of the read matches on writes
+	req, _ = http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/web/firewall_rule/https:disable", nil)
+	req.Header.Set("Authorization", testToken("user@example.com"))
+	req.Header.Set("If-Match", rr.Header().Get("ETag"))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.DisableFirewallRuleHandler).ServeHTTP(rr, mux.SetURLVars(req, vars))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"priority":50`) {
+		t.Errorf("Unexpected update: got %v, %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestFirewallRuleConfiguredPriorityBands(t *testing.T) {
+	server, manager := newTestServer(t)
+	configured := models.NewPriorityBandMemoryStore()
+	configured.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 1000})
+	configured.SaveAssignment(models.BandAssignment{Project: "host", ServiceProject: "sp", Application: "api", Band: "apps"})
+	server.priorityBandStore = models.NewPriorityBandConfigStore(configured, []string{"host"}, models.NewPriorityBandMemoryStore())
+
+	// Applications without band of a host project configured with bands cannot create rules
+	for application, expected := range map[string]int{"web": http.StatusBadRequest, "api": http.StatusCreated} {
+		req, _ := http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/"+application+"/firewall_rule/https", strings.NewReader(`{"sourceRanges":["10.0.0.0/8"],"services":["https"]}`))
+		req.Header.Set("Authorization", testToken("user@example.com"))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(server.CreateFirewallRuleHandler).ServeHTTP(rr, mux.SetURLVars(req, map[string]string{"project": "host", "service_project": "sp", "application": application, "rule": "https"}))
+		if rr.Code != expected {
+			t.Errorf("handler returned wrong status code for application [%s]: got %v want %v. Body %s", application, rr.Code, expected, rr.Body.String())
+		}
+	}
+
+	if len(manager.Rules["host"]) != 1 || manager.Rules["host"][0].Priority != 1050 {
+		t.Errorf("Unexpected rules %v", manager.Rules["host"])
+	}
+}
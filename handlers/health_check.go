@@ -1,11 +1,107 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"golang.org/x/oauth2/google"
+	compute "google.golang.org/api/compute/v1"
 )
 
 // HealthCheckHandler ensure application is runniing properly
 func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, `{"ping": "pong"}`)
 }
+
+// ReadinessCheckHandler ensure application can serve requests, i.e. reach Google with its credentials
+func (s *Server) ReadinessCheckHandler(w http.ResponseWriter, r *http.Request) {
+	readiness := s.readinessChecker.Check(r.Context())
+	res, err := json.Marshal(readiness)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	if readiness.Status != models.ReadinessUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, string(res))
+}
+
+// Google dependencies are ready when
+// - clients are built
+// - credentials, and each service account they impersonate, can mint a token
+// - a compute call succeeds, on the project of the credentials or GOOGLE_CLOUD_PROJECT
+func (s *Server) newReadinessChecker() *services.ReadinessChecker {
+	return services.NewReadinessChecker(time.Duration(s.config.Cache.Readiness),
+		services.ReadinessCheck{Name: "clients", Check: s.checkClients},
+		services.ReadinessCheck{Name: "credentials", Check: s.checkCredentials},
+		services.ReadinessCheck{Name: "compute", Check: s.checkCompute},
+	)
+}
+
+func (s *Server) checkClients(ctx context.Context) error {
+	var failures []string
+	for name, err := range s.clientErrors {
+		failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("fail to create clients: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (s *Server) checkCredentials(ctx context.Context) error {
+	credentials, err := google.FindDefaultCredentials(ctx, compute.CloudPlatformScope)
+	if err != nil {
+		return err
+	}
+
+	_, err = credentials.TokenSource.Token()
+	if err != nil || s.credentials == nil {
+		return err
+	}
+
+	// A fresh token source mints a token, instead of reusing the one of clients
+	var failures []string
+	for _, serviceAccount := range s.credentials.ServiceAccounts() {
+		ts, err := models.NewTokenSource(serviceAccount)
+		if err == nil {
+			_, err = ts.Token()
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", serviceAccount, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("fail to impersonate service accounts: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (s *Server) checkCompute(ctx context.Context) error {
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	if project == "" {
+		credentials, err := google.FindDefaultCredentials(ctx, compute.CloudPlatformScope)
+		if err != nil {
+			return err
+		}
+		project = credentials.ProjectID
+	}
+	if project == "" {
+		return errors.New("no project to call, set GOOGLE_CLOUD_PROJECT")
+	}
+
+	_, err := s.tracedManager(ctx).GetFirewallQuota(project)
+	return err
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
 	"github.com/sirupsen/logrus"
 )
 
@@ -42,3 +48,40 @@ func TestHealthCheckHandler(t *testing.T) {
 	}
 
 }
+
+func TestReadinessCheckHandler(t *testing.T) {
+	tests := []struct {
+		Title    string
+		Err      error
+		Expected int
+		Status   string
+	}{
+		{Title: "Ready", Expected: http.StatusOK, Status: models.ReadinessUp},
+		{Title: "Degraded", Err: errors.New("Fail to mint a token"), Expected: http.StatusServiceUnavailable, Status: models.ReadinessDown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			checkErr := test.Err
+			server := &Server{}
+			server.readinessChecker = services.NewReadinessChecker(time.Minute, services.ReadinessCheck{Name: "credentials", Check: func(ctx context.Context) error { return checkErr }})
+
+			req, err := http.NewRequest(http.MethodGet, "/_ready", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.ReadinessCheckHandler).ServeHTTP(rr, req)
+
+			if rr.Code != test.Expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, test.Expected)
+			}
+
+			var readiness models.Readiness
+			json.Unmarshal(rr.Body.Bytes(), &readiness)
+			if readiness.Status != test.Status || len(readiness.Dependencies) != 1 {
+				t.Errorf("handler returned unexpected body: got %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"google.golang.org/api/compute/v1"
+)
+
+// ListHostProjectsHandler returns host projects managed by the API
+func (s *Server) ListHostProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		metrics.DenyAuthorization(metrics.DenialInvalidToken)
+		handleError(err, w, r)
+		return
+	}
+
+	hostProjects := models.HostProjects{
+		Restricted:   len(s.config.HostProjects) > 0,
+		HostProjects: make([]models.HostProject, 0, len(s.config.HostProjects)),
+	}
+	for _, hostProject := range s.config.HostProjects {
+		networks := hostProject.Networks
+		if networks == nil {
+			networks = []string{}
+		}
+		hostProjects.HostProjects = append(hostProjects.HostProjects, models.HostProject{Project: hostProject.Project, Networks: networks})
+	}
+
+	res, err := json.Marshal(hostProjects)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// HostProjectMiddleware answers Not Found to requests targeting a host project not managed by the API,
+// before any permission check or Google call
+func (s *Server) HostProjectMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		project, _, _, _ := helpers.GetMuxVars(r)
+		err := s.validateManagedProject(project)
+		if err != nil {
+			handleError(err, w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The function valid if the host project is managed by the API
+func (s *Server) validateManagedProject(project string) error {
+	if !s.config.ManagesHostProject(project) {
+		metrics.DenyAuthorization(metrics.DenialUnmanagedProject)
+		return models.NewNotFoundError(fmt.Sprintf("Host project [%s] is not managed by this API", project))
+	}
+	return nil
+}
+
+// Network manager hiding networks not managed by the API, so that rules cannot use them
+type managedNetworkManager struct {
+	models.NetworkManager
+	config *config.Config
+}
+
+func (m managedNetworkManager) ListSharedNetworks(project, serviceProject string) ([]models.Network, error) {
+	networks, err := m.NetworkManager.ListSharedNetworks(project, serviceProject)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make([]models.Network, 0, len(networks))
+	for _, n := range networks {
+		if m.config.ManagesNetwork(project, n.Name) {
+			managed = append(managed, n)
+		}
+	}
+	return managed, nil
+}
+
+// Firewall rule manager hiding rules of networks not managed by the API, so that they can neither be read nor
+// written through any endpoint
+type managedNetworkRuleManager struct {
+	models.FirewallRuleManager
+	config *config.Config
+}
+
+// Name of the network of the given rule, from its self-link
+func networkName(rule *compute.Firewall) string {
+	return rule.Network[strings.LastIndex(rule.Network, "/")+1:]
+}
+
+func (m managedNetworkRuleManager) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	rules, err := m.FirewallRuleManager.ListFirewallRule(project)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make([]*compute.Firewall, 0, len(rules))
+	for _, rule := range rules {
+		if m.config.ManagesNetwork(project, networkName(rule)) {
+			managed = append(managed, rule)
+		}
+	}
+	return managed, nil
+}
+
+func (m managedNetworkRuleManager) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	rule, err := m.FirewallRuleManager.GetFirewallRule(project, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.config.ManagesNetwork(project, networkName(rule)) {
+		metrics.DenyAuthorization(metrics.DenialUnmanagedProject)
+		return nil, models.NewNotFoundError(fmt.Sprintf("Network of rule [%s] is not managed by this API", name))
+	}
+	return rule, nil
+}
+
+func (m managedNetworkRuleManager) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	if !m.config.ManagesNetwork(project, networkName(rule)) {
+		metrics.DenyAuthorization(metrics.DenialUnmanagedProject)
+		return nil, models.NewNotFoundError(fmt.Sprintf("Network [%s] is not managed by this API", rule.Network))
+	}
+	return m.FirewallRuleManager.CreateFirewallRule(project, rule)
+}
+
+func (m managedNetworkRuleManager) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	// Both the replaced rule and its replacement must be on managed networks
+	_, err := m.GetFirewallRule(project, rule.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.config.ManagesNetwork(project, networkName(rule)) {
+		metrics.DenyAuthorization(metrics.DenialUnmanagedProject)
+		return nil, models.NewNotFoundError(fmt.Sprintf("Network [%s] is not managed by this API", rule.Network))
+	}
+	return m.FirewallRuleManager.UpdateFirewallRule(project, rule)
+}
+
+func (m managedNetworkRuleManager) DeleteFirewallRule(project, name string) error {
+	_, err := m.GetFirewallRule(project, name)
+	if err != nil {
+		return err
+	}
+	return m.FirewallRuleManager.DeleteFirewallRule(project, name)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestHostProjectMiddleware(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.config.HostProjects = []config.HostProject{config.HostProject{Project: "host", Networks: []string{"other-network"}}}
+
+	r := mux.NewRouter()
+	projectRouter := r.PathPrefix("/project/{project}").Subrouter()
+	projectRouter.Use(server.HostProjectMiddleware)
+	projectRouter.Path("/networks").Methods(http.MethodGet).HandlerFunc(server.ListSharedNetworksHandler)
+	applicationRouter := projectRouter.PathPrefix("/service_project/{service_project}/application/{application}").Subrouter()
+	applicationRouter.Path("").Methods(http.MethodGet).HandlerFunc(server.ListFirewallRuleHandler)
+
+	tests := []struct {
+		Title    string
+		URL      string
+		Expected int
+	}{
+		{Title: "Managed host project", URL: "/project/host/service_project/sp/application/web", Expected: http.StatusOK},
+		{Title: "Unmanaged host project", URL: "/project/other/service_project/sp/application/web", Expected: http.StatusNotFound},
+		{Title: "Unmanaged host project discovery", URL: "/project/other/networks?service_project=sp", Expected: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", testToken("user@example.com"))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != test.Expected {
+				t.Errorf("Wrong status code. Got %d want %d: %s", rr.Code, test.Expected, rr.Body.String())
+			}
+		})
+	}
+
+	// Networks not managed are hidden
+	req, err := http.NewRequest(http.MethodGet, "/project/host/networks?service_project=sp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", testToken("user@example.com"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var sharedNetworks models.SharedNetworks
+	err = json.Unmarshal(rr.Body.Bytes(), &sharedNetworks)
+	if err != nil {
+		t.Fatalf("Fail to decode response %s: %v", rr.Body.String(), err)
+	}
+	if len(sharedNetworks.Networks) != 0 {
+		t.Errorf("Expected unmanaged networks to be hidden. Got %+v", sharedNetworks.Networks)
+	}
+}
+
+func TestListHostProjectsHandler(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.config.HostProjects = []config.HostProject{config.HostProject{Project: "host"}}
+
+	req, err := http.NewRequest(http.MethodGet, "/projects", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", testToken("user@example.com"))
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.ListHostProjectsHandler).ServeHTTP(rr, req)
+
+	expected := `{"restricted":true,"data":[{"project":"host","networks":[]}]}`
+	if rr.Code != http.StatusOK || rr.Body.String() != expected {
+		t.Errorf("Unexpected response. Got %d %s want %s", rr.Code, rr.Body.String(), expected)
+	}
+}
+
+func TestUnmanagedNetworkRules(t *testing.T) {
+	server, manager := newTestServer(t)
+	server.config.HostProjects = []config.HostProject{config.HostProject{Project: "host", Networks: []string{"lh-network"}}}
+	manager.Rules["host"] = []*compute.Firewall{
+		&compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https"}, Network: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"},
+		&compute.Firewall{Name: "sp-web-legacy", TargetTags: []string{"sp-web-legacy"}, Network: "https://www.googleapis.com/compute/v1/projects/host/global/networks/other-network"},
+	}
+
+	tests := []struct {
+		Title    string
+		Method   string
+		Rule     string
+		Handler  http.HandlerFunc
+		Expected int
+	}{
+		{Title: "Get", Method: http.MethodGet, Rule: "https", Handler: server.GetFirewallRuleHandler, Expected: http.StatusOK},
+		{Title: "Get on unmanaged network", Method: http.MethodGet, Rule: "legacy", Handler: server.GetFirewallRuleHandler, Expected: http.StatusNotFound},
+		{Title: "Disable on unmanaged network", Method: http.MethodPost, Rule: "legacy", Handler: server.DisableFirewallRuleHandler, Expected: http.StatusNotFound},
+		{Title: "Targets on unmanaged network", Method: http.MethodGet, Rule: "legacy", Handler: server.ListRuleTargetsHandler, Expected: http.StatusNotFound},
+		{Title: "Delete on unmanaged network", Method: http.MethodDelete, Rule: "legacy", Handler: server.DeleteFirewallRuleHandler, Expected: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			req, err := http.NewRequest(test.Method, "/project/host/service_project/sp/application/web/firewall_rule/"+test.Rule, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", testToken("user@example.com"))
+			req = mux.SetURLVars(req, map[string]string{"project": "host", "service_project": "sp", "application": "web", "rule": test.Rule})
+
+			rr := httptest.NewRecorder()
+			test.Handler.ServeHTTP(rr, req)
+
+			if rr.Code != test.Expected {
+				t.Errorf("Wrong status code. Got %d want %d: %s", rr.Code, test.Expected, rr.Body.String())
+			}
+		})
+	}
+
+	if len(manager.Rules["host"]) != 2 || manager.Rules["host"][1].Disabled {
+		t.Errorf("Rule of unmanaged network should be left as is. Got %+v", manager.Rules["host"])
+	}
+
+	// Rules of unmanaged networks are not listed
+	req, err := http.NewRequest(http.MethodGet, "/project/host/service_project/sp/application/web", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", testToken("user@example.com"))
+	req = mux.SetURLVars(req, map[string]string{"project": "host", "service_project": "sp", "application": "web"})
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.ListFirewallRuleHandler).ServeHTTP(rr, req)
+
+	var applicationRule models.ApplicationRule
+	err = json.Unmarshal(rr.Body.Bytes(), &applicationRule)
+	if err != nil || len(applicationRule.Rules) != 1 || applicationRule.Rules[0].CustomName != "https" {
+		t.Errorf("Expected rules of unmanaged networks to be hidden. Got %s", rr.Body.String())
+	}
+	if managed := testutil.ToFloat64(metrics.ManagedRules.WithLabelValues("host")); managed != 1 {
+		t.Errorf("Expected rules of unmanaged networks not to be counted. Got %v", managed)
+	}
+}
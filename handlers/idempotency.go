@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"github.com/sirupsen/logrus"
+)
+
+// idempotencyRecorder forwards the response to the client while recording it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *idempotencyRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *idempotencyRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotent makes the given handler replay its first response for retries sharing the same Idempotency-Key header,
+// when it is a success or a rejection of the request itself (400, 422).
+// A retry with the same key but a different body is rejected with 422, a retry while the first request is in progress with 409
+func (s *Server) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		// Keys are scoped by caller and route
+		user, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+		if err != nil {
+			handleError(err, w, r)
+			return
+		}
+		storeKey := fmt.Sprintf("%s %s %s %s", user, r.Method, r.URL.Path, key)
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			handleError(models.NewBadRequestError("Fail to read body"), w, r)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		requestHash := fmt.Sprintf("%x", sha256.Sum256(body))
+
+		// Claim the key before running the request, so that concurrent retries do not run it twice
+		response, err := s.idempotencyStore.Reserve(storeKey, requestHash)
+		if err != nil {
+			handleError(err, w, r)
+			return
+		}
+
+		if response != nil {
+			if response.RequestHash != requestHash {
+				handleError(models.NewUnprocessableEntityError(fmt.Sprintf("Idempotency-Key [%s] has already been used with a different body", key)), w, r)
+				return
+			}
+
+			if response.Pending {
+				w.Header().Set("Retry-After", "1")
+				handleError(models.NewConflictError(fmt.Sprintf("A request with Idempotency-Key [%s] is still in progress", key)), w, r)
+				return
+			}
+
+			helpers.Logger(r.Context()).WithFields(logrus.Fields{
+				"idempotency_key": key,
+				"request_uri":     r.RequestURI,
+			}).Debugln("Replaying response")
+			// The request ID is the one of the retry
+			for name, values := range response.Header {
+				if name != helpers.RequestIDHeader {
+					w.Header()[name] = values
+				}
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(response.StatusCode)
+			w.Write(response.Body)
+			return
+		}
+
+		// Release the key unless a response is saved, including when next panics
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			err := s.idempotencyStore.Release(storeKey)
+			if err != nil {
+				helpers.Logger(r.Context()).WithFields(logrus.Fields{
+					"go-err":          err.Error(),
+					"idempotency_key": key,
+				}).Error("Fail to release idempotency key")
+			}
+		}()
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		// Other responses, such as conflicts or missing rules, may change on retry, do not keep them
+		if !replayable(recorder.statusCode) {
+			return
+		}
+
+		header := w.Header().Clone()
+		header.Del(helpers.RequestIDHeader)
+		err = s.idempotencyStore.SaveResponse(storeKey, &models.IdempotentResponse{
+			RequestHash: requestHash,
+			StatusCode:  recorder.statusCode,
+			Header:      header,
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			helpers.Logger(r.Context()).WithFields(logrus.Fields{
+				"go-err":          err.Error(),
+				"idempotency_key": key,
+			}).Error("Fail to save idempotent response")
+			return
+		}
+		saved = true
+	}
+}
+
+// replayable tells whether a response of given status code is replayed to retries: successes, and rejections of the request itself
+func replayable(statusCode int) bool {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return true
+	case statusCode == http.StatusBadRequest, statusCode == http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
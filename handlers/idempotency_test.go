@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+)
+
+func TestIdempotent(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	handler := server.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/web/firewall_rule/https", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", testToken("user@example.com"))
+		req.Header.Set("Idempotency-Key", "key")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- send("{}") }()
+	<-started
+
+	// Retries are rejected while the first request is in progress
+	if rr := send("{}"); rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected in progress retry to conflict. Got %v %v", rr.Code, rr.Header())
+	}
+	if rr := send(`{"name":"other"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected retry with another body to be rejected. Got %v", rr.Code)
+	}
+
+	close(release)
+	if rr := <-first; rr.Code != http.StatusCreated {
+		t.Fatalf("Expected first request to run. Got %v", rr.Code)
+	}
+
+	// Retries then replay the first response
+	rr := send("{}")
+	if rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected replayed response. Got %v %v", rr.Code, rr.Header())
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to run once. Got %d", calls)
+	}
+}
+
+func TestIdempotentReplayedResponses(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		Title    string
+		Code     int
+		Replayed bool
+	}{
+		{Title: "Success", Code: http.StatusCreated, Replayed: true},
+		{Title: "Bad request", Code: http.StatusBadRequest, Replayed: true},
+		{Title: "Conflict", Code: http.StatusConflict},
+		{Title: "Not found", Code: http.StatusNotFound},
+		{Title: "Server error", Code: http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			calls := 0
+			handler := server.Idempotent(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(test.Code)
+			})
+
+			send := func(requestID string) *httptest.ResponseRecorder {
+				req, err := http.NewRequest(http.MethodPost, "/project/host/service_project/sp/application/web/firewall_rule/"+test.Title, strings.NewReader("{}"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", testToken("user@example.com"))
+				req.Header.Set("Idempotency-Key", "key")
+
+				rr := httptest.NewRecorder()
+				rr.Header().Set(helpers.RequestIDHeader, requestID)
+				handler.ServeHTTP(rr, req)
+				return rr
+			}
+
+			send("first")
+			rr := send("retry")
+			if (calls == 1) != test.Replayed || rr.Code != test.Code {
+				t.Errorf("Unexpected replay. Got %d calls and %d", calls, rr.Code)
+			}
+			if rr.Header().Get(helpers.RequestIDHeader) != "retry" {
+				t.Errorf("Expected the request ID of the retry. Got %s", rr.Header().Get(helpers.RequestIDHeader))
+			}
+		})
+	}
+}
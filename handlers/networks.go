@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// ListSharedNetworksHandler returns host project's networks shared with the service project given as query parameter
+func (s *Server) ListSharedNetworksHandler(w http.ResponseWriter, r *http.Request) {
+	project, _, _, _ := helpers.GetMuxVars(r)
+	serviceProject := r.URL.Query().Get("service_project")
+	if serviceProject == "" {
+		handleError(models.NewBadRequestError("Missing service_project query parameter"), w, r)
+		return
+	}
+
+	err := s.validateProjects(r, project, serviceProject)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	sharedNetworks, err := services.ListSharedNetworks(r.Context(), s.networkManagerOf(project), project, serviceProject)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(sharedNetworks)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"github.com/gorilla/mux"
+)
+
+// ListPriorityBandsHandler returns priority bands of the given host project
+func (s *Server) ListPriorityBandsHandler(w http.ResponseWriter, r *http.Request) {
+	_, err := services.GetUserEmailFromJWT(r.Context(), r.Header.Get("Authorization"), s.config.TrustedIssuers)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	priorityBands, err := services.ListPriorityBands(s.priorityBandStore, project)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(priorityBands)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// SavePriorityBandHandler creates or replaces the given priority band
+func (s *Server) SavePriorityBandHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.PriorityBand
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validateHostProject(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	body.Name = mux.Vars(r)["priority_band"]
+	err = services.SavePriorityBand(r.Context(), s.priorityBandStore, project, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// DeletePriorityBandHandler deletes the given priority band
+func (s *Server) DeletePriorityBandHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validateHostProject(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, _, _, _ := helpers.GetMuxVars(r)
+	err = services.DeletePriorityBand(r.Context(), s.priorityBandStore, project, mux.Vars(r)["priority_band"])
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPriorityBandAssignmentHandler returns the priority band of the given application
+func (s *Server) GetPriorityBandAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	assignment, err := services.GetPriorityBandAssignment(s.priorityBandStore, project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(assignment)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// AssignPriorityBandHandler sets the priority band of the given application
+func (s *Server) AssignPriorityBandHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.BandAssignment
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validateBandAssignment(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	body.Project, body.ServiceProject, body.Application, _ = helpers.GetMuxVars(r)
+	err = services.AssignPriorityBand(r.Context(), s.priorityBandStore, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
+
+// UnassignPriorityBandHandler removes the priority band of the given application, the default one applying
+func (s *Server) UnassignPriorityBandHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validateBandAssignment(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	err = s.priorityBandStore.DeleteAssignment(project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Bands are assigned by host project owners, to applications of its service projects
+func (s *Server) validateBandAssignment(r *http.Request) error {
+	err := s.validateHostProject(r)
+	if err != nil {
+		return err
+	}
+
+	project, serviceProject, _, _ := helpers.GetMuxVars(r)
+	return s.tracedGoogleClient(r.Context(), project).IsAServiceProjectOf(serviceProject, project)
+}
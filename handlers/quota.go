@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// GetQuotaHandler returns the quotas usage of the given application, of its service project and of the host project
+func (s *Server) GetQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, _ := helpers.GetMuxVars(r)
+	report, err := services.GetQuota(r.Context(), s.tracedManager(r.Context()), s.tracedGoogleClient(r.Context(), project), s.guardrailPolicy, project, serviceProject, application)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(report)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+)
+
+// EnableFirewallRuleHandler enables back the given rule
+func (s *Server) EnableFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	s.setRuleDisabledHandler(w, r, false)
+}
+
+// DisableFirewallRuleHandler disables the given rule, keeping it managed
+func (s *Server) DisableFirewallRuleHandler(w http.ResponseWriter, r *http.Request) {
+	s.setRuleDisabledHandler(w, r, true)
+}
+
+func (s *Server) setRuleDisabledHandler(w http.ResponseWriter, r *http.Request, disabled bool) {
+	err := s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+
+	// Ensure rule has not been modified since client read it
+	err = services.CheckIfMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	applicationRule, err := services.SetFirewallRuleDisabled(r.Context(), s.tracedManager(r.Context()), s.guardrailPolicy, project, serviceProject, application, rule, disabled)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	s.writeApplicationRule(w, r, applicationRule)
+}
+
+// SetRuleLoggingHandler switches logging of the given rule
+func (s *Server) SetRuleLoggingHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.LoggingRequest
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		handleError(models.NewBadRequestError("Fail to decode body"), w, r)
+		return
+	}
+
+	err = s.validate(r)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	project, serviceProject, application, rule := helpers.GetMuxVars(r)
+
+	// Ensure rule has not been modified since client read it
+	err = services.CheckIfMatch(r.Context(), s.tracedManager(r.Context()), project, serviceProject, application, rule, r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	applicationRule, err := services.SetFirewallRuleLogging(r.Context(), s.tracedManager(r.Context()), s.guardrailPolicy, project, serviceProject, application, rule, body)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	s.writeApplicationRule(w, r, applicationRule)
+}
+
+func (s *Server) writeApplicationRule(w http.ResponseWriter, r *http.Request, applicationRule *models.ApplicationRule) {
+	etag := services.ETag(applicationRule)
+	err := services.RelativePriorities(s.priorityBandStore, applicationRule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	res, err := json.Marshal(applicationRule)
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, string(res))
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/services"
+	"github.com/sirupsen/logrus"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// Clients gathers the Google clients of a Server
+type Clients struct {
+	FirewallRuleManager models.FirewallRuleManager
+	GoogleClient        models.GoogleClientInterface
+	InstanceManager     models.InstanceManager
+	NetworkManager      models.NetworkManager
+
+	// Credentials of host projects mapped to a service account, whose Google clients replace the ones above. None when nil
+	Credentials *models.CredentialResolver
+
+	// Errors building clients, by client. Failing clients are left nil, for readiness checks to report them
+	Errors map[string]error
+}
+
+// Server serves the API with its Google clients, stores and configuration
+type Server struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	manager         models.FirewallRuleManager
+	googleClient    models.GoogleClientInterface
+	instanceManager models.InstanceManager
+	networkManager  models.NetworkManager
+	credentials     *models.CredentialResolver
+	clientErrors    map[string]error
+
+	serviceCatalog  models.ServiceCatalog
+	guardrailPolicy models.GuardrailPolicy
+
+	addressGroupStore models.AddressGroupStore
+	dependencyStore   models.DependencyStore
+	priorityBandStore models.PriorityBandStore
+	idempotencyStore  models.IdempotencyStore
+
+	readinessChecker *services.ReadinessChecker
+}
+
+// NewGoogleClients returns clients reaching Google with default credentials, instrumented with metrics.
+// Host projects mapped to a service account are reached impersonating it. Rules of each host project are managed on its
+// configured backend, built on first use. Networks shared with service projects are cached, as they are resolved for each rule written
+func NewGoogleClients(c *config.Config) Clients {
+	clients := Clients{Errors: map[string]error{}}
+	failed := func(name string, err error) bool {
+		if err != nil {
+			logrus.WithField("go-err", err.Error()).Errorf("Fail to create %s client", name)
+			clients.Errors[name] = err
+		}
+		return err != nil
+	}
+
+	clients.Credentials = models.NewCredentialResolver(c.ServiceAccounts(), models.NewClientOptions, func(opts ...option.ClientOption) (*models.ProjectClients, error) {
+		projectClients, err := models.NewProjectClients(opts...)
+		if err != nil {
+			return nil, err
+		}
+		projectClients.Google = metrics.NewGoogleClient(projectClients.Google)
+		projectClients.Networks = models.NewNetworkCache(projectClients.Networks, time.Duration(c.Cache.Networks))
+		return projectClients, nil
+	})
+
+	targets := make(map[string]models.BackendTarget)
+	for _, hostProject := range c.HostProjects {
+		targets[hostProject.Project] = models.NewBackendTarget(hostProject)
+	}
+	clients.FirewallRuleManager = metrics.NewFirewallRuleManager(models.NewBackendResolver(clients.Credentials, targets, models.NewFirewallRuleManagerFactory(c.SecureTags)))
+
+	googleClient, err := models.NewGoogleClient()
+	if !failed("google", err) {
+		clients.GoogleClient = metrics.NewGoogleClient(googleClient)
+	}
+	instanceClient, err := models.NewInstanceClient()
+	if !failed("instances", err) {
+		clients.InstanceManager = instanceClient
+	}
+	networkClient, err := models.NewNetworkClient()
+	if !failed("networks", err) {
+		clients.NetworkManager = models.NewNetworkCache(networkClient, time.Duration(c.Cache.Networks))
+	}
+
+	return clients
+}
+
+// NewServer Server constructor. Stores are in-memory, and also saved to the state directory if any.
+// Priority bands set by configuration are served as is
+func NewServer(c *config.Config, clients Clients, logger *logrus.Logger) (*Server, error) {
+	serviceCatalog, err := services.LoadServiceCatalog(c.ServiceCatalogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	guardrailPolicy, err := services.LoadGuardrailPolicy(c.GuardrailPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	configuredPriorityBands, err := services.LoadPriorityBands(c.HostProjects)
+	if err != nil {
+		return nil, err
+	}
+	var configuredProjects []string
+	for _, hostProject := range c.HostProjects {
+		if c.ConfiguresPriorityBands(hostProject.Project) {
+			configuredProjects = append(configuredProjects, hostProject.Project)
+		}
+	}
+
+	var priorityBandStore models.PriorityBandStore = models.NewPriorityBandMemoryStore()
+
+	s := &Server{
+		config:            c,
+		logger:            logger,
+		manager:           clients.FirewallRuleManager,
+		googleClient:      clients.GoogleClient,
+		instanceManager:   clients.InstanceManager,
+		networkManager:    clients.NetworkManager,
+		credentials:       clients.Credentials,
+		clientErrors:      clients.Errors,
+		serviceCatalog:    serviceCatalog,
+		guardrailPolicy:   guardrailPolicy,
+		addressGroupStore: models.NewAddressGroupMemoryStore(),
+		dependencyStore:   models.NewDependencyMemoryStore(),
+		idempotencyStore:  models.NewIdempotencyMemoryStore(time.Duration(c.Cache.Idempotency)),
+	}
+	if c.StateDir != "" {
+		s.addressGroupStore, err = models.NewAddressGroupFileStore(filepath.Join(c.StateDir, "address_groups.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		s.dependencyStore, err = models.NewDependencyFileStore(filepath.Join(c.StateDir, "dependencies.json"))
+		if err != nil {
+			return nil, err
+		}
+
+		priorityBandStore, err = models.NewPriorityBandFileStore(filepath.Join(c.StateDir, "priority_bands.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.priorityBandStore = models.NewPriorityBandConfigStore(configuredPriorityBands, configuredProjects, priorityBandStore)
+	if clients.FirewallRuleManager != nil {
+		s.manager = metrics.NewManagedRuleCounter(managedNetworkRuleManager{FirewallRuleManager: clients.FirewallRuleManager, config: c})
+	}
+	if clients.NetworkManager != nil {
+		s.networkManager = managedNetworkManager{NetworkManager: clients.NetworkManager, config: c}
+	}
+	s.readinessChecker = s.newReadinessChecker()
+	return s, nil
+}
+
+// impersonates returns whether Google clients of given host project impersonate its service account
+func (s *Server) impersonates(project string) bool {
+	return s.credentials != nil && s.credentials.ServiceAccount(project) != ""
+}
+
+// Google client reaching given host project and its service projects
+func (s *Server) googleClientOf(project string) models.GoogleClientInterface {
+	if s.impersonates(project) {
+		return s.credentials.GoogleClient(project)
+	}
+	if s.googleClient == nil {
+		return unavailableClient{name: "google"}
+	}
+	return s.googleClient
+}
+
+// Instance manager reaching service projects of given host project
+func (s *Server) instanceManagerOf(project string) models.InstanceManager {
+	if s.impersonates(project) {
+		return s.credentials.InstanceManager(project)
+	}
+	if s.instanceManager == nil {
+		return unavailableClient{name: "instances"}
+	}
+	return s.instanceManager
+}
+
+// Network manager reaching given host project
+func (s *Server) networkManagerOf(project string) models.NetworkManager {
+	if s.impersonates(project) {
+		return managedNetworkManager{NetworkManager: s.credentials.NetworkManager(project), config: s.config}
+	}
+	if s.networkManager == nil {
+		return unavailableClient{name: "networks"}
+	}
+	return s.networkManager
+}
+
+// Default client which failed to be built, see readiness checks. Its calls fail with a 503 Service Unavailable.
+// Implements GoogleClientInterface, InstanceManager and NetworkManager
+type unavailableClient struct {
+	name string
+}
+
+func (c unavailableClient) err() error {
+	return models.NewServiceUnavailableError(fmt.Sprintf("Google %s client is unavailable", c.name))
+}
+
+func (c unavailableClient) IsProjectOwner(user string, projectID string) error {
+	return c.err()
+}
+
+func (c unavailableClient) IsAServiceProjectOf(projectA, projectB string) error {
+	return c.err()
+}
+
+func (c unavailableClient) ListServiceProjects(project string) ([]string, error) {
+	return nil, c.err()
+}
+
+func (c unavailableClient) ListInstancesWithTag(project, tag string) ([]*compute.Instance, error) {
+	return nil, c.err()
+}
+
+func (c unavailableClient) GetInstance(project, name string) (*compute.Instance, error) {
+	return nil, c.err()
+}
+
+func (c unavailableClient) SetInstanceTags(project string, instance *compute.Instance, tags []string) error {
+	return c.err()
+}
+
+func (c unavailableClient) ListSharedNetworks(project, serviceProject string) ([]models.Network, error) {
+	return nil, c.err()
+}
+
+// Logger returns the logger of the server, base of its requests loggers
+func (s *Server) Logger() *logrus.Logger {
+	return s.logger
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
+)
+
+// newTestServer returns a server on in-memory clients, where user@example.com owns service project "sp" of host
+// project "host", sharing network "lh-network"
+func newTestServer(t *testing.T) (*Server, *fakes.FirewallRuleDummyClient) {
+	t.Helper()
+
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	server, err := NewServer(config.Default(func(string) string { return "" }), Clients{
+		FirewallRuleManager: manager,
+		GoogleClient: &fakes.GoogleDummyClient{
+			Owners:       map[string][]string{"host": []string{"admin@example.com"}, "sp": []string{"user@example.com"}},
+			HostProjects: map[string]string{"sp": "host"},
+		},
+		InstanceManager: &fakes.InstanceDummyClient{},
+		NetworkManager: &fakes.NetworkDummyClient{Networks: map[string][]models.Network{
+			"host/sp": []models.Network{
+				models.Network{Name: "lh-network", SelfLink: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"},
+			},
+		}},
+	}, logrus.StandardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, manager
+}
+
+// testToken returns a bearer token of the given verified user. Signature is not checked
+func testToken(email string) string {
+	claims := fmt.Sprintf(`{"iss":"https://accounts.google.com","email":"%s","email_verified":true}`, email)
+	return "Bearer header." + base64.RawStdEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+func TestImpersonatedHostProjectClients(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.config.HostProjects = []config.HostProject{
+		config.HostProject{Project: "host", ServiceAccount: "sa@host.iam.gserviceaccount.com", Networks: []string{"lh-network"}},
+		config.HostProject{Project: "other-host"},
+	}
+	server.credentials = models.NewCredentialResolver(server.config.ServiceAccounts(), func(serviceAccount string) ([]option.ClientOption, error) {
+		return nil, nil
+	}, func(opts ...option.ClientOption) (*models.ProjectClients, error) {
+		return &models.ProjectClients{
+			Google:    &fakes.GoogleDummyClient{Owners: map[string][]string{"sp": []string{"impersonated@example.com"}}},
+			Instances: &fakes.InstanceDummyClient{},
+			Networks: &fakes.NetworkDummyClient{Networks: map[string][]models.Network{
+				"host/sp": []models.Network{models.Network{Name: "lh-network"}, models.Network{Name: "other-network"}},
+			}},
+		}, nil
+	})
+
+	// Clients of host projects mapped to a service account impersonate it, others use default credentials
+	if err := server.googleClientOf("host").IsProjectOwner("impersonated@example.com", "sp"); err != nil {
+		t.Errorf("Expected impersonated client. Got %v", err)
+	}
+	if err := server.googleClientOf("other-host").IsProjectOwner("user@example.com", "sp"); err != nil {
+		t.Errorf("Expected default client. Got %v", err)
+	}
+
+	networks, err := server.networkManagerOf("host").ListSharedNetworks("host", "sp")
+	if err != nil || len(networks) != 1 || networks[0].Name != "lh-network" {
+		t.Errorf("Expected managed networks of the impersonated client. Got %v, %v", networks, err)
+	}
+}
+
+func TestUnavailableClients(t *testing.T) {
+	server := &Server{config: config.Default(func(string) string { return "" })}
+
+	// Default clients failing to be built answer a 503 instead of panicking
+	assertUnavailable := func(err error) {
+		t.Helper()
+		if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected a service unavailable error. Got %v", err)
+		}
+	}
+	assertUnavailable(server.googleClientOf("host").IsProjectOwner("user@example.com", "sp"))
+	_, err := server.instanceManagerOf("host").GetInstance("sp", "vm")
+	assertUnavailable(err)
+	_, err = server.networkManagerOf("host").ListSharedNetworks("host", "sp")
+	assertUnavailable(err)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+)
+
+// ListServicesHandler returns named services usable in rules instead of protocols and ports
+func (s *Server) ListServicesHandler(w http.ResponseWriter, r *http.Request) {
+	res, err := json.Marshal(models.Services{Services: s.serviceCatalog.List()})
+	if err != nil {
+		handleError(err, w, r)
+		return
+	}
+
+	fmt.Fprint(w, string(res))
+}
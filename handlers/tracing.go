@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/adeo/iwc-gcp-firewall-api/tracing"
+)
+
+// Firewall rule manager tracing its calls as children of the span of the given context
+func (s *Server) tracedManager(ctx context.Context) models.FirewallRuleManager {
+	return tracing.NewFirewallRuleManager(ctx, s.manager)
+}
+
+// Google client of the given host project tracing its calls as children of the span of the given context
+func (s *Server) tracedGoogleClient(ctx context.Context, project string) models.GoogleClientInterface {
+	return tracing.NewGoogleClient(ctx, s.googleClientOf(project))
+}
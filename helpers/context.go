@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID correlating logs of a request, accepted from callers or generated
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs accepted from callers. Others are replaced, not to forge log lines
+var requestIDFormat = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type loggerKey struct{}
+
+type userKey struct{}
+
+// RequestID returns the given caller's request ID if valid, or a new random one
+func RequestID(id string) string {
+	if requestIDFormat.MatchString(id) {
+		return id
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithLogger returns a copy of the given context carrying the given logger
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithUserSlot returns a copy of the given context able to carry the user of the request, once authenticated
+func WithUserSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userKey{}, new(string))
+}
+
+// SetUser records the authenticated user of the request, if the given context has a user slot
+func SetUser(ctx context.Context, user string) {
+	if slot, ok := ctx.Value(userKey{}).(*string); ok {
+		*slot = user
+	}
+}
+
+// Logger returns the logger carried by the given context, or a standard logger if none. Logs carry the user of the
+// request once authenticated
+func Logger(ctx context.Context) *logrus.Entry {
+	logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry)
+	if !ok {
+		logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if slot, ok := ctx.Value(userKey{}).(*string); ok && *slot != "" {
+		return logger.WithField("user", *slot)
+	}
+	return logger
+}
@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		Title    string
+		Header   string
+		Accepted bool
+	}{
+		{Title: "Caller's ID", Header: "3f2c1a-lb.42", Accepted: true},
+		{Title: "Missing", Header: ""},
+		{Title: "Forged log line", Header: "abc\nlevel=error"},
+		{Title: "Too long", Header: strings.Repeat("a", 129)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			id := RequestID(test.Header)
+			if (id == test.Header) != test.Accepted {
+				t.Errorf("Expected caller's ID to be accepted: %v. Got '%s'", test.Accepted, id)
+			}
+			if !requestIDFormat.MatchString(id) {
+				t.Errorf("Invalid request ID '%s'", id)
+			}
+		})
+	}
+
+	if RequestID("") == RequestID("") {
+		t.Error("Generated request IDs should be unique")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	if Logger(context.Background()) == nil {
+		t.Error("Expected a standard logger for a context without logger")
+	}
+
+	ctx := WithLogger(context.Background(), logrus.WithField("request_id", "42"))
+	if Logger(ctx).Data["request_id"] != "42" {
+		t.Errorf("Expected the logger of the context, got fields %v", Logger(ctx).Data)
+	}
+}
+
+func TestSetUser(t *testing.T) {
+	ctx := WithLogger(context.Background(), logrus.WithField("request_id", "42"))
+	SetUser(ctx, "user@example.com")
+	if _, ok := Logger(ctx).Data["user"]; ok {
+		t.Error("Expected no user without user slot")
+	}
+
+	ctx = WithUserSlot(ctx)
+	if _, ok := Logger(ctx).Data["user"]; ok {
+		t.Error("Expected no user before authentication")
+	}
+
+	SetUser(ctx, "user@example.com")
+	if Logger(ctx).Data["user"] != "user@example.com" || Logger(ctx).Data["request_id"] != "42" {
+		t.Errorf("Expected the user and the request ID, got fields %v", Logger(ctx).Data)
+	}
+}
@@ -1,20 +1,74 @@
 package helpers
 
 import (
+	"encoding/json"
 	"log"
-	"os"
 
 	stackdriver "github.com/TV4/logrus-stackdriver-formatter"
+	"github.com/adeo/iwc-gcp-firewall-api/config"
 	"github.com/sirupsen/logrus"
 )
 
-// InitLogger initializes logrus to be compatible with google stackdriver
-func InitLogger() {
-	logrus.SetLevel(logrus.DebugLevel)
-	// Set logger as Stackdriver compliant when runtime is GCP
-	// https://cloud.google.com/run/docs/reference/container-contract#env-vars
-	if os.Getenv("K_SERVICE") != "" {
-		logrus.SetFormatter(stackdriver.NewFormatter())
+// InitLogger initializes logrus with given level and format, text, json or stackdriver
+func InitLogger(level logrus.Level, format string) {
+	logrus.SetLevel(level)
+	switch format {
+	case config.LogFormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case config.LogFormatStackdriver:
+		logrus.SetFormatter(httpRequestFormatter{stackdriver.NewFormatter()})
 		log.SetOutput(logrus.StandardLogger().Writer())
 	}
 }
+
+// HTTPRequestField is the log field describing a served request
+const HTTPRequestField = "httpRequest"
+
+// HTTPRequest describes a served request in Stackdriver format
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type HTTPRequest struct {
+	RequestMethod string `json:"requestMethod"`
+	RequestURL    string `json:"requestUrl"`
+	Status        int    `json:"status"`
+	ResponseSize  string `json:"responseSize"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	Latency       string `json:"latency"`
+	Protocol      string `json:"protocol"`
+}
+
+// Stackdriver formatter moving the served request field to the root of entries, where Stackdriver expects it
+type httpRequestFormatter struct {
+	logrus.Formatter
+}
+
+func (f httpRequestFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	request, ok := e.Data[HTTPRequestField]
+	if !ok {
+		return f.Formatter.Format(e)
+	}
+
+	entry := e.WithFields(logrus.Fields{})
+	entry.Level = e.Level
+	entry.Message = e.Message
+	delete(entry.Data, HTTPRequestField)
+
+	b, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		return nil, err
+	}
+	fields[HTTPRequestField] = request
+
+	b, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -1,15 +1,48 @@
 package helpers
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/adeo/iwc-gcp-firewall-api/config"
 	"github.com/sirupsen/logrus"
 )
 
 func TestInitLogger(t *testing.T) {
-	InitLogger()
+	InitLogger(logrus.DebugLevel, config.LogFormatText)
 
 	if logrus.GetLevel() != logrus.DebugLevel {
 		t.Errorf("Log level should be logrus.DebugLevel. Got '%v' want '%v'", logrus.GetLevel(), logrus.DebugLevel)
 	}
 }
+
+func TestHTTPRequestFormatter(t *testing.T) {
+	formatter := httpRequestFormatter{&logrus.JSONFormatter{}}
+	entry := logrus.WithFields(logrus.Fields{
+		"request_id":     "42",
+		HTTPRequestField: HTTPRequest{RequestMethod: "GET", Status: 200},
+	})
+	entry.Message = "GET / 200"
+
+	b, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(b, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, ok := fields[HTTPRequestField].(map[string]interface{})
+	if !ok || request["requestMethod"] != "GET" || request["status"] != 200.0 {
+		t.Errorf("Expected httpRequest at the root of the entry, got %s", b)
+	}
+	if fields["request_id"] != "42" || fields["msg"] != "GET / 200" {
+		t.Errorf("Expected other fields to be kept, got %s", b)
+	}
+	if _, ok := entry.Data[HTTPRequestField]; !ok {
+		t.Error("Formatted entry should not be modified")
+	}
+}
@@ -0,0 +1,191 @@
+// Package fakes provides in-memory implementations of Google clients, for tests
+package fakes
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/compute/v1"
+)
+
+// FirewallRuleDummyClient provides primitives to collect rules from in-memory rules list
+type FirewallRuleDummyClient struct {
+	mu    sync.Mutex
+	Rules map[string][]*compute.Firewall
+	// Errors returned when deleting rules of given names
+	DeleteErrors map[string]error
+}
+
+// NewFirewallRuleDummyClient FirewallRuleDummyClient constructor
+func NewFirewallRuleDummyClient() (*FirewallRuleDummyClient, error) {
+	manager := FirewallRuleDummyClient{}
+	manager.Rules = make(map[string][]*compute.Firewall)
+	return &manager, nil
+}
+
+// ListFirewallRule returns given project's firewall rule
+func (f *FirewallRuleDummyClient) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if value, ok := f.Rules[project]; ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("Project not found")
+}
+
+// GetFirewallRule returns firewall rule matching given project and name
+func (f *FirewallRuleDummyClient) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, rule := range f.Rules[project] {
+		if rule.Name == name {
+			return rule, nil
+		}
+	}
+	return nil, models.NewNotFoundError()
+}
+
+// CreateFirewallRule create given firewall rule on given project
+func (f *FirewallRuleDummyClient) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range f.Rules[project] {
+		if r.Name == rule.Name {
+			return nil, fmt.Errorf("Rule already exists")
+		}
+	}
+
+	f.Rules[project] = append(f.Rules[project], rule)
+	return rule, nil
+}
+
+// UpdateFirewallRule replace the firewall rule matching given rule's name on given project
+func (f *FirewallRuleDummyClient) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, r := range f.Rules[project] {
+		if r.Name == rule.Name {
+			f.Rules[project][i] = rule
+			return rule, nil
+		}
+	}
+	return nil, models.NewNotFoundError()
+}
+
+// DeleteFirewallRule delete firewall rule matching given project and name
+func (f *FirewallRuleDummyClient) DeleteFirewallRule(project, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err, ok := f.DeleteErrors[name]; ok {
+		return err
+	}
+
+	rules := f.Rules[project]
+	for i, rule := range rules {
+		if rule.Name == name {
+			// Delete matching route
+			rules[i] = rules[len(rules)-1]
+			f.Rules[project] = rules[:len(rules)-1]
+			return nil
+		}
+	}
+	return models.NewNotFoundError()
+}
+
+// GetFirewallQuota returns the firewall rules quota of given project
+func (f *FirewallRuleDummyClient) GetFirewallQuota(project string) (*compute.Quota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &compute.Quota{Metric: "FIREWALLS", Limit: 500, Usage: float64(len(f.Rules[project]))}, nil
+}
+
+// NetworkDummyClient provides primitives to collect shared networks from in-memory networks list
+type NetworkDummyClient struct {
+	Networks map[string][]models.Network
+}
+
+// ListSharedNetworks returns networks shared by given host project with given service project
+func (c *NetworkDummyClient) ListSharedNetworks(project, serviceProject string) ([]models.Network, error) {
+	return c.Networks[project+"/"+serviceProject], nil
+}
+
+// InstanceDummyClient provides primitives to collect instances from in-memory instances list
+type InstanceDummyClient struct {
+	Instances map[string][]*compute.Instance
+}
+
+// ListInstancesWithTag returns instances of given project carrying given network tag
+func (c *InstanceDummyClient) ListInstancesWithTag(project, tag string) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+	for _, instance := range c.Instances[project] {
+		for _, t := range instance.Tags.Items {
+			if t == tag {
+				instances = append(instances, instance)
+			}
+		}
+	}
+	return instances, nil
+}
+
+// GetInstance returns the instance matching given project and name
+func (c *InstanceDummyClient) GetInstance(project, name string) (*compute.Instance, error) {
+	for _, instance := range c.Instances[project] {
+		if instance.Name == name {
+			return instance, nil
+		}
+	}
+	return nil, models.NewNotFoundError()
+}
+
+// SetInstanceTags replaces network tags of given instance
+func (c *InstanceDummyClient) SetInstanceTags(project string, instance *compute.Instance, tags []string) error {
+	instance.Tags = &compute.Tags{Items: tags}
+	return nil
+}
+
+// GoogleDummyClient checks permissions from in-memory owners and service projects
+type GoogleDummyClient struct {
+	// Owners by project
+	Owners map[string][]string
+	// Host project by service project
+	HostProjects map[string]string
+}
+
+// IsProjectOwner return if a given user is owner of the given project
+func (c *GoogleDummyClient) IsProjectOwner(user string, projectID string) error {
+	for _, owner := range c.Owners[projectID] {
+		if owner == user {
+			return nil
+		}
+	}
+	return models.NewForbiddenError(fmt.Sprintf("User [user:%s] does not have permission to access project [%s]. The resource may not exist or you don't have roles/owner", user, projectID))
+}
+
+// IsAServiceProjectOf return if projectA is a service project of projectB
+func (c *GoogleDummyClient) IsAServiceProjectOf(projectA, projectB string) error {
+	if c.HostProjects[projectA] == projectB {
+		return nil
+	}
+	return models.NewForbiddenError(fmt.Sprintf("Project [%s] is not a [%s]'s service project or it may not exist", projectA, projectB))
+}
+
+// ListServiceProjects returns service projects of given host project, sorted
+func (c *GoogleDummyClient) ListServiceProjects(project string) ([]string, error) {
+	var serviceProjects []string
+	for serviceProject, hostProject := range c.HostProjects {
+		if hostProject == project {
+			serviceProjects = append(serviceProjects, serviceProject)
+		}
+	}
+
+	sort.Strings(serviceProjects)
+	return serviceProjects, nil
+}
@@ -0,0 +1,96 @@
+package fakes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// FirewallPolicyServer fakes the compute API of a firewall policy, reachable both as a global network firewall policy
+// of any project and as a hierarchical firewall policy
+type FirewallPolicyServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	policy string
+	rules  []*compute.FirewallPolicyRule
+	// Calls made to change rules, as "<method> <priority>"
+	Calls []string
+}
+
+// NewFirewallPolicyServer FirewallPolicyServer constructor, serving the policy of given name with given rules. Callers close it
+func NewFirewallPolicyServer(policy string, rules []*compute.FirewallPolicyRule) *FirewallPolicyServer {
+	s := &FirewallPolicyServer{policy: policy, rules: rules}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Rules returns the rules of the policy
+func (s *FirewallPolicyServer) Rules() []*compute.FirewallPolicyRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*compute.FirewallPolicyRule(nil), s.rules...)
+}
+
+func (s *FirewallPolicyServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	var rest string
+	switch {
+	case strings.HasPrefix(path, "locations/global/firewallPolicies/"):
+		rest = strings.TrimPrefix(path, "locations/global/firewallPolicies/")
+	case strings.HasPrefix(path, "projects/") && strings.Contains(path, "/global/firewallPolicies/"):
+		rest = path[strings.Index(path, "/global/firewallPolicies/")+len("/global/firewallPolicies/"):]
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	parts := strings.SplitN(rest, "/", 2)
+	if parts[0] != s.policy {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		json.NewEncoder(w).Encode(compute.FirewallPolicy{Name: s.policy, Rules: s.rules})
+		return
+	}
+
+	var rule compute.FirewallPolicyRule
+	json.NewDecoder(r.Body).Decode(&rule)
+	priority, err := strconv.ParseInt(r.URL.Query().Get("priority"), 10, 64)
+	if err != nil {
+		priority = rule.Priority
+	}
+	s.Calls = append(s.Calls, parts[1]+" "+strconv.FormatInt(priority, 10))
+
+	switch parts[1] {
+	case "addRule":
+		s.rules = append(s.rules, &rule)
+	case "patchRule":
+		for i, r := range s.rules {
+			if r.Priority == priority {
+				s.rules[i] = &rule
+			}
+		}
+	case "removeRule":
+		for i, r := range s.rules {
+			if r.Priority == priority {
+				s.rules = append(s.rules[:i], s.rules[i+1:]...)
+				break
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(compute.Operation{})
+}
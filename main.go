@@ -3,26 +3,143 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
+	"github.com/adeo/iwc-gcp-firewall-api/config"
 	"github.com/adeo/iwc-gcp-firewall-api/handlers"
 	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/metrics"
+	"github.com/adeo/iwc-gcp-firewall-api/tracing"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Correlate logs of a request with an ID, accepted from the caller or generated, and with its target and caller,
+// once authenticated by the handler
+func requestIDMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := helpers.RequestID(r.Header.Get(helpers.RequestIDHeader))
+			w.Header().Set(helpers.RequestIDHeader, requestID)
+
+			fields := logrus.Fields{"request_id": requestID}
+			project, serviceProject, application, rule := helpers.GetMuxVars(r)
+			for name, value := range map[string]string{"project": project, "service_project": serviceProject, "application": application, "rule": rule} {
+				if value != "" {
+					fields[name] = value
+				}
+			}
+
+			ctx := helpers.WithUserSlot(helpers.WithLogger(r.Context(), logger.WithFields(fields)))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 // Enable http access log on testing
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logrus.WithFields(logrus.Fields{
-			"method":      r.Method,
-			"request_uri": r.RequestURI,
-			"user_agent":  r.UserAgent(),
-		}).Printf("%s %s", r.Method, r.RequestURI)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+
+		helpers.Logger(r.Context()).WithField(helpers.HTTPRequestField, helpers.HTTPRequest{
+			RequestMethod: r.Method,
+			RequestURL:    r.RequestURI,
+			Status:        rec.status,
+			ResponseSize:  strconv.Itoa(rec.bytes),
+			UserAgent:     r.UserAgent(),
+			RemoteIP:      remoteIP,
+			Referer:       r.Referer(),
+			Latency:       fmt.Sprintf("%.9fs", time.Since(start).Seconds()),
+			Protocol:      r.Proto,
+		}).Printf("%s %s %d", r.Method, r.RequestURI, rec.status)
+	})
+}
+
+// Records the status code and the body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Path template of the route matching given request
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unknown"
+}
+
+// Count requests and observe their latency by route and status
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := routeTemplate(r)
+		code := strconv.Itoa(rec.status)
+		metrics.RequestsTotal.WithLabelValues(r.Method, route, code).Inc()
+		metrics.RequestDuration.WithLabelValues(r.Method, route, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Trace requests in a server span, child of the caller's one sent in traceparent or X-Cloud-Trace-Context header
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.HTTPTarget(r.RequestURI),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
 	})
 }
 
@@ -35,36 +152,87 @@ func contentTypeMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	// Init logger to be Stackdriver compliant
-	helpers.InitLogger()
+	// Load configuration from CONFIG_FILE and environment
+	cfg, err := config.Load(os.Getenv)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	// Init logger, Stackdriver compliant when runtime is GCP
+	level, _ := logrus.ParseLevel(cfg.Log.Level)
+	helpers.InitLogger(level, cfg.Log.Format)
 
-	// Set port to listen to
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+
+	server, err := handlers.NewServer(cfg, handlers.NewGoogleClients(cfg), logrus.StandardLogger())
+	if err != nil {
+		logrus.Fatalln(err)
 	}
 
 	r := mux.NewRouter().StrictSlash(true)
-	if os.Getenv("CI") == "" {
+	r.Use(requestIDMiddleware(server.Logger()))
+	if cfg.AccessLog {
 		r.Use(loggingMiddleware)
 	}
+	r.Use(metricsMiddleware)
+	r.Use(tracingMiddleware)
 
 	// Define routers
 	projectRouter := r.PathPrefix("/project/{project}").Subrouter()
 	serviceProjectRouter := projectRouter.PathPrefix("/service_project/{service_project}").Subrouter()
 	applicationRouter := serviceProjectRouter.PathPrefix("/application/{application}").Subrouter()
 	ruleRouter := applicationRouter.PathPrefix("/firewall_rule/{rule}").Subrouter()
+	projectRouter.Use(server.HostProjectMiddleware)
+
+	// Discovery routes
+	r.Path("/projects").Methods(http.MethodGet).HandlerFunc(server.ListHostProjectsHandler)
+	projectRouter.Path("/networks").Methods(http.MethodGet).HandlerFunc(server.ListSharedNetworksHandler)
+
+	// Host project administration routes
+	projectRouter.Path("/analysis").Methods(http.MethodGet).HandlerFunc(server.AnalyzeProjectRulesHandler)
+	projectRouter.Path("/address_groups").Methods(http.MethodGet).HandlerFunc(server.ListAddressGroupsHandler)
+	projectRouter.Path("/address_groups/{address_group}").Methods(http.MethodGet).HandlerFunc(server.GetAddressGroupHandler)
+	projectRouter.Path("/address_groups/{address_group}").Methods(http.MethodPut).HandlerFunc(server.SaveAddressGroupHandler)
+	projectRouter.Path("/address_groups/{address_group}").Methods(http.MethodDelete).HandlerFunc(server.DeleteAddressGroupHandler)
+
+	projectRouter.Path("/priority_bands").Methods(http.MethodGet).HandlerFunc(server.ListPriorityBandsHandler)
+	projectRouter.Path("/priority_bands/{priority_band}").Methods(http.MethodPut).HandlerFunc(server.SavePriorityBandHandler)
+	projectRouter.Path("/priority_bands/{priority_band}").Methods(http.MethodDelete).HandlerFunc(server.DeletePriorityBandHandler)
+	applicationRouter.Path("/priority_band").Methods(http.MethodGet).HandlerFunc(server.GetPriorityBandAssignmentHandler)
+	applicationRouter.Path("/priority_band").Methods(http.MethodPut).HandlerFunc(server.AssignPriorityBandHandler)
+	applicationRouter.Path("/priority_band").Methods(http.MethodDelete).HandlerFunc(server.UnassignPriorityBandHandler)
 
 	// Manage sets of rules routes
-	applicationRouter.Path("").Methods(http.MethodGet).HandlerFunc(handlers.ListFirewallRuleHandler)
+	applicationRouter.Path("").Methods(http.MethodGet).HandlerFunc(server.ListFirewallRuleHandler)
+	applicationRouter.Path("").Methods(http.MethodDelete).HandlerFunc(server.DeleteApplicationFirewallRuleHandler)
+	applicationRouter.Path("/clone").Methods(http.MethodPost).HandlerFunc(server.Idempotent(server.CloneFirewallRuleHandler))
+	applicationRouter.Path("/analysis").Methods(http.MethodGet).HandlerFunc(server.AnalyzeApplicationRulesHandler)
+	applicationRouter.Path("/quota").Methods(http.MethodGet).HandlerFunc(server.GetQuotaHandler)
+	applicationRouter.Path("/connectivity").Methods(http.MethodPost).HandlerFunc(server.CheckConnectivityHandler)
+	applicationRouter.Path("/firewall_rules:batch").Methods(http.MethodPost).HandlerFunc(server.Idempotent(server.BatchCreateFirewallRuleHandler))
 
 	// Manage a specific rule
-	ruleRouter.Path("").Methods(http.MethodPost).HandlerFunc(handlers.CreateFirewallRuleHandler)
-	ruleRouter.Path("").Methods(http.MethodGet).HandlerFunc(handlers.GetFirewallRuleHandler)
-	ruleRouter.Path("").Methods(http.MethodDelete).HandlerFunc(handlers.DeleteFirewallRuleHandler)
+	ruleRouter.Path("").Methods(http.MethodPost).HandlerFunc(server.Idempotent(server.CreateFirewallRuleHandler))
+	ruleRouter.Path("").Methods(http.MethodGet).HandlerFunc(server.GetFirewallRuleHandler)
+	ruleRouter.Path("").Methods(http.MethodDelete).HandlerFunc(server.DeleteFirewallRuleHandler)
+	ruleRouter.Path("/enable").Methods(http.MethodPost).HandlerFunc(server.EnableFirewallRuleHandler)
+	ruleRouter.Path("/disable").Methods(http.MethodPost).HandlerFunc(server.DisableFirewallRuleHandler)
+	ruleRouter.Path("/logging").Methods(http.MethodPut).HandlerFunc(server.SetRuleLoggingHandler)
+	ruleRouter.Path("/rename").Methods(http.MethodPost).HandlerFunc(server.RenameFirewallRuleHandler)
+	ruleRouter.Path("/rename:complete").Methods(http.MethodPost).HandlerFunc(server.CompleteRenameHandler)
+	ruleRouter.Path("/targets").Methods(http.MethodGet).HandlerFunc(server.ListRuleTargetsHandler)
+	ruleRouter.Path("/targets/{instance}").Methods(http.MethodPut).HandlerFunc(server.AttachRuleTargetHandler)
+	ruleRouter.Path("/targets/{instance}").Methods(http.MethodDelete).HandlerFunc(server.DetachRuleTargetHandler)
 
 	// Other endpoints routes
+	r.Path("/services").Methods(http.MethodGet).HandlerFunc(server.ListServicesHandler)
+	r.Path("/metrics").Methods(http.MethodGet).Handler(promhttp.Handler())
 	r.Path("/_health").Methods(http.MethodGet).HandlerFunc(handlers.HealthCheckHandler)
+	r.Path("/_config").Methods(http.MethodGet).HandlerFunc(server.GetConfigHandler)
+	r.Path("/_ready").Methods(http.MethodGet).HandlerFunc(server.ReadinessCheckHandler)
 
 	// Override default error handlers
 	r.MethodNotAllowedHandler = http.HandlerFunc(handlers.MethodNotAllowedHandler)
@@ -73,17 +241,17 @@ func main() {
 	r.Use(contentTypeMiddleware)
 
 	srv := http.Server{
-		Addr: fmt.Sprintf(":%s", port),
+		Addr: cfg.ListenAddress,
 		// Good practice to set timeouts to avoid Slowloris attacks.
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
+		WriteTimeout: time.Duration(cfg.Timeouts.Write),
+		ReadTimeout:  time.Duration(cfg.Timeouts.Read),
+		IdleTimeout:  time.Duration(cfg.Timeouts.Idle),
 		Handler:      r,
 	}
 
 	// Run our server in a goroutine so that it doesn't block.
 	go func() {
-		logrus.Printf("Listening on port %s", port)
+		logrus.Printf("Listening on %s", cfg.ListenAddress)
 		if err := srv.ListenAndServe(); err != nil {
 			logrus.Println(err)
 		}
@@ -95,9 +263,10 @@ func main() {
 	<-c
 
 	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeouts.Shutdown))
 	defer cancel()
 	srv.Shutdown(ctx)
+	shutdownTracing(ctx)
 	logrus.Println("Shutting down server")
 	os.Exit(0)
 }
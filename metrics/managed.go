@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/compute/v1"
+)
+
+// ManagedRuleCounter records the count of managed rules of each host project from its listings. Implements models.FirewallRuleManager.
+// It wraps the rules seen by clients, so that rules hidden from them, such as the ones of networks not managed, are not counted
+type ManagedRuleCounter struct {
+	models.FirewallRuleManager
+}
+
+// NewManagedRuleCounter ManagedRuleCounter constructor
+func NewManagedRuleCounter(next models.FirewallRuleManager) ManagedRuleCounter {
+	return ManagedRuleCounter{FirewallRuleManager: next}
+}
+
+// ListFirewallRule returns given project's firewall rule, and records the count of managed ones
+func (c ManagedRuleCounter) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	rules, err := c.FirewallRuleManager.ListFirewallRule(project)
+	if err != nil {
+		return nil, err
+	}
+
+	managed := 0
+	for _, rule := range rules {
+		if isManaged(rule) {
+			managed++
+		}
+	}
+	ManagedRules.WithLabelValues(project).Set(float64(managed))
+	return rules, nil
+}
+
+// isManaged tells whether given rule is managed by this API. Managed rules target their own name
+func isManaged(rule *compute.Firewall) bool {
+	return len(rule.TargetTags) > 0 && rule.TargetTags[0] == rule.Name
+}
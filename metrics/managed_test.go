@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestManagedRuleCounter(t *testing.T) {
+	next := &fakeFirewallRuleManager{rules: map[string][]*compute.Firewall{
+		"host": []*compute.Firewall{
+			&compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https"}},
+			&compute.Firewall{Name: "sp-api-https", TargetTags: []string{"sp-api-https", "legacy"}},
+			&compute.Firewall{Name: "default-allow-internal"},
+			&compute.Firewall{Name: "manual", TargetTags: []string{"web"}},
+		},
+	}}
+	counter := NewManagedRuleCounter(next)
+
+	_, err := counter.ListFirewallRule("host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if managed := testutil.ToFloat64(ManagedRules.WithLabelValues("host")); managed != 2 {
+		t.Errorf("Expected 2 managed rules, got %v", managed)
+	}
+
+	// Writes are counted by the next listing only
+	_, err = counter.CreateFirewallRule("host", &compute.Firewall{Name: "sp-web-ssh", TargetTags: []string{"sp-web-ssh"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if managed := testutil.ToFloat64(ManagedRules.WithLabelValues("host")); managed != 2 {
+		t.Errorf("Expected 2 managed rules before listing, got %v", managed)
+	}
+	counter.ListFirewallRule("host")
+	if managed := testutil.ToFloat64(ManagedRules.WithLabelValues("host")); managed != 3 {
+		t.Errorf("Expected 3 managed rules, got %v", managed)
+	}
+
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Authorization denial reasons
+const (
+	DenialInvalidToken      = "invalid_token"
+	DenialNotOwner          = "not_owner"
+	DenialNotServiceProject = "not_service_project"
+	DenialNotHostOwner      = "not_host_owner"
+	DenialNotAdmin          = "not_admin"
+	DenialUnmanagedProject  = "unmanaged_project"
+)
+
+const namespace = "gcp_firewall_api"
+
+var (
+	// RequestsTotal counts served requests by route and status
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Count of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// RequestDuration observes served requests latencies by route and status
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	// UpstreamDuration observes Google calls latencies by interface and method
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_call_duration_seconds",
+		Help:      "Latency of Google calls by interface and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"interface", "method"})
+
+	// UpstreamErrors counts failed Google calls by interface and method
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_call_errors_total",
+		Help:      "Count of failed Google calls by interface and method.",
+	}, []string{"interface", "method"})
+
+	// AuthorizationDenials counts rejected callers by reason
+	AuthorizationDenials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authorization_denials_total",
+		Help:      "Count of rejected callers by reason.",
+	}, []string{"reason"})
+
+	// ManagedRules reports rules managed by this API by host project, as seen by its last listing
+	ManagedRules = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "managed_rules",
+		Help:      "Count of rules managed by this API by host project.",
+	}, []string{"project"})
+)
+
+// DenyAuthorization counts a rejected caller, for the given reason
+func DenyAuthorization(reason string) {
+	AuthorizationDenials.WithLabelValues(reason).Inc()
+}
+
+// observeUpstream records the latency of a Google call started at given time, and its failure if any
+func observeUpstream(iface, method string, start time.Time, err error) {
+	UpstreamDuration.WithLabelValues(iface, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		UpstreamErrors.WithLabelValues(iface, method).Inc()
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/compute/v1"
+)
+
+// FirewallRuleManager instruments calls of a models.FirewallRuleManager. Implements models.FirewallRuleManager
+type FirewallRuleManager struct {
+	next models.FirewallRuleManager
+}
+
+// NewFirewallRuleManager FirewallRuleManager constructor
+func NewFirewallRuleManager(next models.FirewallRuleManager) *FirewallRuleManager {
+	return &FirewallRuleManager{next: next}
+}
+
+// ListFirewallRule returns given project's firewall rule
+func (m *FirewallRuleManager) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	start := time.Now()
+	rules, err := m.next.ListFirewallRule(project)
+	observeUpstream("FirewallRuleManager", "ListFirewallRule", start, err)
+	return rules, err
+}
+
+// GetFirewallRule returns firewall rule matching given project and name
+func (m *FirewallRuleManager) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	start := time.Now()
+	rule, err := m.next.GetFirewallRule(project, name)
+	observeUpstream("FirewallRuleManager", "GetFirewallRule", start, err)
+	return rule, err
+}
+
+// CreateFirewallRule create given firewall rule on given project
+func (m *FirewallRuleManager) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	start := time.Now()
+	created, err := m.next.CreateFirewallRule(project, rule)
+	observeUpstream("FirewallRuleManager", "CreateFirewallRule", start, err)
+	return created, err
+}
+
+// UpdateFirewallRule replace the firewall rule matching given rule's name on given project
+func (m *FirewallRuleManager) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	start := time.Now()
+	updated, err := m.next.UpdateFirewallRule(project, rule)
+	observeUpstream("FirewallRuleManager", "UpdateFirewallRule", start, err)
+	return updated, err
+}
+
+// DeleteFirewallRule delete firewall rule matching given project and name
+func (m *FirewallRuleManager) DeleteFirewallRule(project, name string) error {
+	start := time.Now()
+	err := m.next.DeleteFirewallRule(project, name)
+	observeUpstream("FirewallRuleManager", "DeleteFirewallRule", start, err)
+	return err
+}
+
+// GetFirewallQuota returns the firewall rules quota of given project
+func (m *FirewallRuleManager) GetFirewallQuota(project string) (*compute.Quota, error) {
+	start := time.Now()
+	quota, err := m.next.GetFirewallQuota(project)
+	observeUpstream("FirewallRuleManager", "GetFirewallQuota", start, err)
+	return quota, err
+}
+
+// GoogleClient instruments calls of a models.GoogleClientInterface. Implements models.GoogleClientInterface
+type GoogleClient struct {
+	next models.GoogleClientInterface
+}
+
+// NewGoogleClient GoogleClient constructor
+func NewGoogleClient(next models.GoogleClientInterface) *GoogleClient {
+	return &GoogleClient{next: next}
+}
+
+// IsProjectOwner return if a given user is owner of the given project
+func (c *GoogleClient) IsProjectOwner(user string, projectID string) error {
+	start := time.Now()
+	err := c.next.IsProjectOwner(user, projectID)
+	observeUpstream("GoogleClientInterface", "IsProjectOwner", start, err)
+	return err
+}
+
+// IsAServiceProjectOf return if projectA is a service project of projectB
+func (c *GoogleClient) IsAServiceProjectOf(projectA, projectB string) error {
+	start := time.Now()
+	err := c.next.IsAServiceProjectOf(projectA, projectB)
+	observeUpstream("GoogleClientInterface", "IsAServiceProjectOf", start, err)
+	return err
+}
+
+// ListServiceProjects returns service projects of given host project
+func (c *GoogleClient) ListServiceProjects(project string) ([]string, error) {
+	start := time.Now()
+	serviceProjects, err := c.next.ListServiceProjects(project)
+	observeUpstream("GoogleClientInterface", "ListServiceProjects", start, err)
+	return serviceProjects, err
+}
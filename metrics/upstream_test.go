@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Firewall rule manager returning fixed rules, or failing for unknown projects
+type fakeFirewallRuleManager struct {
+	models.FirewallRuleManager
+	rules map[string][]*compute.Firewall
+}
+
+func (f *fakeFirewallRuleManager) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	rules, ok := f.rules[project]
+	if !ok {
+		return nil, errors.New("Project not found")
+	}
+	return rules, nil
+}
+
+func (f *fakeFirewallRuleManager) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	f.rules[project] = append(f.rules[project], rule)
+	return rule, nil
+}
+
+func (f *fakeFirewallRuleManager) DeleteFirewallRule(project, name string) error {
+	for i, rule := range f.rules[project] {
+		if rule.Name == name {
+			f.rules[project] = append(f.rules[project][:i], f.rules[project][i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("Rule not found")
+}
+
+func TestFirewallRuleManager(t *testing.T) {
+	manager := NewFirewallRuleManager(&fakeFirewallRuleManager{rules: map[string][]*compute.Firewall{"host": nil}})
+
+	failures := testutil.ToFloat64(UpstreamErrors.WithLabelValues("FirewallRuleManager", "ListFirewallRule"))
+
+	_, err := manager.ListFirewallRule("host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = manager.ListFirewallRule("unknown")
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if got := testutil.ToFloat64(UpstreamErrors.WithLabelValues("FirewallRuleManager", "ListFirewallRule")); got != failures+1 {
+		t.Errorf("Expected %v errors, got %v", failures+1, got)
+	}
+}
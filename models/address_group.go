@@ -0,0 +1,268 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// AddressGroup describe a named list of IP ranges of a host project, usable as rule sources or destinations
+type AddressGroup struct {
+	Name   string   `json:"name"`
+	Ranges []string `json:"ranges"`
+}
+
+// AddressGroups describe an end-user response listing address groups
+type AddressGroups struct {
+	Project string         `json:"project"`
+	Groups  []AddressGroup `json:"data"`
+}
+
+// AddressGroupResult describe an end-user response of an address group change, with the result of re-applying it to referencing rules
+type AddressGroupResult struct {
+	Project string            `json:"project"`
+	Group   AddressGroup      `json:"group"`
+	Results []BatchRuleResult `json:"data"`
+}
+
+// AddressGroupReference describe a rule referencing address groups, with the ranges given along the groups
+type AddressGroupReference struct {
+	ServiceProject           string
+	Application              string
+	CustomName               string
+	SourceAddressGroups      []string
+	DestinationAddressGroups []string
+	SourceRanges             []string
+	DestinationRanges        []string
+}
+
+// AddressGroupStore contains methods to store address groups and rules referencing them
+type AddressGroupStore interface {
+	ListAddressGroups(project string) ([]AddressGroup, error)
+	GetAddressGroup(project, name string) (*AddressGroup, error)
+	SaveAddressGroup(project string, group AddressGroup) error
+	DeleteAddressGroup(project, name string) error
+	ListReferences(project, group string) ([]AddressGroupReference, error)
+	GetReference(project, serviceProject, application, customName string) (*AddressGroupReference, error)
+	SaveReference(project string, reference AddressGroupReference) error
+	DeleteReference(project, serviceProject, application, customName string) error
+}
+
+// AddressGroupMemoryStore keeps address groups in memory. Implements AddressGroupStore
+type AddressGroupMemoryStore struct {
+	mu         sync.RWMutex
+	groups     map[string]map[string]AddressGroup
+	references map[string]map[ruleKey]AddressGroupReference
+}
+
+// NewAddressGroupMemoryStore AddressGroupMemoryStore constructor
+func NewAddressGroupMemoryStore() *AddressGroupMemoryStore {
+	return &AddressGroupMemoryStore{
+		groups:     make(map[string]map[string]AddressGroup),
+		references: make(map[string]map[ruleKey]AddressGroupReference),
+	}
+}
+
+// ListAddressGroups returns address groups of given project sorted by name
+func (s *AddressGroupMemoryStore) ListAddressGroups(project string) ([]AddressGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make([]AddressGroup, 0, len(s.groups[project]))
+	for _, group := range s.groups[project] {
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// GetAddressGroup returns the address group matching given project and name
+func (s *AddressGroupMemoryStore) GetAddressGroup(project, name string) (*AddressGroup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	group, ok := s.groups[project][name]
+	if !ok {
+		e := NewNotFoundError()
+		e.Message = fmt.Sprintf("Address group [%s] not found in project [%s]", name, project)
+		return nil, e
+	}
+
+	return &group, nil
+}
+
+// SaveAddressGroup creates or replaces given address group
+func (s *AddressGroupMemoryStore) SaveAddressGroup(project string, group AddressGroup) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[project]; !ok {
+		s.groups[project] = make(map[string]AddressGroup)
+	}
+
+	s.groups[project][group.Name] = group
+	return nil
+}
+
+// DeleteAddressGroup deletes the address group matching given project and name
+func (s *AddressGroupMemoryStore) DeleteAddressGroup(project, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.groups[project][name]; !ok {
+		e := NewNotFoundError()
+		e.Message = fmt.Sprintf("Address group [%s] not found in project [%s]", name, project)
+		return e
+	}
+
+	delete(s.groups[project], name)
+	return nil
+}
+
+// ListReferences returns rules of given project referencing given address group
+func (s *AddressGroupMemoryStore) ListReferences(project, group string) ([]AddressGroupReference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	references := make([]AddressGroupReference, 0)
+	for _, reference := range s.references[project] {
+		if contains(reference.SourceAddressGroups, group) || contains(reference.DestinationAddressGroups, group) {
+			references = append(references, reference)
+		}
+	}
+
+	return references, nil
+}
+
+// GetReference returns the matching rule's reference, nil if it does not reference any group
+func (s *AddressGroupMemoryStore) GetReference(project, serviceProject, application, customName string) (*AddressGroupReference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reference, ok := s.references[project][ruleKey{serviceProject, application, customName}]
+	if !ok {
+		return nil, nil
+	}
+
+	return &reference, nil
+}
+
+// SaveReference creates or replaces given rule's reference
+func (s *AddressGroupMemoryStore) SaveReference(project string, reference AddressGroupReference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.references[project]; !ok {
+		s.references[project] = make(map[ruleKey]AddressGroupReference)
+	}
+
+	s.references[project][ruleKey{reference.ServiceProject, reference.Application, reference.CustomName}] = reference
+	return nil
+}
+
+// DeleteReference deletes the matching rule's reference, if any
+func (s *AddressGroupMemoryStore) DeleteReference(project, serviceProject, application, customName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.references[project], ruleKey{serviceProject, application, customName})
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// addressGroupState describe the content of an address group store, by project
+type addressGroupState struct {
+	Groups     map[string][]AddressGroup          `json:"groups"`
+	References map[string][]AddressGroupReference `json:"references"`
+}
+
+func (s *AddressGroupMemoryStore) state() addressGroupState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := addressGroupState{Groups: make(map[string][]AddressGroup), References: make(map[string][]AddressGroupReference)}
+	for project, groups := range s.groups {
+		for _, group := range groups {
+			state.Groups[project] = append(state.Groups[project], group)
+		}
+	}
+	for project, references := range s.references {
+		for _, reference := range references {
+			state.References[project] = append(state.References[project], reference)
+		}
+	}
+	return state
+}
+
+// AddressGroupFileStore keeps address groups in memory, and saves them to a file on each change
+// so that they survive restarts. Implements AddressGroupStore
+type AddressGroupFileStore struct {
+	*AddressGroupMemoryStore
+	mu   sync.Mutex
+	path string
+}
+
+// NewAddressGroupFileStore AddressGroupFileStore constructor, loading address groups saved to given file if any
+func NewAddressGroupFileStore(path string) (*AddressGroupFileStore, error) {
+	var state addressGroupState
+	err := readStateFile(path, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AddressGroupFileStore{AddressGroupMemoryStore: NewAddressGroupMemoryStore(), path: path}
+	for project, groups := range state.Groups {
+		for _, group := range groups {
+			s.AddressGroupMemoryStore.SaveAddressGroup(project, group)
+		}
+	}
+	for project, references := range state.References {
+		for _, reference := range references {
+			s.AddressGroupMemoryStore.SaveReference(project, reference)
+		}
+	}
+	return s, nil
+}
+
+// SaveAddressGroup creates or replaces given address group
+func (s *AddressGroupFileStore) SaveAddressGroup(project string, group AddressGroup) error {
+	return s.update(func() error { return s.AddressGroupMemoryStore.SaveAddressGroup(project, group) })
+}
+
+// DeleteAddressGroup deletes the address group matching given project and name
+func (s *AddressGroupFileStore) DeleteAddressGroup(project, name string) error {
+	return s.update(func() error { return s.AddressGroupMemoryStore.DeleteAddressGroup(project, name) })
+}
+
+// SaveReference creates or replaces given rule's reference
+func (s *AddressGroupFileStore) SaveReference(project string, reference AddressGroupReference) error {
+	return s.update(func() error { return s.AddressGroupMemoryStore.SaveReference(project, reference) })
+}
+
+// DeleteReference deletes the matching rule's reference, if any
+func (s *AddressGroupFileStore) DeleteReference(project, serviceProject, application, customName string) error {
+	return s.update(func() error {
+		return s.AddressGroupMemoryStore.DeleteReference(project, serviceProject, application, customName)
+	})
+}
+
+// update applies given change, then saves the whole store. Changes are saved one at a time, in order
+func (s *AddressGroupFileStore) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := change()
+	if err != nil {
+		return err
+	}
+	return writeStateFile(s.path, s.AddressGroupMemoryStore.state())
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddressGroupMemoryStore(t *testing.T) {
+	store := NewAddressGroupMemoryStore()
+
+	_, err := store.GetAddressGroup("host", "office")
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+
+	store.SaveAddressGroup("host", AddressGroup{Name: "vpn", Ranges: []string{"10.1.0.0/16"}})
+	store.SaveAddressGroup("host", AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/16"}})
+	store.SaveAddressGroup("other-host", AddressGroup{Name: "office", Ranges: []string{"10.2.0.0/16"}})
+
+	groups, _ := store.ListAddressGroups("host")
+	if len(groups) != 2 || groups[0].Name != "office" || groups[1].Name != "vpn" {
+		t.Errorf("Groups should be scoped by project and sorted by name. Got %v", groups)
+	}
+
+	group, err := store.GetAddressGroup("other-host", "office")
+	if err != nil || group.Ranges[0] != "10.2.0.0/16" {
+		t.Errorf("Unexpected group. Got %v, %v", group, err)
+	}
+
+	err = store.DeleteAddressGroup("host", "vpn")
+	if err != nil {
+		t.Errorf("Unexpected error. Got %v", err)
+	}
+
+	err = store.DeleteAddressGroup("host", "vpn")
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+}
+
+func TestAddressGroupMemoryStoreReferences(t *testing.T) {
+	store := NewAddressGroupMemoryStore()
+	store.SaveReference("host", AddressGroupReference{ServiceProject: "sp", Application: "web", CustomName: "https", SourceAddressGroups: []string{"office"}})
+	store.SaveReference("host", AddressGroupReference{ServiceProject: "sp", Application: "web", CustomName: "egress", DestinationAddressGroups: []string{"office", "vpn"}})
+
+	references, _ := store.ListReferences("host", "office")
+	if len(references) != 2 {
+		t.Errorf("Wrong references count. Got %v", references)
+	}
+
+	references, _ = store.ListReferences("host", "vpn")
+	if len(references) != 1 || references[0].CustomName != "egress" {
+		t.Errorf("Unexpected references. Got %v", references)
+	}
+
+	reference, _ := store.GetReference("host", "sp", "web", "https")
+	if reference == nil || reference.SourceAddressGroups[0] != "office" {
+		t.Errorf("Unexpected reference. Got %v", reference)
+	}
+
+	store.DeleteReference("host", "sp", "web", "https")
+	reference, _ = store.GetReference("host", "sp", "web", "https")
+	if reference != nil {
+		t.Errorf("Reference should have been deleted. Got %v", reference)
+	}
+}
+
+func TestAddressGroupMemoryStoreReferencesWithHyphens(t *testing.T) {
+	store := NewAddressGroupMemoryStore()
+	store.SaveReference("host", AddressGroupReference{ServiceProject: "sp-a", Application: "web", CustomName: "https", SourceAddressGroups: []string{"office"}})
+	store.SaveReference("host", AddressGroupReference{ServiceProject: "sp", Application: "a-web", CustomName: "https", SourceAddressGroups: []string{"vpn"}})
+
+	references, _ := store.ListReferences("host", "office")
+	if len(references) != 1 || references[0].ServiceProject != "sp-a" {
+		t.Errorf("References with the same rule name should not collide. Got %v", references)
+	}
+}
+
+func TestAddressGroupFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "address_groups.json")
+
+	store, err := NewAddressGroupFileStore(path)
+	if err != nil {
+		t.Fatalf("Missing file should give an empty store. Got %v", err)
+	}
+	store.SaveAddressGroup("host", AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/8"}})
+	store.SaveAddressGroup("host", AddressGroup{Name: "vpn", Ranges: []string{"172.16.0.0/12"}})
+	store.DeleteAddressGroup("host", "vpn")
+	store.SaveReference("host", AddressGroupReference{ServiceProject: "sp", Application: "web", CustomName: "https", SourceAddressGroups: []string{"office"}})
+
+	// A new store finds the saved state back
+	store, err = NewAddressGroupFileStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	groups, _ := store.ListAddressGroups("host")
+	if len(groups) != 1 || groups[0].Name != "office" || groups[0].Ranges[0] != "10.0.0.0/8" {
+		t.Errorf("Unexpected address groups. Got %v", groups)
+	}
+
+	reference, _ := store.GetReference("host", "sp", "web", "https")
+	if reference == nil || reference.SourceAddressGroups[0] != "office" {
+		t.Errorf("Unexpected reference. Got %v", reference)
+	}
+
+	// Corrupted state is not silently dropped
+	ioutil.WriteFile(path, []byte("{"), 0600)
+	_, err = NewAddressGroupFileStore(path)
+	if err == nil {
+		t.Errorf("Expected an error on corrupted state")
+	}
+}
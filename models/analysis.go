@@ -0,0 +1,29 @@
+package models
+
+// Rule analysis finding kinds
+const (
+	// FindingShadowed rule never decides any traffic, being covered by a rule taking precedence
+	FindingShadowed = "SHADOWED"
+	// FindingDuplicate rule has the same effect as another rule
+	FindingDuplicate = "DUPLICATE"
+	// FindingOverlapping rule shares part of its traffic with another rule
+	FindingOverlapping = "OVERLAPPING"
+)
+
+// RuleFinding describe an issue of a rule caused by another rule
+type RuleFinding struct {
+	Kind       string `json:"kind"`
+	Rule       string `json:"rule"`
+	Priority   int64  `json:"priority"`
+	By         string `json:"by"`
+	ByPriority int64  `json:"by_priority"`
+	Message    string `json:"message"`
+}
+
+// RuleAnalysis describe an end-user response of an analysis of host project rules, or of an application rules
+type RuleAnalysis struct {
+	Project        string        `json:"project"`
+	ServiceProject string        `json:"service_project,omitempty"`
+	Application    string        `json:"application,omitempty"`
+	Findings       []RuleFinding `json:"data"`
+}
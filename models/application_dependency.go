@@ -0,0 +1,173 @@
+package models
+
+import (
+	"sort"
+	"sync"
+)
+
+// SourceApplication describe an application allowed as traffic source of a rule, through its rules target tags.
+// Service project defaults to the rule's one, and all application rules are used when no rule is given
+type SourceApplication struct {
+	ServiceProject string `json:"service_project,omitempty"`
+	Application    string `json:"application"`
+	Rule           string `json:"rule,omitempty"`
+}
+
+// ApplicationDependency describe a rule allowing traffic from other applications
+type ApplicationDependency struct {
+	ServiceProject     string
+	Application        string
+	CustomName         string
+	SourceApplications []SourceApplication
+}
+
+// DependencyStore contains methods to store rules allowing traffic from other applications
+type DependencyStore interface {
+	ListDependencies(project, serviceProject, application string) ([]ApplicationDependency, error)
+	GetDependency(project, serviceProject, application, customName string) (*ApplicationDependency, error)
+	SaveDependency(project string, dependency ApplicationDependency) error
+	DeleteDependency(project, serviceProject, application, customName string) error
+}
+
+// DependencyMemoryStore keeps dependencies in memory. Implements DependencyStore
+type DependencyMemoryStore struct {
+	mu           sync.RWMutex
+	dependencies map[string]map[ruleKey]ApplicationDependency
+}
+
+// NewDependencyMemoryStore DependencyMemoryStore constructor
+func NewDependencyMemoryStore() *DependencyMemoryStore {
+	return &DependencyMemoryStore{
+		dependencies: make(map[string]map[ruleKey]ApplicationDependency),
+	}
+}
+
+// ListDependencies returns rules of given project allowing traffic from given application, sorted by name
+func (s *DependencyMemoryStore) ListDependencies(project, serviceProject, application string) ([]ApplicationDependency, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dependencies := make([]ApplicationDependency, 0)
+	for _, dependency := range s.dependencies[project] {
+		for _, source := range dependency.SourceApplications {
+			if source.ServiceProject == serviceProject && source.Application == application {
+				dependencies = append(dependencies, dependency)
+				break
+			}
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		a, b := dependencies[i], dependencies[j]
+		if a.ServiceProject != b.ServiceProject {
+			return a.ServiceProject < b.ServiceProject
+		}
+		if a.Application != b.Application {
+			return a.Application < b.Application
+		}
+		return a.CustomName < b.CustomName
+	})
+	return dependencies, nil
+}
+
+// GetDependency returns the matching rule's dependency, nil if it does not allow traffic from any application
+func (s *DependencyMemoryStore) GetDependency(project, serviceProject, application, customName string) (*ApplicationDependency, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dependency, ok := s.dependencies[project][ruleKey{serviceProject, application, customName}]
+	if !ok {
+		return nil, nil
+	}
+
+	return &dependency, nil
+}
+
+// SaveDependency creates or replaces given rule's dependency
+func (s *DependencyMemoryStore) SaveDependency(project string, dependency ApplicationDependency) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.dependencies[project]; !ok {
+		s.dependencies[project] = make(map[ruleKey]ApplicationDependency)
+	}
+
+	s.dependencies[project][ruleKey{dependency.ServiceProject, dependency.Application, dependency.CustomName}] = dependency
+	return nil
+}
+
+// DeleteDependency deletes the matching rule's dependency, if any
+func (s *DependencyMemoryStore) DeleteDependency(project, serviceProject, application, customName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.dependencies[project], ruleKey{serviceProject, application, customName})
+	return nil
+}
+
+// dependencyState describe the content of a dependency store, by project
+type dependencyState struct {
+	Dependencies map[string][]ApplicationDependency `json:"dependencies"`
+}
+
+func (s *DependencyMemoryStore) state() dependencyState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := dependencyState{Dependencies: make(map[string][]ApplicationDependency)}
+	for project, dependencies := range s.dependencies {
+		for _, dependency := range dependencies {
+			state.Dependencies[project] = append(state.Dependencies[project], dependency)
+		}
+	}
+	return state
+}
+
+// DependencyFileStore keeps dependencies in memory, and saves them to a file on each change
+// so that they survive restarts. Implements DependencyStore
+type DependencyFileStore struct {
+	*DependencyMemoryStore
+	mu   sync.Mutex
+	path string
+}
+
+// NewDependencyFileStore DependencyFileStore constructor, loading dependencies saved to given file if any
+func NewDependencyFileStore(path string) (*DependencyFileStore, error) {
+	var state dependencyState
+	err := readStateFile(path, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DependencyFileStore{DependencyMemoryStore: NewDependencyMemoryStore(), path: path}
+	for project, dependencies := range state.Dependencies {
+		for _, dependency := range dependencies {
+			s.DependencyMemoryStore.SaveDependency(project, dependency)
+		}
+	}
+	return s, nil
+}
+
+// SaveDependency creates or replaces given rule's dependency
+func (s *DependencyFileStore) SaveDependency(project string, dependency ApplicationDependency) error {
+	return s.update(func() error { return s.DependencyMemoryStore.SaveDependency(project, dependency) })
+}
+
+// DeleteDependency deletes the matching rule's dependency, if any
+func (s *DependencyFileStore) DeleteDependency(project, serviceProject, application, customName string) error {
+	return s.update(func() error {
+		return s.DependencyMemoryStore.DeleteDependency(project, serviceProject, application, customName)
+	})
+}
+
+// update applies given change, then saves the whole store. Changes are saved one at a time, in order
+func (s *DependencyFileStore) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := change()
+	if err != nil {
+		return err
+	}
+	return writeStateFile(s.path, s.DependencyMemoryStore.state())
+}
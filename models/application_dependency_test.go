@@ -0,0 +1,80 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDependencyMemoryStore(t *testing.T) {
+	store := NewDependencyMemoryStore()
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "web", CustomName: "to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "batch", CustomName: "to-db", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "db", Rule: "mysql"}, {ServiceProject: "sp", Application: "api"}}})
+	store.SaveDependency("other-host", ApplicationDependency{ServiceProject: "sp", Application: "web", CustomName: "to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+
+	dependencies, _ := store.ListDependencies("host", "sp", "api")
+	if len(dependencies) != 2 || dependencies[0].Application != "batch" || dependencies[1].Application != "web" {
+		t.Errorf("Dependencies should be scoped by project and sorted by name. Got %v", dependencies)
+	}
+
+	dependencies, _ = store.ListDependencies("host", "other-sp", "api")
+	if len(dependencies) != 0 {
+		t.Errorf("Dependencies should be scoped by service project. Got %v", dependencies)
+	}
+
+	dependency, _ := store.GetDependency("host", "sp", "batch", "to-db")
+	if dependency == nil || dependency.SourceApplications[0].Rule != "mysql" {
+		t.Errorf("Unexpected dependency. Got %v", dependency)
+	}
+
+	store.DeleteDependency("host", "sp", "batch", "to-db")
+	dependency, _ = store.GetDependency("host", "sp", "batch", "to-db")
+	if dependency != nil {
+		t.Errorf("Dependency should be deleted. Got %v", dependency)
+	}
+
+	dependencies, _ = store.ListDependencies("host", "sp", "api")
+	if len(dependencies) != 1 {
+		t.Errorf("Wrong dependencies count. Got %v", dependencies)
+	}
+}
+
+func TestDependencyMemoryStoreWithHyphens(t *testing.T) {
+	store := NewDependencyMemoryStore()
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "web-front", CustomName: "to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "web", CustomName: "front-to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+
+	dependencies, _ := store.ListDependencies("host", "sp", "api")
+	if len(dependencies) != 2 {
+		t.Errorf("Dependencies with the same rule name should not collide. Got %v", dependencies)
+	}
+}
+
+func TestDependencyFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dependencies.json")
+
+	store, err := NewDependencyFileStore(path)
+	if err != nil {
+		t.Fatalf("Missing file should give an empty store. Got %v", err)
+	}
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "web", CustomName: "to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+	store.SaveDependency("host", ApplicationDependency{ServiceProject: "sp", Application: "batch", CustomName: "to-api", SourceApplications: []SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+	store.DeleteDependency("host", "sp", "batch", "to-api")
+
+	// A new store finds the saved state back
+	store, err = NewDependencyFileStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	dependencies, _ := store.ListDependencies("host", "sp", "api")
+	if len(dependencies) != 1 || dependencies[0].Application != "web" || dependencies[0].SourceApplications[0].Application != "api" {
+		t.Errorf("Unexpected dependencies. Got %v", dependencies)
+	}
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// BackendTarget describe the backend managing rules of a host project, and the service account impersonated to reach it,
+// the one of the host project credentials
+type BackendTarget struct {
+	Backend        string
+	FirewallPolicy string
+	ServiceAccount string
+}
+
+// NewBackendTarget returns the backend target of given host project configuration
+func NewBackendTarget(hostProject config.HostProject) BackendTarget {
+	target := BackendTarget{
+		Backend:        hostProject.Backend,
+		FirewallPolicy: hostProject.FirewallPolicy,
+	}
+	if target.Backend == "" {
+		target.Backend = config.BackendVPCFirewall
+	}
+	return target
+}
+
+// FirewallRuleManagerFactory builds the firewall rule manager of a backend target, reaching it with given client options
+type FirewallRuleManagerFactory func(target BackendTarget, opts ...option.ClientOption) (FirewallRuleManager, error)
+
+// NewFirewallRuleManagerFactory returns a factory of managers of rules on backend targets,
+// firewall policies translating network tags to given secure tag values
+func NewFirewallRuleManagerFactory(secureTags map[string]string) FirewallRuleManagerFactory {
+	return func(target BackendTarget, opts ...option.ClientOption) (FirewallRuleManager, error) {
+		if target.Backend == config.BackendMemory {
+			return NewRuleManager(NewMemoryBackend()), nil
+		}
+
+		computeService, err := newComputeService(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		switch target.Backend {
+		case config.BackendVPCFirewall:
+			return NewRuleManager(NewVPCFirewallBackend(computeService)), nil
+		case config.BackendNetworkFirewallPolicy:
+			return NewRuleManager(NewNetworkFirewallPolicyBackend(computeService, target.FirewallPolicy, secureTags)), nil
+		case config.BackendHierarchicalFirewallPolicy:
+			return NewRuleManager(NewHierarchicalFirewallPolicyBackend(computeService, target.FirewallPolicy, secureTags)), nil
+		}
+		return nil, fmt.Errorf("unknown backend [%s]", target.Backend)
+	}
+}
+
+// BackendResolver manages the rules of each host project on the backend mapped to it, with the credentials resolved for it.
+// Implements FirewallRuleManager. Managers are built on first use, and shared by host projects mapped to the same target
+type BackendResolver struct {
+	credentials *CredentialResolver
+	targets     map[string]BackendTarget
+	factory     FirewallRuleManagerFactory
+
+	mu       sync.Mutex
+	managers map[BackendTarget]FirewallRuleManager
+}
+
+// NewBackendResolver BackendResolver constructor. Host projects absent from given targets use VPC firewall rules
+func NewBackendResolver(credentials *CredentialResolver, targets map[string]BackendTarget, factory FirewallRuleManagerFactory) *BackendResolver {
+	return &BackendResolver{
+		credentials: credentials,
+		targets:     targets,
+		factory:     factory,
+		managers:    make(map[BackendTarget]FirewallRuleManager),
+	}
+}
+
+// Target returns the backend target of given host project
+func (b *BackendResolver) Target(project string) BackendTarget {
+	target, ok := b.targets[project]
+	if !ok {
+		target = BackendTarget{Backend: config.BackendVPCFirewall}
+	}
+	target.ServiceAccount = b.credentials.ServiceAccount(project)
+	return target
+}
+
+// Manager returns the firewall rule manager of given host project. Failures to build it are not kept, so that next calls retry
+func (b *BackendResolver) Manager(project string) (FirewallRuleManager, error) {
+	target := b.Target(project)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if manager, ok := b.managers[target]; ok {
+		return manager, nil
+	}
+
+	opts, err := b.credentials.ClientOptions(project)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, err := b.factory(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.managers[target] = manager
+	return manager, nil
+}
+
+// ListFirewallRule returns given project's firewall rule
+func (b *BackendResolver) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return nil, err
+	}
+	return manager.ListFirewallRule(project)
+}
+
+// GetFirewallRule returns firewall rule matching given project and name
+func (b *BackendResolver) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return nil, err
+	}
+	return manager.GetFirewallRule(project, name)
+}
+
+// CreateFirewallRule create given firewall rule on given project
+func (b *BackendResolver) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return nil, err
+	}
+	return manager.CreateFirewallRule(project, rule)
+}
+
+// UpdateFirewallRule replace the firewall rule matching given rule's name on given project
+func (b *BackendResolver) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return nil, err
+	}
+	return manager.UpdateFirewallRule(project, rule)
+}
+
+// DeleteFirewallRule delete firewall rule matching given project and name
+func (b *BackendResolver) DeleteFirewallRule(project, name string) error {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return err
+	}
+	return manager.DeleteFirewallRule(project, name)
+}
+
+// GetFirewallQuota returns the firewall rules quota of given project
+func (b *BackendResolver) GetFirewallQuota(project string) (*compute.Quota, error) {
+	manager, err := b.Manager(project)
+	if err != nil {
+		return nil, err
+	}
+	return manager.GetFirewallQuota(project)
+}
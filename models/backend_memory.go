@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryBackend keeps rules of host projects in memory, to run the API without reaching Google. Implements RuleBackend
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	rules  map[string][]*Rule
+	nextID uint64
+}
+
+// NewMemoryBackend MemoryBackend constructor
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{rules: make(map[string][]*Rule), nextID: 1}
+}
+
+// ListRules returns given project's rules
+func (b *MemoryBackend) ListRules(project string) ([]*Rule, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	rules := make([]*Rule, 0, len(b.rules[project]))
+	for _, rule := range b.rules[project] {
+		rules = append(rules, copyRule(rule))
+	}
+	return rules, nil
+}
+
+// GetRule returns rule matching given project and name
+func (b *MemoryBackend) GetRule(project, name string) (*Rule, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	i := b.index(project, name)
+	if i < 0 {
+		return nil, NewNotFoundError(fmt.Sprintf("Rule [%s] not found in project [%s]", name, project))
+	}
+	return copyRule(b.rules[project][i]), nil
+}
+
+// CreateRule create given rule on given project
+func (b *MemoryBackend) CreateRule(project string, rule *Rule) (*Rule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.index(project, rule.Name) >= 0 {
+		return nil, NewConflictError(fmt.Sprintf("Rule [%s] already exists in project [%s]", rule.Name, project))
+	}
+
+	created := copyRule(rule)
+	created.ID = b.nextID
+	created.CreationTimestamp = time.Now().Format(time.RFC3339)
+	b.nextID++
+	b.rules[project] = append(b.rules[project], created)
+
+	return copyRule(created), nil
+}
+
+// UpdateRule replace the rule matching given rule's name on given project
+func (b *MemoryBackend) UpdateRule(project string, rule *Rule) (*Rule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(project, rule.Name)
+	if i < 0 {
+		return nil, NewNotFoundError(fmt.Sprintf("Rule [%s] not found in project [%s]", rule.Name, project))
+	}
+
+	updated := copyRule(rule)
+	updated.ID = b.rules[project][i].ID
+	updated.CreationTimestamp = b.rules[project][i].CreationTimestamp
+	b.rules[project][i] = updated
+
+	return copyRule(updated), nil
+}
+
+// DeleteRule delete rule matching given project and name
+func (b *MemoryBackend) DeleteRule(project, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.index(project, name)
+	if i < 0 {
+		return NewNotFoundError(fmt.Sprintf("Rule [%s] not found in project [%s]", name, project))
+	}
+	b.rules[project] = append(b.rules[project][:i], b.rules[project][i+1:]...)
+	return nil
+}
+
+// GetRuleQuota returns the count of rules of given project, without limit
+func (b *MemoryBackend) GetRuleQuota(project string) (*RuleQuota, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return &RuleQuota{Usage: float64(len(b.rules[project]))}, nil
+}
+
+// Index of the rule matching given project and name, -1 if none. Callers hold the lock
+func (b *MemoryBackend) index(project, name string) int {
+	for i, rule := range b.rules[project] {
+		if rule.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyRule returns a deep copy of given rule, so that callers and the backend do not share its slices
+func copyRule(rule *Rule) *Rule {
+	r := *rule
+	if rule.Protocols != nil {
+		r.Protocols = make([]RuleProtocol, len(rule.Protocols))
+		for i, p := range rule.Protocols {
+			r.Protocols[i] = RuleProtocol{Protocol: p.Protocol, Ports: copyStrings(p.Ports)}
+		}
+	}
+	r.SourceRanges = copyStrings(rule.SourceRanges)
+	r.DestinationRanges = copyStrings(rule.DestinationRanges)
+	r.SourceTags = copyStrings(rule.SourceTags)
+	r.TargetTags = copyStrings(rule.TargetTags)
+	r.SourceServiceAccounts = copyStrings(rule.SourceServiceAccounts)
+	r.TargetServiceAccounts = copyStrings(rule.TargetServiceAccounts)
+	return &r
+}
+
+// copyStrings returns a copy of given slice, nil when nil
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
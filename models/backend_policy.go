@@ -0,0 +1,387 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Priority given to created rules without one, as VPC firewall rules default priority.
+// Firewall policies identify rules by priority, so the next unused one is taken
+const defaultPolicyRulePriority = 1000
+
+// Calls on a firewall policy
+type firewallPolicy interface {
+	get() (*compute.FirewallPolicy, error)
+	addRule(rule *compute.FirewallPolicyRule) error
+	patchRule(priority int64, rule *compute.FirewallPolicyRule) error
+	removeRule(priority int64) error
+}
+
+// FirewallPolicyBackend manages rules of a GCP firewall policy. Implements RuleBackend.
+// Firewall policies match secure tags rather than network tags, so tags of rules are translated to the secure tag values
+// mapped to them, tags already given as tagValues/<ID> being kept. Rules of other actions than allow and deny are ignored
+// https://cloud.google.com/firewall/docs/firewall-policies-overview
+type FirewallPolicyBackend struct {
+	name   string
+	policy func(project string) firewallPolicy
+	// Hierarchical firewall policies apply rules to their target resources, network policies to their associated networks
+	targetResources bool
+
+	secureTags  map[string]string
+	networkTags map[string]string
+}
+
+// NewNetworkFirewallPolicyBackend FirewallPolicyBackend constructor, managing rules of the global network firewall policy of each host project with given name.
+// Network tags of rules are translated to given secure tag values
+func NewNetworkFirewallPolicyBackend(computeService *compute.Service, policy string, secureTags map[string]string) *FirewallPolicyBackend {
+	return newFirewallPolicyBackend(policy, func(project string) firewallPolicy {
+		return networkFirewallPolicy{service: computeService.NetworkFirewallPolicies, project: project, policy: policy}
+	}, false, secureTags)
+}
+
+// NewHierarchicalFirewallPolicyBackend FirewallPolicyBackend constructor, managing rules of the hierarchical firewall policy with given ID.
+// Host projects sharing the policy share it, each one seeing only the rules applying to its networks.
+// Network tags of rules are translated to given secure tag values
+func NewHierarchicalFirewallPolicyBackend(computeService *compute.Service, policy string, secureTags map[string]string) *FirewallPolicyBackend {
+	return newFirewallPolicyBackend(policy, func(project string) firewallPolicy {
+		return hierarchicalFirewallPolicy{service: computeService.FirewallPolicies, policy: policy}
+	}, true, secureTags)
+}
+
+func newFirewallPolicyBackend(name string, policy func(project string) firewallPolicy, targetResources bool, secureTags map[string]string) *FirewallPolicyBackend {
+	networkTags := make(map[string]string)
+	for tag, value := range secureTags {
+		networkTags[value] = tag
+	}
+	return &FirewallPolicyBackend{
+		name:            name,
+		policy:          policy,
+		targetResources: targetResources,
+		secureTags:      secureTags,
+		networkTags:     networkTags,
+	}
+}
+
+// ListRules returns rules of the policy of given project
+func (b *FirewallPolicyBackend) ListRules(project string) ([]*Rule, error) {
+	policyRules, err := b.listPolicyRules(project)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	for _, policyRule := range policyRules {
+		if b.targetResources && !appliesToProject(policyRule, project) {
+			continue
+		}
+		rules = append(rules, b.newRule(policyRule))
+	}
+	return rules, nil
+}
+
+// Returns allow and deny rules of the policy of given project, whatever the networks they apply to
+func (b *FirewallPolicyBackend) listPolicyRules(project string) ([]*compute.FirewallPolicyRule, error) {
+	policy, err := b.policy(project).get()
+	if err != nil {
+		return nil, err
+	}
+
+	var policyRules []*compute.FirewallPolicyRule
+	for _, policyRule := range policy.Rules {
+		if policyRule.Action != RuleActionAllow && policyRule.Action != RuleActionDeny {
+			continue
+		}
+		policyRules = append(policyRules, policyRule)
+	}
+	return policyRules, nil
+}
+
+// GetRule returns rule matching given name in the policy of given project
+func (b *FirewallPolicyBackend) GetRule(project, name string) (*Rule, error) {
+	rules, err := b.ListRules(project)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule, nil
+		}
+	}
+	return nil, NewNotFoundError(fmt.Sprintf("Rule [%s] not found in firewall policy [%s]", name, b.name))
+}
+
+// CreateRule add given rule to the policy of given project.
+// Names and priorities are unique in the whole policy, including rules of other host projects sharing it
+func (b *FirewallPolicyBackend) CreateRule(project string, rule *Rule) (*Rule, error) {
+	policyRules, err := b.listPolicyRules(project)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[int64]bool)
+	for _, r := range policyRules {
+		if r.RuleName == rule.Name {
+			return nil, NewConflictError(fmt.Sprintf("Rule [%s] already exists in firewall policy [%s]", rule.Name, b.name))
+		}
+		used[r.Priority] = true
+	}
+
+	created := *rule
+	if created.Priority == 0 {
+		created.Priority = defaultPolicyRulePriority
+		for used[created.Priority] {
+			created.Priority++
+		}
+	}
+
+	policyRule, err := b.policyRule(project, &created)
+	if err != nil {
+		return nil, err
+	}
+	err = b.policy(project).addRule(policyRule)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetRule(project, rule.Name)
+}
+
+// UpdateRule replace the rule matching given rule's name in the policy of given project
+func (b *FirewallPolicyBackend) UpdateRule(project string, rule *Rule) (*Rule, error) {
+	current, err := b.GetRule(project, rule.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *rule
+	if updated.Priority == 0 {
+		updated.Priority = current.Priority
+	}
+
+	policyRule, err := b.policyRule(project, &updated)
+	if err != nil {
+		return nil, err
+	}
+	err = b.policy(project).patchRule(current.Priority, policyRule)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetRule(project, rule.Name)
+}
+
+// DeleteRule remove rule matching given name from the policy of given project
+func (b *FirewallPolicyBackend) DeleteRule(project, name string) error {
+	current, err := b.GetRule(project, name)
+	if err != nil {
+		return err
+	}
+	return b.policy(project).removeRule(current.Priority)
+}
+
+// GetRuleQuota returns the count of rules of the policy of given project. Firewall policies limits are in rule attributes, so none is given
+func (b *FirewallPolicyBackend) GetRuleQuota(project string) (*RuleQuota, error) {
+	rules, err := b.ListRules(project)
+	if err != nil {
+		return nil, err
+	}
+	return &RuleQuota{Usage: float64(len(rules))}, nil
+}
+
+// Translates a rule of given project to a firewall policy rule.
+// Returns a bad request error on fields firewall policies cannot enforce as given, rather than dropping them
+func (b *FirewallPolicyBackend) policyRule(project string, rule *Rule) (*compute.FirewallPolicyRule, error) {
+	if len(rule.SourceServiceAccounts) > 0 {
+		return nil, NewBadRequestError(fmt.Sprintf("Source service accounts are not supported by firewall policy [%s]", b.name))
+	}
+	if len(rule.TargetServiceAccounts) > 0 && len(rule.TargetTags) > 0 {
+		return nil, NewBadRequestError(fmt.Sprintf("Target service accounts cannot be combined with target tags on firewall policy [%s]", b.name))
+	}
+	if rule.LoggingMetadata == LoggingExcludeAllMetadata {
+		return nil, NewBadRequestError(fmt.Sprintf("Logging metadata [%s] is not supported by firewall policy [%s], which logs all metadata", rule.LoggingMetadata, b.name))
+	}
+	if b.targetResources && rule.Network == "" {
+		return nil, NewBadRequestError(fmt.Sprintf("Network is required by hierarchical firewall policy [%s]", b.name))
+	}
+
+	policyRule := &compute.FirewallPolicyRule{
+		RuleName:      rule.Name,
+		Description:   rule.Description,
+		Direction:     rule.Direction,
+		Action:        rule.Action,
+		Priority:      rule.Priority,
+		Disabled:      rule.Disabled,
+		EnableLogging: rule.Logging,
+		Match: &compute.FirewallPolicyRuleMatcher{
+			SrcIpRanges:  rule.SourceRanges,
+			DestIpRanges: rule.DestinationRanges,
+		},
+		TargetServiceAccounts: rule.TargetServiceAccounts,
+	}
+	if policyRule.Action == "" {
+		policyRule.Action = RuleActionAllow
+	}
+	if b.targetResources {
+		network := rule.Network
+		if !strings.Contains(network, "projects/") {
+			network = fmt.Sprintf("projects/%s/%s", project, strings.TrimPrefix(network, "/"))
+		}
+		if !inProject(network, project) {
+			return nil, NewBadRequestError(fmt.Sprintf("Network [%s] is not a network of [%s]", rule.Network, project))
+		}
+		policyRule.TargetResources = []string{network}
+	}
+
+	for _, protocol := range rule.Protocols {
+		policyRule.Match.Layer4Configs = append(policyRule.Match.Layer4Configs, &compute.FirewallPolicyRuleMatcherLayer4Config{IpProtocol: protocol.Protocol, Ports: protocol.Ports})
+	}
+	for _, tag := range rule.SourceTags {
+		value, err := b.secureTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		policyRule.Match.SrcSecureTags = append(policyRule.Match.SrcSecureTags, &compute.FirewallPolicyRuleSecureTag{Name: value})
+	}
+	for _, tag := range rule.TargetTags {
+		value, err := b.secureTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		policyRule.TargetSecureTags = append(policyRule.TargetSecureTags, &compute.FirewallPolicyRuleSecureTag{Name: value})
+	}
+
+	return policyRule, nil
+}
+
+// Returns the secure tag value of given tag, kept as is when already a secure tag value
+func (b *FirewallPolicyBackend) secureTag(tag string) (string, error) {
+	if strings.HasPrefix(tag, "tagValues/") {
+		return tag, nil
+	}
+	value, ok := b.secureTags[tag]
+	if !ok {
+		return "", NewBadRequestError(fmt.Sprintf("Tag [%s] has no secure tag value on firewall policy [%s]", tag, b.name))
+	}
+	return value, nil
+}
+
+// Returns the network tag mapped to given secure tag value, or the value itself
+func (b *FirewallPolicyBackend) networkTag(value string) string {
+	if tag, ok := b.networkTags[value]; ok {
+		return tag
+	}
+	return value
+}
+
+// appliesToProject returns whether given hierarchical policy rule targets a network of given project.
+// Rules without target resources apply to all networks below the policy, so belong to no host project
+func appliesToProject(policyRule *compute.FirewallPolicyRule, project string) bool {
+	for _, resource := range policyRule.TargetResources {
+		if inProject(resource, project) {
+			return true
+		}
+	}
+	return false
+}
+
+// inProject returns whether given resource, as a self-link or a relative resource name, belongs to given project
+func inProject(resource, project string) bool {
+	return strings.HasPrefix(resource, "projects/"+project+"/") || strings.Contains(resource, "/projects/"+project+"/")
+}
+
+// Translates a firewall policy rule to a rule
+func (b *FirewallPolicyBackend) newRule(policyRule *compute.FirewallPolicyRule) *Rule {
+	rule := &Rule{
+		Name:                  policyRule.RuleName,
+		Description:           policyRule.Description,
+		Direction:             policyRule.Direction,
+		Action:                policyRule.Action,
+		Priority:              policyRule.Priority,
+		Disabled:              policyRule.Disabled,
+		Logging:               policyRule.EnableLogging,
+		TargetServiceAccounts: policyRule.TargetServiceAccounts,
+	}
+	if b.targetResources && len(policyRule.TargetResources) == 1 {
+		rule.Network = policyRule.TargetResources[0]
+	}
+
+	if policyRule.Match != nil {
+		rule.SourceRanges = policyRule.Match.SrcIpRanges
+		rule.DestinationRanges = policyRule.Match.DestIpRanges
+		for _, config := range policyRule.Match.Layer4Configs {
+			rule.Protocols = append(rule.Protocols, RuleProtocol{Protocol: config.IpProtocol, Ports: config.Ports})
+		}
+		for _, tag := range policyRule.Match.SrcSecureTags {
+			rule.SourceTags = append(rule.SourceTags, b.networkTag(tag.Name))
+		}
+	}
+	for _, tag := range policyRule.TargetSecureTags {
+		rule.TargetTags = append(rule.TargetTags, b.networkTag(tag.Name))
+	}
+
+	return rule
+}
+
+// Global network firewall policy of a host project
+type networkFirewallPolicy struct {
+	service *compute.NetworkFirewallPoliciesService
+	project string
+	policy  string
+}
+
+func (p networkFirewallPolicy) get() (*compute.FirewallPolicy, error) {
+	policy, err := p.service.Get(p.project, p.policy).Context(context.Background()).Do()
+	return policy, googleError(err)
+}
+
+func (p networkFirewallPolicy) addRule(rule *compute.FirewallPolicyRule) error {
+	_, err := p.service.AddRule(p.project, p.policy, rule).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+func (p networkFirewallPolicy) patchRule(priority int64, rule *compute.FirewallPolicyRule) error {
+	_, err := p.service.PatchRule(p.project, p.policy, rule).Priority(priority).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+func (p networkFirewallPolicy) removeRule(priority int64) error {
+	_, err := p.service.RemoveRule(p.project, p.policy).Priority(priority).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+// Hierarchical firewall policy, attached to an organization or a folder
+type hierarchicalFirewallPolicy struct {
+	service *compute.FirewallPoliciesService
+	policy  string
+}
+
+func (p hierarchicalFirewallPolicy) get() (*compute.FirewallPolicy, error) {
+	policy, err := p.service.Get(p.policy).Context(context.Background()).Do()
+	return policy, googleError(err)
+}
+
+func (p hierarchicalFirewallPolicy) addRule(rule *compute.FirewallPolicyRule) error {
+	_, err := p.service.AddRule(p.policy, rule).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+func (p hierarchicalFirewallPolicy) patchRule(priority int64, rule *compute.FirewallPolicyRule) error {
+	_, err := p.service.PatchRule(p.policy, rule).Priority(priority).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+func (p hierarchicalFirewallPolicy) removeRule(priority int64) error {
+	_, err := p.service.RemoveRule(p.policy).Priority(priority).Context(context.Background()).Do()
+	return googleError(err)
+}
+
+// googleError returns Google errors as application errors, other errors as is
+func googleError(err error) error {
+	if e, ok := err.(*googleapi.Error); ok {
+		return NewGoogleApplicationError(e)
+	}
+	return err
+}
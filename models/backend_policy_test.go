@@ -0,0 +1,91 @@
+package models
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFirewallPolicyRuleTranslation(t *testing.T) {
+	rule := &Rule{
+		Name:         "sp-web-https",
+		Description:  "HTTPS from offices",
+		Network:      "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network",
+		Direction:    RuleDirectionIngress,
+		Action:       RuleActionDeny,
+		Priority:     900,
+		Protocols:    []RuleProtocol{RuleProtocol{Protocol: "tcp", Ports: []string{"443"}}},
+		SourceRanges: []string{"10.0.0.0/8"},
+		SourceTags:   []string{"tagValues/1"},
+		TargetTags:   []string{"sp-web-https"},
+		Disabled:     true,
+		Logging:      true,
+	}
+	secureTags := map[string]string{"sp-web-https": "tagValues/2"}
+
+	tests := []struct {
+		Title           string
+		Backend         *FirewallPolicyBackend
+		TargetResources []string
+		Network         string
+	}{
+		{Title: "Network firewall policy", Backend: NewNetworkFirewallPolicyBackend(nil, "lh-policy", secureTags), Network: ""},
+		{Title: "Hierarchical firewall policy", Backend: NewHierarchicalFirewallPolicyBackend(nil, "123", secureTags), TargetResources: []string{rule.Network}, Network: rule.Network},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			policyRule, err := test.Backend.policyRule("host", rule)
+			if err != nil {
+				t.Fatalf("Unexpected error. Got %v", err)
+			}
+			if policyRule.RuleName != rule.Name || policyRule.Match.Layer4Configs[0].IpProtocol != "tcp" || policyRule.TargetSecureTags[0].Name != "tagValues/2" {
+				t.Errorf("Unexpected policy rule. Got %+v", policyRule)
+			}
+			if !reflect.DeepEqual(policyRule.TargetResources, test.TargetResources) {
+				t.Errorf("Wrong target resources. Got %v want %v", policyRule.TargetResources, test.TargetResources)
+			}
+
+			// Network is lost by network policies, which apply rules to the networks they are associated with
+			expected := *rule
+			expected.Network = test.Network
+			translated := test.Backend.newRule(policyRule)
+			if !reflect.DeepEqual(translated, &expected) {
+				t.Errorf("Translation is not reversible. Got %+v want %+v", translated, &expected)
+			}
+		})
+	}
+}
+
+func TestFirewallPolicyRuleUntranslatable(t *testing.T) {
+	network := "projects/host/global/networks/lh-network"
+	tests := []struct {
+		Title   string
+		Backend *FirewallPolicyBackend
+		Rule    Rule
+		Valid   bool
+	}{
+		{Title: "Unmapped network tag", Backend: NewNetworkFirewallPolicyBackend(nil, "lh-policy", nil), Rule: Rule{TargetTags: []string{"sp-web-https"}}},
+		{Title: "Source service accounts", Backend: NewNetworkFirewallPolicyBackend(nil, "lh-policy", nil), Rule: Rule{SourceServiceAccounts: []string{"web@sp.iam.gserviceaccount.com"}}},
+		{Title: "Target service accounts and tags", Backend: NewNetworkFirewallPolicyBackend(nil, "lh-policy", nil), Rule: Rule{TargetTags: []string{"tagValues/2"}, TargetServiceAccounts: []string{"web@sp.iam.gserviceaccount.com"}}},
+		{Title: "Logging metadata excluded", Backend: NewNetworkFirewallPolicyBackend(nil, "lh-policy", nil), Rule: Rule{Logging: true, LoggingMetadata: LoggingExcludeAllMetadata}},
+		{Title: "Missing network", Backend: NewHierarchicalFirewallPolicyBackend(nil, "123", nil), Rule: Rule{}},
+		{Title: "Network of another project", Backend: NewHierarchicalFirewallPolicyBackend(nil, "123", nil), Rule: Rule{Network: "projects/other-host/global/networks/lh-network"}},
+		{Title: "Valid rule", Backend: NewHierarchicalFirewallPolicyBackend(nil, "123", nil), Rule: Rule{Network: network, TargetServiceAccounts: []string{"web@sp.iam.gserviceaccount.com"}}, Valid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			_, err := test.Backend.policyRule("host", &test.Rule)
+			if test.Valid {
+				if err != nil {
+					t.Errorf("Unexpected error. Got %v", err)
+				}
+				return
+			}
+			if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusBadRequest {
+				t.Errorf("Expected a bad request error. Got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestBackendResolver(t *testing.T) {
+	target := models.NewBackendTarget(config.HostProject{Project: "host-a"})
+	broken := models.BackendTarget{Backend: config.BackendNetworkFirewallPolicy, FirewallPolicy: "broken"}
+	if target.Backend != config.BackendVPCFirewall {
+		t.Errorf("Expected VPC firewall rules by default. Got %+v", target)
+	}
+
+	credentials := models.NewCredentialResolver(map[string]string{"host-a": "sa@host.iam.gserviceaccount.com", "host-b": "sa@host.iam.gserviceaccount.com"}, func(serviceAccount string) ([]option.ClientOption, error) {
+		return []option.ClientOption{option.WithQuotaProject(serviceAccount)}, nil
+	}, nil)
+
+	built := make(map[models.BackendTarget]int)
+	managers := make(map[models.BackendTarget]*fakes.FirewallRuleDummyClient)
+	fail := true
+	resolver := models.NewBackendResolver(credentials, map[string]models.BackendTarget{"host-a": target, "host-b": target, "host-c": broken}, func(target models.BackendTarget, opts ...option.ClientOption) (models.FirewallRuleManager, error) {
+		built[target]++
+		if len(opts) != 1 {
+			t.Errorf("Expected the client options of the credentials. Got %v", opts)
+		}
+		if target == broken && fail {
+			return nil, errors.New("broken")
+		}
+		manager, _ := fakes.NewFirewallRuleDummyClient()
+		managers[target] = manager
+		return manager, nil
+	})
+
+	for _, project := range []string{"host-a", "host-b", "host-d"} {
+		_, err := resolver.CreateFirewallRule(project, &compute.Firewall{Name: "rule"})
+		if err != nil {
+			t.Fatalf("Unexpected error. Got %v", err)
+		}
+	}
+
+	// Host projects sharing a target and credentials share its manager, others use VPC firewall rules with default credentials
+	impersonated := resolver.Target("host-a")
+	defaultTarget := resolver.Target("host-d")
+	if impersonated.ServiceAccount != "sa@host.iam.gserviceaccount.com" || defaultTarget.ServiceAccount != "" || defaultTarget.Backend != config.BackendVPCFirewall {
+		t.Errorf("Unexpected targets %+v and %+v", impersonated, defaultTarget)
+	}
+	if built[impersonated] != 1 || built[defaultTarget] != 1 {
+		t.Errorf("Expected a manager built once by target. Got %v", built)
+	}
+	if len(managers[impersonated].Rules) != 2 || len(managers[defaultTarget].Rules["host-d"]) != 1 {
+		t.Errorf("Rules created on wrong backend. Got %v and %v", managers[impersonated].Rules, managers[defaultTarget].Rules)
+	}
+
+	// Failures are retried
+	_, err := resolver.ListFirewallRule("host-c")
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	fail = false
+	_, err = resolver.CreateFirewallRule("host-c", &compute.Firewall{Name: "rule"})
+	if err != nil || built[broken] != 2 {
+		t.Errorf("Expected manager to be built again. Got %v after %d builds", err, built[broken])
+	}
+}
+
+func newComputeService(t *testing.T, srv *fakes.FirewallPolicyServer) *compute.Service {
+	t.Cleanup(srv.Close)
+	computeService, err := compute.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return computeService
+}
+
+func TestFirewallPolicyBackend(t *testing.T) {
+	srv := fakes.NewFirewallPolicyServer("lh-policy", []*compute.FirewallPolicyRule{
+		&compute.FirewallPolicyRule{RuleName: "sp-web-https", Action: "allow", Priority: 1000},
+		&compute.FirewallPolicyRule{RuleName: "sp-web-ssh", Action: "deny", Priority: 1001},
+		&compute.FirewallPolicyRule{RuleName: "default-egress", Action: "goto_next", Priority: 2147483645},
+	})
+	backend := models.NewNetworkFirewallPolicyBackend(newComputeService(t, srv), "lh-policy", nil)
+
+	rules, err := backend.ListRules("host")
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("Expected rules of other actions to be ignored. Got %v, %v", rules, err)
+	}
+
+	_, err = backend.CreateRule("host", &models.Rule{Name: "sp-web-https"})
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict. Got %v", err)
+	}
+
+	// Next unused priority is given to rules without one
+	created, err := backend.CreateRule("host", &models.Rule{Name: "sp-web-http", Action: models.RuleActionAllow})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if created.Priority != 1002 {
+		t.Errorf("Wrong priority. Got %d want 1002", created.Priority)
+	}
+
+	// Rules are identified by their priority
+	_, err = backend.UpdateRule("host", &models.Rule{Name: "sp-web-ssh", Action: models.RuleActionAllow})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	err = backend.DeleteRule("host", "sp-web-https")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := []string{"addRule 1002", "patchRule 1001", "removeRule 1000"}
+	if !reflect.DeepEqual(srv.Calls, expected) {
+		t.Errorf("Unexpected calls. Got %v want %v", srv.Calls, expected)
+	}
+
+	err = backend.DeleteRule("host", "sp-web-unknown")
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+}
+
+func TestHierarchicalFirewallPolicyBackend(t *testing.T) {
+	srv := fakes.NewFirewallPolicyServer("123", []*compute.FirewallPolicyRule{
+		&compute.FirewallPolicyRule{RuleName: "sp-web-https", Action: "allow", Priority: 1000, TargetResources: []string{"https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"}},
+		&compute.FirewallPolicyRule{RuleName: "other-sp-web-https", Action: "allow", Priority: 1001, TargetResources: []string{"https://www.googleapis.com/compute/v1/projects/other-host/global/networks/lh-network"}},
+		&compute.FirewallPolicyRule{RuleName: "all-networks", Action: "deny", Priority: 1002},
+	})
+	backend := models.NewHierarchicalFirewallPolicyBackend(newComputeService(t, srv), "123", nil)
+
+	// Rules of other host projects sharing the policy, or of all its networks, are hidden
+	rules, err := backend.ListRules("host")
+	if err != nil || len(rules) != 1 || rules[0].Name != "sp-web-https" {
+		t.Fatalf("Expected rules of host networks only. Got %v, %v", rules, err)
+	}
+	err = backend.DeleteRule("host", "other-sp-web-https")
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+
+	// Names and priorities are unique in the whole policy
+	_, err = backend.CreateRule("host", &models.Rule{Name: "other-sp-web-https", Network: "global/networks/lh-network"})
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict. Got %v", err)
+	}
+	created, err := backend.CreateRule("host", &models.Rule{Name: "sp-web-http", Network: "global/networks/lh-network"})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if created.Priority != 1003 || created.Network != "projects/host/global/networks/lh-network" {
+		t.Errorf("Unexpected rule. Got %+v", created)
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// VPCFirewallBackend manages GCP VPC firewall rules of host projects. Implements RuleBackend
+type VPCFirewallBackend struct {
+	computeService *compute.Service
+}
+
+// NewVPCFirewallBackend VPCFirewallBackend constructor
+func NewVPCFirewallBackend(computeService *compute.Service) *VPCFirewallBackend {
+	return &VPCFirewallBackend{computeService: computeService}
+}
+
+// newComputeService returns a compute service with default credentials, unless overridden by given options
+func newComputeService(opts ...option.ClientOption) (*compute.Service, error) {
+	c, err := compute.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(compute.CloudPlatformScope)}, opts...)...)
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListRules returns given project's firewall rules
+func (b *VPCFirewallBackend) ListRules(project string) ([]*Rule, error) {
+	var rules []*Rule
+	err := b.computeService.Firewalls.List(project).Pages(context.Background(), func(page *compute.FirewallList) error {
+		for _, firewall := range page.Items {
+			rules = append(rules, NewRuleFromFirewall(firewall))
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetRule returns firewall rule matching given project and name
+func (b *VPCFirewallBackend) GetRule(project, name string) (*Rule, error) {
+	firewall, err := b.computeService.Firewalls.Get(project, name).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleFromFirewall(firewall), nil
+}
+
+// CreateRule create given firewall rule on given project
+func (b *VPCFirewallBackend) CreateRule(project string, rule *Rule) (*Rule, error) {
+	_, err := b.computeService.Firewalls.Insert(project, rule.Firewall()).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.GetRule(project, rule.Name)
+}
+
+// UpdateRule replace the firewall rule matching given rule's name on given project
+func (b *VPCFirewallBackend) UpdateRule(project string, rule *Rule) (*Rule, error) {
+	_, err := b.computeService.Firewalls.Update(project, rule.Name, rule.Firewall()).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return b.GetRule(project, rule.Name)
+}
+
+// DeleteRule delete firewall rule matching given project and name
+func (b *VPCFirewallBackend) DeleteRule(project, name string) error {
+	_, err := b.computeService.Firewalls.Delete(project, name).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return NewGoogleApplicationError(e)
+	}
+	return err
+}
+
+// GetRuleQuota returns the firewall rules quota of given project
+func (b *VPCFirewallBackend) GetRuleQuota(project string) (*RuleQuota, error) {
+	p, err := b.computeService.Projects.Get(project).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, quota := range p.Quotas {
+		if quota.Metric == "FIREWALLS" {
+			return &RuleQuota{Usage: quota.Usage, Limit: quota.Limit}, nil
+		}
+	}
+
+	return nil, NewNotFoundError(fmt.Sprintf("Firewall rules quota not found in project [%s]", project))
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Connectivity verdicts
+const (
+	ConnectivityAllowed = "ALLOWED"
+	ConnectivityDenied  = "DENIED"
+)
+
+// Names of the rules Google applies when no other rule matches
+const (
+	ImpliedDenyIngressRule = "implied-deny-ingress"
+	ImpliedAllowEgressRule = "implied-allow-egress"
+)
+
+// ConnectivityQuery describe traffic to or from application instances.
+// Ingress traffic comes from Source, with SourceTags or SourceServiceAccount when it comes from an instance of the network.
+// Egress traffic goes to Destination
+type ConnectivityQuery struct {
+	Direction            string   `json:"direction,omitempty"`
+	Network              string   `json:"network,omitempty"`
+	Source               string   `json:"source,omitempty"`
+	SourceTags           []string `json:"source_tags,omitempty"`
+	SourceServiceAccount string   `json:"source_service_account,omitempty"`
+	Destination          string   `json:"destination,omitempty"`
+	TargetTags           []string `json:"target_tags,omitempty"`
+	ServiceAccount       string   `json:"service_account,omitempty"`
+	Protocol             string   `json:"protocol"`
+	Port                 int      `json:"port,omitempty"`
+}
+
+// ConnectivityResult describe an end-user response of a connectivity query, with the rule deciding of the verdict
+type ConnectivityResult struct {
+	Project        string            `json:"project"`
+	ServiceProject string            `json:"service_project"`
+	Application    string            `json:"application"`
+	Query          ConnectivityQuery `json:"query"`
+	Verdict        string            `json:"verdict"`
+	RuleName       string            `json:"rule"`
+	Priority       int64             `json:"priority"`
+	Rule           *compute.Firewall `json:"item,omitempty"`
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// ClientOptionsFactory builds the options of Google clients impersonating given service account,
+// or using default credentials when empty
+type ClientOptionsFactory func(serviceAccount string) ([]option.ClientOption, error)
+
+// ProjectClients gathers the Google clients reaching a host project and its service projects, but its firewall rules
+type ProjectClients struct {
+	Google    GoogleClientInterface
+	Instances InstanceManager
+	Networks  NetworkManager
+}
+
+// ProjectClientsFactory builds the Google clients of a host project with given client options
+type ProjectClientsFactory func(opts ...option.ClientOption) (*ProjectClients, error)
+
+// CredentialResolver resolves the credentials mapped to each host project, and the Google clients using them.
+// Credentials and clients are built on first use, and shared by host projects mapped to the same service account.
+// They are built outside of the lock, once at a time by service account, so that a slow service account does not hold the others
+type CredentialResolver struct {
+	serviceAccounts map[string]string
+	optionsFactory  ClientOptionsFactory
+	clientsFactory  ProjectClientsFactory
+
+	mu       sync.Mutex
+	options  map[string][]option.ClientOption
+	clients  map[string]*ProjectClients
+	building map[string]*clientsBuild
+}
+
+// Clients being built for a service account. Done is closed once clients or err are set
+type clientsBuild struct {
+	done    chan struct{}
+	clients *ProjectClients
+	err     error
+}
+
+// NewCredentialResolver CredentialResolver constructor. Host projects absent from given service accounts use default credentials
+func NewCredentialResolver(serviceAccounts map[string]string, optionsFactory ClientOptionsFactory, clientsFactory ProjectClientsFactory) *CredentialResolver {
+	return &CredentialResolver{
+		serviceAccounts: serviceAccounts,
+		optionsFactory:  optionsFactory,
+		clientsFactory:  clientsFactory,
+		options:         make(map[string][]option.ClientOption),
+		clients:         make(map[string]*ProjectClients),
+		building:        make(map[string]*clientsBuild),
+	}
+}
+
+// NewClientOptions returns the options of Google clients impersonating given service account, or none for default credentials
+func NewClientOptions(serviceAccount string) ([]option.ClientOption, error) {
+	if serviceAccount == "" {
+		return nil, nil
+	}
+
+	ts, err := NewTokenSource(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
+}
+
+// NewTokenSource returns the source of access tokens of given service account.
+// Default credentials must be granted roles/iam.serviceAccountTokenCreator on it.
+// Access tokens are generated through IAM Credentials API, and reused until they expire
+// https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/generateAccessToken
+func NewTokenSource(serviceAccount string) (oauth2.TokenSource, error) {
+	return impersonate.CredentialsTokenSource(context.Background(), impersonate.CredentialsConfig{
+		TargetPrincipal: serviceAccount,
+		Scopes:          []string{compute.CloudPlatformScope},
+	})
+}
+
+// NewProjectClients returns Google clients with given client options
+func NewProjectClients(opts ...option.ClientOption) (*ProjectClients, error) {
+	googleClient, err := NewGoogleClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	instanceClient, err := NewInstanceClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	networkClient, err := NewNetworkClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProjectClients{Google: googleClient, Instances: instanceClient, Networks: networkClient}, nil
+}
+
+// ServiceAccount returns the service account impersonated to reach given host project, empty for default credentials
+func (c *CredentialResolver) ServiceAccount(project string) string {
+	return c.serviceAccounts[project]
+}
+
+// ServiceAccounts returns the service accounts mapped to host projects, sorted and without duplicates
+func (c *CredentialResolver) ServiceAccounts() []string {
+	seen := make(map[string]bool)
+	var serviceAccounts []string
+	for _, serviceAccount := range c.serviceAccounts {
+		if serviceAccount != "" && !seen[serviceAccount] {
+			seen[serviceAccount] = true
+			serviceAccounts = append(serviceAccounts, serviceAccount)
+		}
+	}
+	sort.Strings(serviceAccounts)
+	return serviceAccounts
+}
+
+// ClientOptions returns the options of Google clients of given host project. Failures to build them are not kept, so that next calls retry
+func (c *CredentialResolver) ClientOptions(project string) ([]option.ClientOption, error) {
+	return c.clientOptions(c.ServiceAccount(project))
+}
+
+// clientOptions returns the options of Google clients impersonating given service account, built outside of the lock
+func (c *CredentialResolver) clientOptions(serviceAccount string) ([]option.ClientOption, error) {
+	c.mu.Lock()
+	opts, ok := c.options[serviceAccount]
+	c.mu.Unlock()
+	if ok {
+		return opts, nil
+	}
+
+	opts, err := c.optionsFactory(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the options built first, for clients to share them
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if built, ok := c.options[serviceAccount]; ok {
+		return built, nil
+	}
+	c.options[serviceAccount] = opts
+	return opts, nil
+}
+
+// Clients returns the Google clients of given host project. Failures to build them are not kept, so that next calls retry.
+// Calls for a service account whose clients are being built wait for them
+func (c *CredentialResolver) Clients(project string) (*ProjectClients, error) {
+	serviceAccount := c.ServiceAccount(project)
+
+	c.mu.Lock()
+	if clients, ok := c.clients[serviceAccount]; ok {
+		c.mu.Unlock()
+		return clients, nil
+	}
+	if build, ok := c.building[serviceAccount]; ok {
+		c.mu.Unlock()
+		<-build.done
+		return build.clients, build.err
+	}
+	build := &clientsBuild{done: make(chan struct{})}
+	c.building[serviceAccount] = build
+	c.mu.Unlock()
+
+	build.clients, build.err = c.buildClients(serviceAccount)
+
+	c.mu.Lock()
+	delete(c.building, serviceAccount)
+	if build.err == nil {
+		c.clients[serviceAccount] = build.clients
+	}
+	c.mu.Unlock()
+	close(build.done)
+
+	return build.clients, build.err
+}
+
+// buildClients returns new Google clients impersonating given service account
+func (c *CredentialResolver) buildClients(serviceAccount string) (*ProjectClients, error) {
+	opts, err := c.clientOptions(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	return c.clientsFactory(opts...)
+}
+
+// GoogleClient returns the Google client of given host project. Its clients are built on first call
+func (c *CredentialResolver) GoogleClient(project string) GoogleClientInterface {
+	return projectGoogleClient{resolver: c, project: project}
+}
+
+// InstanceManager returns the instance manager of given host project and its service projects. Its clients are built on first call
+func (c *CredentialResolver) InstanceManager(project string) InstanceManager {
+	return projectInstanceManager{resolver: c, project: project}
+}
+
+// NetworkManager returns the network manager of given host project. Its clients are built on first call
+func (c *CredentialResolver) NetworkManager(project string) NetworkManager {
+	return projectNetworkManager{resolver: c, project: project}
+}
+
+// Google client using the credentials of a host project
+type projectGoogleClient struct {
+	resolver *CredentialResolver
+	project  string
+}
+
+func (c projectGoogleClient) IsProjectOwner(user string, projectID string) error {
+	clients, err := c.resolver.Clients(c.project)
+	if err != nil {
+		return err
+	}
+	return clients.Google.IsProjectOwner(user, projectID)
+}
+
+func (c projectGoogleClient) IsAServiceProjectOf(projectA, projectB string) error {
+	clients, err := c.resolver.Clients(c.project)
+	if err != nil {
+		return err
+	}
+	return clients.Google.IsAServiceProjectOf(projectA, projectB)
+}
+
+func (c projectGoogleClient) ListServiceProjects(project string) ([]string, error) {
+	clients, err := c.resolver.Clients(c.project)
+	if err != nil {
+		return nil, err
+	}
+	return clients.Google.ListServiceProjects(project)
+}
+
+// Instance manager using the credentials of a host project
+type projectInstanceManager struct {
+	resolver *CredentialResolver
+	project  string
+}
+
+func (m projectInstanceManager) ListInstancesWithTag(project, tag string) ([]*compute.Instance, error) {
+	clients, err := m.resolver.Clients(m.project)
+	if err != nil {
+		return nil, err
+	}
+	return clients.Instances.ListInstancesWithTag(project, tag)
+}
+
+func (m projectInstanceManager) GetInstance(project, name string) (*compute.Instance, error) {
+	clients, err := m.resolver.Clients(m.project)
+	if err != nil {
+		return nil, err
+	}
+	return clients.Instances.GetInstance(project, name)
+}
+
+func (m projectInstanceManager) SetInstanceTags(project string, instance *compute.Instance, tags []string) error {
+	clients, err := m.resolver.Clients(m.project)
+	if err != nil {
+		return err
+	}
+	return clients.Instances.SetInstanceTags(project, instance, tags)
+}
+
+// Network manager using the credentials of a host project
+type projectNetworkManager struct {
+	resolver *CredentialResolver
+	project  string
+}
+
+func (m projectNetworkManager) ListSharedNetworks(project, serviceProject string) ([]Network, error) {
+	clients, err := m.resolver.Clients(m.project)
+	if err != nil {
+		return nil, err
+	}
+	return clients.Networks.ListSharedNetworks(project, serviceProject)
+}
@@ -0,0 +1,121 @@
+package models_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/option"
+)
+
+func TestCredentialResolver(t *testing.T) {
+	built := make(map[string]int)
+	fail := true
+	resolver := models.NewCredentialResolver(map[string]string{"host-a": "sa@host.iam.gserviceaccount.com", "host-b": "sa@host.iam.gserviceaccount.com", "host-c": "broken@host.iam.gserviceaccount.com"}, func(serviceAccount string) ([]option.ClientOption, error) {
+		built[serviceAccount]++
+		if serviceAccount == "broken@host.iam.gserviceaccount.com" && fail {
+			return nil, errors.New("broken")
+		}
+		return []option.ClientOption{option.WithQuotaProject(serviceAccount)}, nil
+	}, func(opts ...option.ClientOption) (*models.ProjectClients, error) {
+		return &models.ProjectClients{
+			Google:    &fakes.GoogleDummyClient{Owners: map[string][]string{"sp": []string{"user@example.com"}}},
+			Instances: &fakes.InstanceDummyClient{},
+			Networks:  &fakes.NetworkDummyClient{},
+		}, nil
+	})
+
+	for _, project := range []string{"host-a", "host-b", "host-d"} {
+		err := resolver.GoogleClient(project).IsProjectOwner("user@example.com", "sp")
+		if err != nil {
+			t.Fatalf("Unexpected error. Got %v", err)
+		}
+	}
+
+	// Host projects sharing a service account share its credentials and clients, others use default credentials
+	if built["sa@host.iam.gserviceaccount.com"] != 1 || built[""] != 1 {
+		t.Errorf("Expected credentials built once by service account. Got %v", built)
+	}
+	clientsA, _ := resolver.Clients("host-a")
+	clientsB, _ := resolver.Clients("host-b")
+	clientsD, _ := resolver.Clients("host-d")
+	if clientsA != clientsB || clientsA == clientsD {
+		t.Error("Expected clients shared by service account")
+	}
+	if resolver.ServiceAccount("host-d") != "" {
+		t.Errorf("Expected default credentials. Got %s", resolver.ServiceAccount("host-d"))
+	}
+
+	// Failures are retried, and reported by the calls needing the clients
+	_, err := resolver.NetworkManager("host-c").ListSharedNetworks("host-c", "sp")
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	_, err = resolver.InstanceManager("host-c").GetInstance("sp", "vm")
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	fail = false
+	err = resolver.GoogleClient("host-c").IsProjectOwner("other@example.com", "sp")
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusForbidden || built["broken@host.iam.gserviceaccount.com"] != 3 {
+		t.Errorf("Expected credentials to be built again. Got %v after %d builds", err, built["broken@host.iam.gserviceaccount.com"])
+	}
+}
+
+func TestCredentialResolverConcurrentBuilds(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	built := make(map[string]int)
+	resolver := models.NewCredentialResolver(map[string]string{"host-a": "sa@host.iam.gserviceaccount.com", "host-slow": "slow@host.iam.gserviceaccount.com", "host-slow-2": "slow@host.iam.gserviceaccount.com"}, func(serviceAccount string) ([]option.ClientOption, error) {
+		mu.Lock()
+		built[serviceAccount]++
+		mu.Unlock()
+		if serviceAccount == "slow@host.iam.gserviceaccount.com" {
+			close(started)
+			<-release
+		}
+		return nil, nil
+	}, func(opts ...option.ClientOption) (*models.ProjectClients, error) {
+		return &models.ProjectClients{}, nil
+	})
+
+	if !reflect.DeepEqual(resolver.ServiceAccounts(), []string{"sa@host.iam.gserviceaccount.com", "slow@host.iam.gserviceaccount.com"}) {
+		t.Errorf("Unexpected service accounts. Got %v", resolver.ServiceAccounts())
+	}
+
+	slow := make(chan *models.ProjectClients, 2)
+	for _, project := range []string{"host-slow", "host-slow-2"} {
+		go func(project string) {
+			clients, _ := resolver.Clients(project)
+			slow <- clients
+		}(project)
+		if project == "host-slow" {
+			<-started
+		}
+	}
+
+	// Other service accounts are not held by the slow one
+	done := make(chan struct{})
+	go func() {
+		resolver.Clients("host-a")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Clients of other service accounts should not wait for the slow one")
+	}
+
+	// Calls for the slow service account share a single build
+	close(release)
+	clients1, clients2 := <-slow, <-slow
+	if clients1 == nil || clients1 != clients2 || built["slow@host.iam.gserviceaccount.com"] != 1 {
+		t.Errorf("Expected clients built once. Got %d builds", built["slow@host.iam.gserviceaccount.com"])
+	}
+}
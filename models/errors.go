@@ -71,11 +71,17 @@ func NewMethodNotAllowedError() *ApplicationError {
 }
 
 // NewNotFoundError describe a http error response 404 Not Found
-func NewNotFoundError() *ApplicationError {
-	return &ApplicationError{
+func NewNotFoundError(message ...string) *ApplicationError {
+	e := &ApplicationError{
 		Code:    http.StatusNotFound,
 		Message: http.StatusText(http.StatusNotFound),
 	}
+
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+
+	return e
 }
 
 // NewBadRequestError describe a http error response 400 Bad Request
@@ -91,3 +97,59 @@ func NewBadRequestError(message ...string) *ApplicationError {
 
 	return e
 }
+
+// NewPreconditionFailedError describe a http error response 412 Precondition Failed
+func NewPreconditionFailedError(message ...string) *ApplicationError {
+	e := &ApplicationError{
+		Code:    http.StatusPreconditionFailed,
+		Message: http.StatusText(http.StatusPreconditionFailed),
+	}
+
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+
+	return e
+}
+
+// NewUnprocessableEntityError describe a http error response 422 Unprocessable Entity
+func NewUnprocessableEntityError(message ...string) *ApplicationError {
+	e := &ApplicationError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: http.StatusText(http.StatusUnprocessableEntity),
+	}
+
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+
+	return e
+}
+
+// NewConflictError describe a http error response 409 Conflict
+func NewConflictError(message ...string) *ApplicationError {
+	e := &ApplicationError{
+		Code:    http.StatusConflict,
+		Message: http.StatusText(http.StatusConflict),
+	}
+
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+
+	return e
+}
+
+// NewServiceUnavailableError describe a http error response 503 Service Unavailable
+func NewServiceUnavailableError(message ...string) *ApplicationError {
+	e := &ApplicationError{
+		Code:    http.StatusServiceUnavailable,
+		Message: http.StatusText(http.StatusServiceUnavailable),
+	}
+
+	if len(message) > 0 {
+		e.Message = message[0]
+	}
+
+	return e
+}
@@ -385,3 +385,207 @@ func TestNewBadRequestError(t *testing.T) {
 		})
 	}
 }
+
+func TestNewPreconditionFailedError(t *testing.T) {
+	expectedError1 := ApplicationError{
+		Code:    http.StatusPreconditionFailed,
+		Message: http.StatusText(http.StatusPreconditionFailed),
+	}
+
+	expectedError2 := ApplicationError{
+		Code:    http.StatusPreconditionFailed,
+		Message: "foo",
+	}
+
+	// Execute function
+	testedError1 := NewPreconditionFailedError()
+	testedError2 := NewPreconditionFailedError("foo")
+
+	suite := []TestCase{
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError1 == nil,
+		},
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError2 == nil,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError1.Code,
+			Got:      testedError1.Code,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError2.Code,
+			Got:      testedError2.Code,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError1.Message,
+			Got:      testedError1.Message,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError2.Message,
+			Got:      testedError2.Message,
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError1.Code, expectedError1.Message),
+			Got:      testedError1.Error(),
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError2.Code, expectedError2.Message),
+			Got:      testedError2.Error(),
+		},
+	}
+
+	// Launch test
+	for _, suiteCase := range suite {
+		t.Run(suiteCase.Title, func(t *testing.T) {
+			if suiteCase.Expected != suiteCase.Got {
+				t.Errorf("Got '%v' want '%v'", suiteCase.Got, suiteCase.Expected)
+			}
+		})
+	}
+}
+
+func TestNewUnprocessableEntityError(t *testing.T) {
+	expectedError1 := ApplicationError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: http.StatusText(http.StatusUnprocessableEntity),
+	}
+
+	expectedError2 := ApplicationError{
+		Code:    http.StatusUnprocessableEntity,
+		Message: "foo",
+	}
+
+	// Execute function
+	testedError1 := NewUnprocessableEntityError()
+	testedError2 := NewUnprocessableEntityError("foo")
+
+	suite := []TestCase{
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError1 == nil,
+		},
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError2 == nil,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError1.Code,
+			Got:      testedError1.Code,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError2.Code,
+			Got:      testedError2.Code,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError1.Message,
+			Got:      testedError1.Message,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError2.Message,
+			Got:      testedError2.Message,
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError1.Code, expectedError1.Message),
+			Got:      testedError1.Error(),
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError2.Code, expectedError2.Message),
+			Got:      testedError2.Error(),
+		},
+	}
+
+	// Launch test
+	for _, suiteCase := range suite {
+		t.Run(suiteCase.Title, func(t *testing.T) {
+			if suiteCase.Expected != suiteCase.Got {
+				t.Errorf("Got '%v' want '%v'", suiteCase.Got, suiteCase.Expected)
+			}
+		})
+	}
+}
+
+func TestNewConflictError(t *testing.T) {
+	expectedError1 := ApplicationError{
+		Code:    http.StatusConflict,
+		Message: http.StatusText(http.StatusConflict),
+	}
+
+	expectedError2 := ApplicationError{
+		Code:    http.StatusConflict,
+		Message: "foo",
+	}
+
+	// Execute function
+	testedError1 := NewConflictError()
+	testedError2 := NewConflictError("foo")
+
+	suite := []TestCase{
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError1 == nil,
+		},
+		TestCase{
+			Title:    "Error should not be nil",
+			Expected: false,
+			Got:      testedError2 == nil,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError1.Code,
+			Got:      testedError1.Code,
+		},
+		TestCase{
+			Title:    "Error code should be identical",
+			Expected: expectedError2.Code,
+			Got:      testedError2.Code,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError1.Message,
+			Got:      testedError1.Message,
+		},
+		TestCase{
+			Title:    "Error message shoud be identical",
+			Expected: expectedError2.Message,
+			Got:      testedError2.Message,
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError1.Code, expectedError1.Message),
+			Got:      testedError1.Error(),
+		},
+		TestCase{
+			Title:    "Error() method should return error as JSON",
+			Expected: fmt.Sprintf(`{"code":%d,"message":"%s"}`, expectedError2.Code, expectedError2.Message),
+			Got:      testedError2.Error(),
+		},
+	}
+
+	// Launch test
+	for _, suiteCase := range suite {
+		t.Run(suiteCase.Title, func(t *testing.T) {
+			if suiteCase.Expected != suiteCase.Got {
+				t.Errorf("Got '%v' want '%v'", suiteCase.Got, suiteCase.Expected)
+			}
+		})
+	}
+}
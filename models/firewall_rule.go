@@ -1,17 +1,25 @@
 package models
 
 import (
-	"context"
+	"encoding/json"
 
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/compute/v1"
-	"google.golang.org/api/googleapi"
 )
 
 // FirewallRule descibe a firewall rule
 type FirewallRule struct {
 	Rule       compute.Firewall `json:"item"`
 	CustomName string           `json:"custom_name"`
+	// Whether the rule priority is out of its application band, given then as the Google priority
+	OutOfBand bool `json:"out_of_band,omitempty"`
+}
+
+// ruleKey identifies a rule of a host project. Unlike the rule name, it does not mix up service projects, applications
+// and custom names containing hyphens
+type ruleKey struct {
+	serviceProject string
+	application    string
+	customName     string
 }
 
 // FirewallRules describe a set of firewall rule
@@ -25,79 +33,117 @@ type ApplicationRule struct {
 	Rules          FirewallRules `json:"data"`
 }
 
-// FirewallRuleManager contains methods to manage firewall rules
-type FirewallRuleManager interface {
-	ListFirewallRule(project string) ([]*compute.Firewall, error)
-	GetFirewallRule(project, name string) (*compute.Firewall, error)
-	CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error)
-	DeleteFirewallRule(project, name string) error
-}
-
-// FirewallRuleClient provides primitives to collect rules from Google Cloud Platform. Implements FirewallRuleManager
-type FirewallRuleClient struct {
-	computeService *compute.Service
+// FirewallRuleRequest describe a rule sent by a client. Extends Google rule with API specific fields.
+// PriorityUnset tells a decoded request has no priority, which Google rules cannot tell from priority 0
+type FirewallRuleRequest struct {
+	compute.Firewall
+	SourceAddressGroups      []string            `json:"sourceAddressGroups,omitempty"`
+	DestinationAddressGroups []string            `json:"destinationAddressGroups,omitempty"`
+	Services                 []string            `json:"services,omitempty"`
+	DeniedServices           []string            `json:"deniedServices,omitempty"`
+	SourceApplications       []SourceApplication `json:"sourceApplications,omitempty"`
+	PriorityUnset            bool                `json:"-"`
 }
 
-// NewFirewallRuleClient FirewallRuleClient contructor
-func NewFirewallRuleClient() (*FirewallRuleClient, error) {
-	c, err := google.DefaultClient(context.Background(), compute.CloudPlatformScope)
-	if e, ok := err.(*googleapi.Error); ok {
-		return nil, NewGoogleApplicationError(e)
+// UnmarshalJSON decodes a rule request, recording whether it has a priority
+func (r *FirewallRuleRequest) UnmarshalJSON(data []byte) error {
+	type request FirewallRuleRequest
+	var decoded request
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
 	}
 
-	computeService, err := compute.New(c)
-	if e, ok := err.(*googleapi.Error); ok {
-		return nil, NewGoogleApplicationError(e)
+	var priority struct {
+		Priority *int64 `json:"priority"`
+	}
+	err = json.Unmarshal(data, &priority)
+	if err != nil {
+		return err
 	}
 
-	manager := FirewallRuleClient{}
-	manager.computeService = computeService
-	return &manager, nil
+	*r = FirewallRuleRequest(decoded)
+	r.PriorityUnset = priority.Priority == nil
+	return nil
 }
 
-// ListFirewallRule returns given project's firewall rule
-func (f *FirewallRuleClient) ListFirewallRule(project string) ([]*compute.Firewall, error) {
-	req := f.computeService.Firewalls.List(project)
-
-	var firewallRuleList []*compute.Firewall
+// BatchRuleRequest describe a named rule to create within a batch
+type BatchRuleRequest struct {
+	CustomName string              `json:"custom_name"`
+	Rule       FirewallRuleRequest `json:"item"`
+}
 
-	err := req.Pages(context.Background(), func(page *compute.FirewallList) error {
-		for _, firewall := range page.Items {
-			firewallRuleList = append(firewallRuleList, firewall)
-		}
-		return nil
-	})
-	if e, ok := err.(*googleapi.Error); ok {
-		return nil, NewGoogleApplicationError(e)
-	}
+// BatchRuleResult describe the outcome of a single rule operation within a batch
+type BatchRuleResult struct {
+	ServiceProject string            `json:"service_project,omitempty"`
+	Application    string            `json:"application,omitempty"`
+	CustomName     string            `json:"custom_name"`
+	Action         string            `json:"action,omitempty"`
+	Code           int               `json:"code"`
+	Rule           *compute.Firewall `json:"item,omitempty"`
+	OutOfBand      bool              `json:"out_of_band,omitempty"`
+	Error          *ApplicationError `json:"error,omitempty"`
+}
 
-	return firewallRuleList, nil
+// BatchResult describe an end-user batch response
+type BatchResult struct {
+	Project        string            `json:"project"`
+	ServiceProject string            `json:"service_project"`
+	Application    string            `json:"application"`
+	Results        []BatchRuleResult `json:"data"`
 }
 
-// GetFirewallRule returns firewall rule matching given project and name
-func (f *FirewallRuleClient) GetFirewallRule(project, name string) (*compute.Firewall, error) {
-	rules, err := f.computeService.Firewalls.Get(project, name).Context(context.Background()).Do()
-	if e, ok := err.(*googleapi.Error); ok {
-		return nil, NewGoogleApplicationError(e)
-	}
-	return rules, nil
+// Conflict handling options when cloning rules to an application which already has a rule with the same name
+const (
+	CloneConflictFail      = "fail"
+	CloneConflictSkip      = "skip"
+	CloneConflictOverwrite = "overwrite"
+)
+
+// Actions reported for each cloned rule
+const (
+	CloneActionCreate    = "create"
+	CloneActionOverwrite = "overwrite"
+	CloneActionSkip      = "skip"
+)
+
+// CloneRequest describe the destination of an application's rules copy
+type CloneRequest struct {
+	Project        string `json:"project"`
+	ServiceProject string `json:"service_project"`
+	Application    string `json:"application"`
+	Conflict       string `json:"conflict"`
+	DryRun         bool   `json:"dry_run"`
 }
 
-// CreateFirewallRule create given firewall rule on given project
-func (f *FirewallRuleClient) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
-	_, err := f.computeService.Firewalls.Insert(project, rule).Context(context.Background()).Do()
-	if e, ok := err.(*googleapi.Error); ok {
-		return nil, NewGoogleApplicationError(e)
-	}
+// RenameRequest describe the new name of a rule. GracePeriod is a Go duration, such as "24h"
+type RenameRequest struct {
+	Application string `json:"application"`
+	Name        string `json:"name"`
+	KeepOldTag  bool   `json:"keep_old_tag"`
+	GracePeriod string `json:"grace_period"`
+}
 
-	return f.GetFirewallRule(project, rule.Name)
+// RenameResult describe an end-user rename response. CompleteAfter is set while the rename must be completed,
+// and Error reports a step which failed once the new rule was written
+type RenameResult struct {
+	ApplicationRule
+	OldApplication        string            `json:"old_application"`
+	OldCustomName         string            `json:"old_custom_name"`
+	OldTargetTag          string            `json:"old_target_tag"`
+	OldTargetTagInstances []string          `json:"old_target_tag_instances"`
+	OldRuleDeleted        bool              `json:"old_rule_deleted"`
+	CompleteAfter         string            `json:"complete_after,omitempty"`
+	Error                 *ApplicationError `json:"error,omitempty"`
 }
 
-// DeleteFirewallRule delete firewall rule matching given project and name
-func (f *FirewallRuleClient) DeleteFirewallRule(project string, name string) error {
-	_, err := f.computeService.Firewalls.Delete(project, name).Context(context.Background()).Do()
-	if e, ok := err.(*googleapi.Error); ok {
-		return NewGoogleApplicationError(e)
-	}
-	return err
+// FirewallRuleManager contains methods to manage firewall rules, in the format of the API.
+// RuleManager implements it on any RuleBackend
+type FirewallRuleManager interface {
+	ListFirewallRule(project string) ([]*compute.Firewall, error)
+	GetFirewallRule(project, name string) (*compute.Firewall, error)
+	CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error)
+	UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error)
+	DeleteFirewallRule(project, name string) error
+	GetFirewallQuota(project string) (*compute.Quota, error)
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFirewallRuleRequestUnmarshal(t *testing.T) {
+	tests := []struct {
+		Title            string
+		Body             string
+		ExpectedPriority int64
+		ExpectedUnset    bool
+	}{
+		{Title: "Without priority", Body: `{"sourceRanges": ["10.0.0.0/8"], "services": ["https"]}`, ExpectedUnset: true},
+		{Title: "Null priority", Body: `{"priority": null}`, ExpectedUnset: true},
+		{Title: "Priority 0", Body: `{"priority": 0}`},
+		{Title: "Priority", Body: `{"priority": 10, "services": ["https"]}`, ExpectedPriority: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			var request FirewallRuleRequest
+			err := json.Unmarshal([]byte(test.Body), &request)
+			if err != nil {
+				t.Fatalf("Unexpected error. Got %v", err)
+			}
+
+			if request.Priority != test.ExpectedPriority || request.PriorityUnset != test.ExpectedUnset {
+				t.Errorf("Wrong priority. Got %d, unset %v", request.Priority, request.PriorityUnset)
+			}
+		})
+	}
+
+	var request FirewallRuleRequest
+	json.Unmarshal([]byte(`{"sourceRanges": ["10.0.0.0/8"], "services": ["https"]}`), &request)
+	if len(request.SourceRanges) != 1 || len(request.Services) != 1 {
+		t.Errorf("Rule request has not been decoded. Got %+v", request)
+	}
+}
@@ -20,19 +20,31 @@ type GoogleClient struct {
 type GoogleClientInterface interface {
 	IsProjectOwner(user string, projectID string) error
 	IsAServiceProjectOf(projectA, projetB string) error
+	ServiceProjectLister
 }
 
-// NewGoogleClient GoogleClient constructor
-func NewGoogleClient() (*GoogleClient, error) {
-	p, err := cloudresourcemanager.NewService(context.Background(), option.WithScopes(cloudresourcemanager.CloudPlatformReadOnlyScope))
+// ServiceProjectLister lists service projects of host projects
+type ServiceProjectLister interface {
+	ListServiceProjects(project string) ([]string, error)
+}
+
+// NewGoogleClient GoogleClient constructor. Uses default credentials unless overridden by given options
+func NewGoogleClient(opts ...option.ClientOption) (*GoogleClient, error) {
+	p, err := cloudresourcemanager.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(cloudresourcemanager.CloudPlatformReadOnlyScope)}, opts...)...)
 	if e, ok := err.(*googleapi.Error); ok {
 		return nil, NewGoogleApplicationError(e)
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	c, err := compute.NewService(context.Background(), option.WithScopes(cloudresourcemanager.CloudPlatformScope))
+	c, err := compute.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(cloudresourcemanager.CloudPlatformScope)}, opts...)...)
 	if e, ok := err.(*googleapi.Error); ok {
 		return nil, NewGoogleApplicationError(e)
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	return &GoogleClient{
 		projectService: p.Projects,
@@ -89,3 +101,25 @@ func (c *GoogleClient) IsAServiceProjectOf(projectA, projetB string) error {
 
 	return e
 }
+
+// ListServiceProjects returns IDs of the service projects of given host project
+// https://cloud.google.com/compute/docs/reference/rest/v1/projects/getXpnResources
+func (c *GoogleClient) ListServiceProjects(project string) ([]string, error) {
+	var serviceProjects []string
+	err := c.computeService.GetXpnResources(project).Pages(context.Background(), func(page *compute.ProjectsGetXpnResources) error {
+		for _, resource := range page.Resources {
+			if resource.Type == "PROJECT" {
+				serviceProjects = append(serviceProjects, resource.Id)
+			}
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceProjects, nil
+}
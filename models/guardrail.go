@@ -0,0 +1,36 @@
+package models
+
+// Firewall logging metadata options
+// https://cloud.google.com/vpc/docs/firewall-rules-logging
+const (
+	LoggingIncludeAllMetadata = "INCLUDE_ALL_METADATA"
+	LoggingExcludeAllMetadata = "EXCLUDE_ALL_METADATA"
+)
+
+// LoggingRequest describe the wanted logging of a rule
+type LoggingRequest struct {
+	Enable   bool   `json:"enable"`
+	Metadata string `json:"metadata,omitempty"`
+}
+
+// ProjectGuardrails describe constraints applied to all rules of a host project.
+// Quotas limit rules created by applications and service projects, 0 meaning no limit
+type ProjectGuardrails struct {
+	ForceLogging              bool   `json:"force_logging" yaml:"force_logging"`
+	LoggingMetadata           string `json:"logging_metadata,omitempty" yaml:"logging_metadata"`
+	ForbidDisable             bool   `json:"forbid_disable" yaml:"forbid_disable"`
+	MaxRulesPerApplication    int64  `json:"max_rules_per_application,omitempty" yaml:"max_rules_per_application"`
+	MaxRulesPerServiceProject int64  `json:"max_rules_per_service_project,omitempty" yaml:"max_rules_per_service_project"`
+	MaxSourceRanges           int64  `json:"max_source_ranges,omitempty" yaml:"max_source_ranges"`
+}
+
+// GuardrailPolicy describe guardrails by host project. Guardrails of "*" apply to host projects without their own
+type GuardrailPolicy map[string]ProjectGuardrails
+
+// For returns guardrails of the given host project
+func (p GuardrailPolicy) For(project string) ProjectGuardrails {
+	if guardrails, ok := p[project]; ok {
+		return guardrails
+	}
+	return p["*"]
+}
@@ -0,0 +1,14 @@
+package models
+
+// HostProject describe a host project managed by the API, and the networks whose rules it manages. All when empty
+type HostProject struct {
+	Project  string   `json:"project"`
+	Networks []string `json:"networks"`
+}
+
+// HostProjects describe an end-user response listing managed host projects.
+// Restricted is false when the API manages all host projects its credentials can reach
+type HostProjects struct {
+	Restricted   bool          `json:"restricted"`
+	HostProjects []HostProject `json:"data"`
+}
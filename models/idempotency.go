@@ -0,0 +1,98 @@
+package models
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotentResponse describe a response stored for an idempotency key.
+// Pending responses are reservations of requests still in progress
+type IdempotentResponse struct {
+	RequestHash string
+	Pending     bool
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore contains methods to store and replay responses by idempotency key
+type IdempotencyStore interface {
+	// Reserve atomically claims given key for a request with given body hash. It returns nil once claimed,
+	// or the response already stored for the key, pending if the request holding it is still in progress
+	Reserve(key, requestHash string) (*IdempotentResponse, error)
+	SaveResponse(key string, response *IdempotentResponse) error
+	// Release forgets given key, so that a next request claims it again
+	Release(key string) error
+}
+
+type idempotencyEntry struct {
+	response  *IdempotentResponse
+	expiresAt time.Time
+}
+
+// IdempotencyMemoryStore keeps responses in memory for a given duration. Implements IdempotencyStore
+type IdempotencyMemoryStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	responses map[string]idempotencyEntry
+}
+
+// NewIdempotencyMemoryStore IdempotencyMemoryStore constructor
+func NewIdempotencyMemoryStore(ttl time.Duration) *IdempotencyMemoryStore {
+	return &IdempotencyMemoryStore{
+		ttl:       ttl,
+		responses: make(map[string]idempotencyEntry),
+	}
+}
+
+// Reserve claims given key with a pending response, unless an unexpired response is already stored for it
+func (s *IdempotencyMemoryStore) Reserve(key, requestHash string) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := s.responses[key]
+	if ok && now.Before(entry.expiresAt) {
+		return entry.response, nil
+	}
+
+	s.dropExpired(now)
+	s.responses[key] = idempotencyEntry{
+		response:  &IdempotentResponse{RequestHash: requestHash, Pending: true},
+		expiresAt: now.Add(s.ttl),
+	}
+	return nil, nil
+}
+
+// SaveResponse stores the response for given key
+func (s *IdempotencyMemoryStore) SaveResponse(key string, response *IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.dropExpired(now)
+	s.responses[key] = idempotencyEntry{
+		response:  response,
+		expiresAt: now.Add(s.ttl),
+	}
+	return nil
+}
+
+// Release forgets the response stored for given key
+func (s *IdempotencyMemoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.responses, key)
+	return nil
+}
+
+// Drop expired entries to keep memory bounded. Callers hold the lock
+func (s *IdempotencyMemoryStore) dropExpired(now time.Time) {
+	for k, entry := range s.responses {
+		if now.After(entry.expiresAt) {
+			delete(s.responses, k)
+		}
+	}
+}
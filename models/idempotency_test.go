@@ -0,0 +1,82 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyMemoryStore(t *testing.T) {
+	store := NewIdempotencyMemoryStore(time.Hour)
+
+	// Unknown key is claimed
+	response, err := store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if response != nil {
+		t.Errorf("Expected unknown key to be claimed. Got %v", response)
+	}
+
+	// Claimed key is pending until its response is saved
+	response, err = store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if response == nil || !response.Pending || response.RequestHash != "hash" {
+		t.Errorf("Expected a pending response. Got %v", response)
+	}
+
+	expected := &IdempotentResponse{RequestHash: "hash", StatusCode: http.StatusCreated, Body: []byte("{}")}
+	err = store.SaveResponse("foo", expected)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	response, err = store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if response != expected {
+		t.Errorf("Got '%v' want '%v'", response, expected)
+	}
+}
+
+func TestIdempotencyMemoryStoreRelease(t *testing.T) {
+	store := NewIdempotencyMemoryStore(time.Hour)
+
+	_, err := store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	err = store.Release("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	response, err := store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if response != nil {
+		t.Errorf("Expected released key to be claimed again. Got %v", response)
+	}
+}
+
+func TestIdempotencyMemoryStoreExpiration(t *testing.T) {
+	store := NewIdempotencyMemoryStore(-time.Second)
+
+	err := store.SaveResponse("foo", &IdempotentResponse{StatusCode: http.StatusCreated})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	response, err := store.Reserve("foo", "hash")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if response != nil {
+		t.Errorf("Expected expired response to be dropped. Got %v", response)
+	}
+}
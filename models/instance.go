@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// TargetInstance describe an instance targeted by a firewall rule
+type TargetInstance struct {
+	Name     string `json:"name"`
+	Zone     string `json:"zone"`
+	Status   string `json:"status"`
+	SelfLink string `json:"self_link"`
+}
+
+// RuleTargets describe an end-user response listing instances targeted by a rule
+type RuleTargets struct {
+	Project        string           `json:"project"`
+	ServiceProject string           `json:"service_project"`
+	Application    string           `json:"application"`
+	CustomName     string           `json:"custom_name"`
+	TargetTag      string           `json:"target_tag"`
+	Instances      []TargetInstance `json:"data"`
+}
+
+// InstanceManager contains methods to manage compute instances
+type InstanceManager interface {
+	ListInstancesWithTag(project, tag string) ([]*compute.Instance, error)
+	GetInstance(project, name string) (*compute.Instance, error)
+	SetInstanceTags(project string, instance *compute.Instance, tags []string) error
+}
+
+// InstanceClient provides primitives to collect instances from Google Cloud Platform. Implements InstanceManager
+type InstanceClient struct {
+	computeService *compute.Service
+}
+
+// NewInstanceClient InstanceClient constructor. Uses default credentials unless overridden by given options
+func NewInstanceClient(opts ...option.ClientOption) (*InstanceClient, error) {
+	computeService, err := compute.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(compute.CloudPlatformScope)}, opts...)...)
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstanceClient{computeService: computeService}, nil
+}
+
+// ListInstancesWithTag returns instances of every zone of given project carrying given network tag
+func (c *InstanceClient) ListInstancesWithTag(project, tag string) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+
+	req := c.computeService.Instances.AggregatedList(project)
+	err := req.Pages(context.Background(), func(page *compute.InstanceAggregatedList) error {
+		for _, scoped := range page.Items {
+			for _, instance := range scoped.Instances {
+				if instance.Tags == nil {
+					continue
+				}
+				for _, t := range instance.Tags.Items {
+					if t == tag {
+						instances = append(instances, instance)
+						break
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+
+	return instances, nil
+}
+
+// GetInstance returns the instance matching given name in any zone of given project
+func (c *InstanceClient) GetInstance(project, name string) (*compute.Instance, error) {
+	var found *compute.Instance
+
+	req := c.computeService.Instances.AggregatedList(project).Filter(fmt.Sprintf("name = %q", name))
+	err := req.Pages(context.Background(), func(page *compute.InstanceAggregatedList) error {
+		for _, scoped := range page.Items {
+			for _, instance := range scoped.Instances {
+				if instance.Name == name {
+					found = instance
+				}
+			}
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+
+	if found == nil {
+		e := NewNotFoundError()
+		e.Message = fmt.Sprintf("Instance [%s] not found in project [%s]", name, project)
+		return nil, e
+	}
+
+	return found, nil
+}
+
+// SetInstanceTags replaces network tags of given instance
+// https://cloud.google.com/compute/docs/reference/rest/v1/instances/setTags
+func (c *InstanceClient) SetInstanceTags(project string, instance *compute.Instance, tags []string) error {
+	// Fingerprint ensures tags have not been modified since instance has been read
+	t := &compute.Tags{Items: tags}
+	if instance.Tags != nil {
+		t.Fingerprint = instance.Tags.Fingerprint
+	}
+
+	_, err := c.computeService.Instances.SetTags(project, path.Base(instance.Zone), instance.Name, t).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return NewGoogleApplicationError(e)
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Role granting the use of a Shared VPC network or subnetwork
+const networkUserRole = "roles/compute.networkUser"
+
+// Subnetwork describe a subnetwork shared with a service project
+type Subnetwork struct {
+	Name        string `json:"name"`
+	Region      string `json:"region"`
+	IPCidrRange string `json:"ip_cidr_range"`
+	SelfLink    string `json:"self_link"`
+}
+
+// Network describe a VPC network shared with a service project
+type Network struct {
+	Name        string       `json:"name"`
+	SelfLink    string       `json:"self_link"`
+	Subnetworks []Subnetwork `json:"subnetworks"`
+}
+
+// SharedNetworks describe an end-user response listing networks shared with a service project
+type SharedNetworks struct {
+	Project        string    `json:"project"`
+	ServiceProject string    `json:"service_project"`
+	Networks       []Network `json:"data"`
+}
+
+// NetworkManager contains methods to discover Shared VPC networks
+type NetworkManager interface {
+	ListSharedNetworks(project, serviceProject string) ([]Network, error)
+}
+
+// NetworkClient provides primitives to collect Shared VPC networks from Google Cloud Platform. Implements NetworkManager
+type NetworkClient struct {
+	computeService *compute.Service
+	projectService *cloudresourcemanager.ProjectsService
+}
+
+// NewNetworkClient NetworkClient constructor. Uses default credentials unless overridden by given options
+func NewNetworkClient(opts ...option.ClientOption) (*NetworkClient, error) {
+	p, err := cloudresourcemanager.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(cloudresourcemanager.CloudPlatformReadOnlyScope)}, opts...)...)
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := compute.NewService(context.Background(), append([]option.ClientOption{option.WithScopes(compute.CloudPlatformScope)}, opts...)...)
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetworkClient{
+		computeService: c,
+		projectService: p.Projects,
+	}, nil
+}
+
+// ListSharedNetworks returns networks of given host project with their subnetworks shared with given service project.
+// Subnetworks are shared when the service project is granted roles/compute.networkUser on the host project or on the subnetwork
+// https://cloud.google.com/vpc/docs/provisioning-shared-vpc#networkuseratproject
+func (c *NetworkClient) ListSharedNetworks(project, serviceProject string) ([]Network, error) {
+	sp, err := c.projectService.Get(serviceProject).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	serviceProjectNumber := fmt.Sprintf("%d", sp.ProjectNumber)
+
+	// Host project level grant shares all subnetworks
+	policy, err := c.projectService.GetIamPolicy(project, &cloudresourcemanager.GetIamPolicyRequest{}).Context(context.Background()).Do()
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sharedProject := false
+	for _, b := range policy.Bindings {
+		if b.Role == networkUserRole && hasServiceProjectMember(b.Members, serviceProject, serviceProjectNumber) {
+			sharedProject = true
+		}
+	}
+
+	// Collect subnetworks by network
+	subnetworks := make(map[string][]Subnetwork)
+	err = c.computeService.Subnetworks.AggregatedList(project).Pages(context.Background(), func(page *compute.SubnetworkAggregatedList) error {
+		for _, scoped := range page.Items {
+			for _, s := range scoped.Subnetworks {
+				if !sharedProject {
+					shared, err := c.isSubnetworkSharedWith(project, s, serviceProject, serviceProjectNumber)
+					if err != nil {
+						return err
+					}
+					if !shared {
+						continue
+					}
+				}
+
+				subnetworks[s.Network] = append(subnetworks[s.Network], Subnetwork{
+					Name:        s.Name,
+					Region:      path.Base(s.Region),
+					IPCidrRange: s.IpCidrRange,
+					SelfLink:    s.SelfLink,
+				})
+			}
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	networks := make([]Network, 0)
+	err = c.computeService.Networks.List(project).Pages(context.Background(), func(page *compute.NetworkList) error {
+		for _, n := range page.Items {
+			if _, ok := subnetworks[n.SelfLink]; !ok {
+				continue
+			}
+			networks = append(networks, Network{
+				Name:        n.Name,
+				SelfLink:    n.SelfLink,
+				Subnetworks: subnetworks[n.SelfLink],
+			})
+		}
+		return nil
+	})
+	if e, ok := err.(*googleapi.Error); ok {
+		return nil, NewGoogleApplicationError(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return networks, nil
+}
+
+func (c *NetworkClient) isSubnetworkSharedWith(project string, subnetwork *compute.Subnetwork, serviceProject, serviceProjectNumber string) (bool, error) {
+	policy, err := c.computeService.Subnetworks.GetIamPolicy(project, path.Base(subnetwork.Region), subnetwork.Name).Context(context.Background()).Do()
+	if err != nil {
+		return false, err
+	}
+
+	for _, b := range policy.Bindings {
+		if b.Role == networkUserRole && hasServiceProjectMember(b.Members, serviceProject, serviceProjectNumber) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasServiceProjectMember returns if one of given IAM members is a service account of given service project,
+// either a user managed one or a Google managed one identified by the project number
+func hasServiceProjectMember(members []string, serviceProject, serviceProjectNumber string) bool {
+	for _, member := range members {
+		if !strings.HasPrefix(member, "serviceAccount:") {
+			continue
+		}
+
+		if strings.HasSuffix(member, fmt.Sprintf("@%s.iam.gserviceaccount.com", serviceProject)) ||
+			strings.HasPrefix(member, fmt.Sprintf("serviceAccount:%s@", serviceProjectNumber)) ||
+			strings.HasPrefix(member, fmt.Sprintf("serviceAccount:%s-", serviceProjectNumber)) ||
+			strings.HasPrefix(member, fmt.Sprintf("serviceAccount:service-%s@", serviceProjectNumber)) {
+			return true
+		}
+	}
+	return false
+}
+
+type networkCacheEntry struct {
+	networks  []Network
+	expiresAt time.Time
+}
+
+// NetworkCache keeps the networks shared with each service project for a given duration, not to read subnetworks and their
+// IAM policies again for each rule written. Failures are not kept. Implements NetworkManager
+type NetworkCache struct {
+	manager NetworkManager
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	networks map[string]networkCacheEntry
+}
+
+// NewNetworkCache NetworkCache constructor, caching networks listed by given manager
+func NewNetworkCache(manager NetworkManager, ttl time.Duration) *NetworkCache {
+	return &NetworkCache{
+		manager:  manager,
+		ttl:      ttl,
+		networks: make(map[string]networkCacheEntry),
+	}
+}
+
+// ListSharedNetworks returns the networks of given host project shared with given service project, listed again once expired
+func (c *NetworkCache) ListSharedNetworks(project, serviceProject string) ([]Network, error) {
+	key := project + "/" + serviceProject
+
+	c.mu.Lock()
+	entry, ok := c.networks[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return append([]Network(nil), entry.networks...), nil
+	}
+
+	networks, err := c.manager.ListSharedNetworks(project, serviceProject)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.networks {
+		if !now.Before(e.expiresAt) {
+			delete(c.networks, k)
+		}
+	}
+	c.networks[key] = networkCacheEntry{networks: networks, expiresAt: now.Add(c.ttl)}
+	return append([]Network(nil), networks...), nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHasServiceProjectMember(t *testing.T) {
+	suite := []struct {
+		Title    string
+		Members  []string
+		Expected bool
+	}{
+		{Title: "Google APIs service agent", Members: []string{"serviceAccount:123@cloudservices.gserviceaccount.com"}, Expected: true},
+		{Title: "Compute default service account", Members: []string{"serviceAccount:123-compute@developer.gserviceaccount.com"}, Expected: true},
+		{Title: "GKE service agent", Members: []string{"serviceAccount:service-123@container-engine-robot.iam.gserviceaccount.com"}, Expected: true},
+		{Title: "User managed service account", Members: []string{"serviceAccount:app@my-sp.iam.gserviceaccount.com"}, Expected: true},
+		{Title: "Other project", Members: []string{"serviceAccount:1234@cloudservices.gserviceaccount.com", "serviceAccount:app@other-sp.iam.gserviceaccount.com"}, Expected: false},
+		{Title: "User", Members: []string{"user:123@example.com"}, Expected: false},
+		{Title: "No member", Members: nil, Expected: false},
+	}
+
+	for _, suiteCase := range suite {
+		t.Run(suiteCase.Title, func(t *testing.T) {
+			got := hasServiceProjectMember(suiteCase.Members, "my-sp", "123")
+			if got != suiteCase.Expected {
+				t.Errorf("Got '%v' want '%v'", got, suiteCase.Expected)
+			}
+		})
+	}
+}
+
+// Network manager counting its calls, failing while err is set
+type countingNetworkManager struct {
+	calls int
+	err   error
+}
+
+func (m *countingNetworkManager) ListSharedNetworks(project, serviceProject string) ([]Network, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []Network{Network{Name: serviceProject}}, nil
+}
+
+func TestNetworkCache(t *testing.T) {
+	manager := &countingNetworkManager{err: errors.New("unavailable")}
+	cache := NewNetworkCache(manager, time.Hour)
+
+	// Failures are not kept
+	_, err := cache.ListSharedNetworks("host", "sp")
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	manager.err = nil
+
+	for i := 0; i < 3; i++ {
+		networks, err := cache.ListSharedNetworks("host", "sp")
+		if err != nil || len(networks) != 1 || networks[0].Name != "sp" {
+			t.Errorf("Unexpected networks. Got %v, %v", networks, err)
+		}
+	}
+	cache.ListSharedNetworks("host", "other-sp")
+	if manager.calls != 3 {
+		t.Errorf("Expected networks listed once by service project. Got %d calls", manager.calls)
+	}
+
+	// Expired networks are listed again
+	cache = NewNetworkCache(manager, 0)
+	cache.ListSharedNetworks("host", "sp")
+	cache.ListSharedNetworks("host", "sp")
+	if manager.calls != 5 {
+		t.Errorf("Expected expired networks listed again. Got %d calls", manager.calls)
+	}
+}
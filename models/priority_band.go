@@ -0,0 +1,341 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PriorityBandWidth is the count of priorities of a band. Applications give priorities relative to their band,
+// from 0 to PriorityBandWidth - 1
+const PriorityBandWidth = 100
+
+// DefaultRelativePriority is the priority within its band of a rule created without priority, leaving room before and after it
+const DefaultRelativePriority = PriorityBandWidth / 2
+
+// PriorityBand describe a range of Google priorities of a host project, starting at Base.
+// The default band applies to applications without band
+type PriorityBand struct {
+	Name    string `json:"name"`
+	Base    int64  `json:"base"`
+	Default bool   `json:"default"`
+}
+
+// PriorityBands describe an end-user response listing priority bands
+type PriorityBands struct {
+	Project string         `json:"project"`
+	Bands   []PriorityBand `json:"data"`
+}
+
+// BandAssignment describe the priority band of an application
+type BandAssignment struct {
+	Project        string `json:"project"`
+	ServiceProject string `json:"service_project"`
+	Application    string `json:"application"`
+	Band           string `json:"band"`
+}
+
+// PriorityBandStore contains methods to store priority bands and their applications
+type PriorityBandStore interface {
+	ListPriorityBands(project string) ([]PriorityBand, error)
+	GetPriorityBand(project, name string) (*PriorityBand, error)
+	SavePriorityBand(project string, band PriorityBand) error
+	DeletePriorityBand(project, name string) error
+	ListAssignments(project, band string) ([]BandAssignment, error)
+	GetAssignment(project, serviceProject, application string) (*BandAssignment, error)
+	SaveAssignment(assignment BandAssignment) error
+	DeleteAssignment(project, serviceProject, application string) error
+}
+
+// applicationKey identifies an application of a host project
+type applicationKey struct {
+	serviceProject string
+	application    string
+}
+
+// PriorityBandMemoryStore keeps priority bands in memory. Implements PriorityBandStore
+type PriorityBandMemoryStore struct {
+	mu          sync.RWMutex
+	bands       map[string]map[string]PriorityBand
+	assignments map[string]map[applicationKey]BandAssignment
+}
+
+// NewPriorityBandMemoryStore PriorityBandMemoryStore constructor
+func NewPriorityBandMemoryStore() *PriorityBandMemoryStore {
+	return &PriorityBandMemoryStore{
+		bands:       make(map[string]map[string]PriorityBand),
+		assignments: make(map[string]map[applicationKey]BandAssignment),
+	}
+}
+
+// ListPriorityBands returns priority bands of given project sorted by base
+func (s *PriorityBandMemoryStore) ListPriorityBands(project string) ([]PriorityBand, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bands := make([]PriorityBand, 0, len(s.bands[project]))
+	for _, band := range s.bands[project] {
+		bands = append(bands, band)
+	}
+
+	sort.Slice(bands, func(i, j int) bool { return bands[i].Base < bands[j].Base })
+	return bands, nil
+}
+
+// GetPriorityBand returns the priority band matching given project and name
+func (s *PriorityBandMemoryStore) GetPriorityBand(project, name string) (*PriorityBand, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	band, ok := s.bands[project][name]
+	if !ok {
+		e := NewNotFoundError()
+		e.Message = fmt.Sprintf("Priority band [%s] not found in project [%s]", name, project)
+		return nil, e
+	}
+
+	return &band, nil
+}
+
+// SavePriorityBand creates or replaces given priority band
+func (s *PriorityBandMemoryStore) SavePriorityBand(project string, band PriorityBand) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bands[project]; !ok {
+		s.bands[project] = make(map[string]PriorityBand)
+	}
+
+	s.bands[project][band.Name] = band
+	return nil
+}
+
+// DeletePriorityBand deletes the priority band matching given project and name
+func (s *PriorityBandMemoryStore) DeletePriorityBand(project, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bands[project][name]; !ok {
+		e := NewNotFoundError()
+		e.Message = fmt.Sprintf("Priority band [%s] not found in project [%s]", name, project)
+		return e
+	}
+
+	delete(s.bands[project], name)
+	return nil
+}
+
+// ListAssignments returns applications of given project assigned to given band
+func (s *PriorityBandMemoryStore) ListAssignments(project, band string) ([]BandAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	assignments := make([]BandAssignment, 0)
+	for _, assignment := range s.assignments[project] {
+		if assignment.Band == band {
+			assignments = append(assignments, assignment)
+		}
+	}
+
+	return assignments, nil
+}
+
+// GetAssignment returns the priority band of the matching application, nil if it has none
+func (s *PriorityBandMemoryStore) GetAssignment(project, serviceProject, application string) (*BandAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	assignment, ok := s.assignments[project][applicationKey{serviceProject, application}]
+	if !ok {
+		return nil, nil
+	}
+
+	return &assignment, nil
+}
+
+// SaveAssignment creates or replaces the priority band of given application
+func (s *PriorityBandMemoryStore) SaveAssignment(assignment BandAssignment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.assignments[assignment.Project]; !ok {
+		s.assignments[assignment.Project] = make(map[applicationKey]BandAssignment)
+	}
+
+	s.assignments[assignment.Project][applicationKey{assignment.ServiceProject, assignment.Application}] = assignment
+	return nil
+}
+
+// DeleteAssignment deletes the priority band of the matching application, if any
+func (s *PriorityBandMemoryStore) DeleteAssignment(project, serviceProject, application string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.assignments[project], applicationKey{serviceProject, application})
+	return nil
+}
+
+// priorityBandState describe the content of a priority band store, by project
+type priorityBandState struct {
+	Bands       map[string][]PriorityBand `json:"bands"`
+	Assignments []BandAssignment          `json:"assignments"`
+}
+
+func (s *PriorityBandMemoryStore) state() priorityBandState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := priorityBandState{Bands: make(map[string][]PriorityBand), Assignments: make([]BandAssignment, 0)}
+	for project, bands := range s.bands {
+		for _, band := range bands {
+			state.Bands[project] = append(state.Bands[project], band)
+		}
+	}
+	for _, assignments := range s.assignments {
+		for _, assignment := range assignments {
+			state.Assignments = append(state.Assignments, assignment)
+		}
+	}
+	return state
+}
+
+// PriorityBandFileStore keeps priority bands in memory, and saves them to a file on each change
+// so that they survive restarts. Implements PriorityBandStore
+type PriorityBandFileStore struct {
+	*PriorityBandMemoryStore
+	mu   sync.Mutex
+	path string
+}
+
+// NewPriorityBandFileStore PriorityBandFileStore constructor, loading priority bands saved to given file if any
+func NewPriorityBandFileStore(path string) (*PriorityBandFileStore, error) {
+	var state priorityBandState
+	err := readStateFile(path, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PriorityBandFileStore{PriorityBandMemoryStore: NewPriorityBandMemoryStore(), path: path}
+	for project, bands := range state.Bands {
+		for _, band := range bands {
+			s.PriorityBandMemoryStore.SavePriorityBand(project, band)
+		}
+	}
+	for _, assignment := range state.Assignments {
+		s.PriorityBandMemoryStore.SaveAssignment(assignment)
+	}
+	return s, nil
+}
+
+// SavePriorityBand creates or replaces given priority band
+func (s *PriorityBandFileStore) SavePriorityBand(project string, band PriorityBand) error {
+	return s.update(func() error { return s.PriorityBandMemoryStore.SavePriorityBand(project, band) })
+}
+
+// DeletePriorityBand deletes the priority band matching given project and name
+func (s *PriorityBandFileStore) DeletePriorityBand(project, name string) error {
+	return s.update(func() error { return s.PriorityBandMemoryStore.DeletePriorityBand(project, name) })
+}
+
+// SaveAssignment creates or replaces the priority band of given application
+func (s *PriorityBandFileStore) SaveAssignment(assignment BandAssignment) error {
+	return s.update(func() error { return s.PriorityBandMemoryStore.SaveAssignment(assignment) })
+}
+
+// DeleteAssignment deletes the priority band of the matching application, if any
+func (s *PriorityBandFileStore) DeleteAssignment(project, serviceProject, application string) error {
+	return s.update(func() error { return s.PriorityBandMemoryStore.DeleteAssignment(project, serviceProject, application) })
+}
+
+// update applies given change, then saves the whole store. Changes are saved one at a time, in order
+func (s *PriorityBandFileStore) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := change()
+	if err != nil {
+		return err
+	}
+	return writeStateFile(s.path, s.PriorityBandMemoryStore.state())
+}
+
+// PriorityBandConfigStore serves priority bands of the host projects set by configuration, which cannot be changed,
+// and keeps bands of other host projects in the embedded store. Implements PriorityBandStore
+type PriorityBandConfigStore struct {
+	PriorityBandStore
+	configured PriorityBandStore
+	projects   map[string]bool
+}
+
+// NewPriorityBandConfigStore PriorityBandConfigStore constructor. Given configured store holds bands of given projects
+func NewPriorityBandConfigStore(configured PriorityBandStore, projects []string, store PriorityBandStore) *PriorityBandConfigStore {
+	s := &PriorityBandConfigStore{PriorityBandStore: store, configured: configured, projects: make(map[string]bool)}
+	for _, project := range projects {
+		s.projects[project] = true
+	}
+	return s
+}
+
+// ListPriorityBands returns priority bands of given project sorted by base
+func (s *PriorityBandConfigStore) ListPriorityBands(project string) ([]PriorityBand, error) {
+	return s.store(project).ListPriorityBands(project)
+}
+
+// GetPriorityBand returns the priority band matching given project and name
+func (s *PriorityBandConfigStore) GetPriorityBand(project, name string) (*PriorityBand, error) {
+	return s.store(project).GetPriorityBand(project, name)
+}
+
+// SavePriorityBand creates or replaces given priority band, unless bands of the project are set by configuration
+func (s *PriorityBandConfigStore) SavePriorityBand(project string, band PriorityBand) error {
+	if s.projects[project] {
+		return configuredPriorityBandsError(project)
+	}
+	return s.PriorityBandStore.SavePriorityBand(project, band)
+}
+
+// DeletePriorityBand deletes the matching priority band, unless bands of the project are set by configuration
+func (s *PriorityBandConfigStore) DeletePriorityBand(project, name string) error {
+	if s.projects[project] {
+		return configuredPriorityBandsError(project)
+	}
+	return s.PriorityBandStore.DeletePriorityBand(project, name)
+}
+
+// ListAssignments returns applications of given project assigned to given band
+func (s *PriorityBandConfigStore) ListAssignments(project, band string) ([]BandAssignment, error) {
+	return s.store(project).ListAssignments(project, band)
+}
+
+// GetAssignment returns the priority band of the matching application, nil if it has none
+func (s *PriorityBandConfigStore) GetAssignment(project, serviceProject, application string) (*BandAssignment, error) {
+	return s.store(project).GetAssignment(project, serviceProject, application)
+}
+
+// SaveAssignment creates or replaces the priority band of given application, unless bands of its project are set by configuration
+func (s *PriorityBandConfigStore) SaveAssignment(assignment BandAssignment) error {
+	if s.projects[assignment.Project] {
+		return configuredPriorityBandsError(assignment.Project)
+	}
+	return s.PriorityBandStore.SaveAssignment(assignment)
+}
+
+// DeleteAssignment deletes the priority band of the matching application, unless bands of its project are set by configuration
+func (s *PriorityBandConfigStore) DeleteAssignment(project, serviceProject, application string) error {
+	if s.projects[project] {
+		return configuredPriorityBandsError(project)
+	}
+	return s.PriorityBandStore.DeleteAssignment(project, serviceProject, application)
+}
+
+// store returns the store keeping bands of given project
+func (s *PriorityBandConfigStore) store(project string) PriorityBandStore {
+	if s.projects[project] {
+		return s.configured
+	}
+	return s.PriorityBandStore
+}
+
+func configuredPriorityBandsError(project string) *ApplicationError {
+	return NewConflictError(fmt.Sprintf("Priority bands of project [%s] are set by configuration", project))
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPriorityBandMemoryStore(t *testing.T) {
+	store := NewPriorityBandMemoryStore()
+
+	_, err := store.GetPriorityBand("host", "apps")
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+
+	store.SavePriorityBand("host", PriorityBand{Name: "apps", Base: 2000})
+	store.SavePriorityBand("host", PriorityBand{Name: "security", Base: 1000})
+	store.SavePriorityBand("other-host", PriorityBand{Name: "apps", Base: 3000})
+
+	bands, _ := store.ListPriorityBands("host")
+	if len(bands) != 2 || bands[0].Name != "security" || bands[1].Name != "apps" {
+		t.Errorf("Bands should be scoped by project and sorted by base. Got %v", bands)
+	}
+
+	err = store.DeletePriorityBand("host", "security")
+	if err != nil {
+		t.Errorf("Unexpected error. Got %v", err)
+	}
+
+	err = store.DeletePriorityBand("host", "security")
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+}
+
+func TestPriorityBandMemoryStoreAssignments(t *testing.T) {
+	store := NewPriorityBandMemoryStore()
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "web", Band: "apps"})
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "api", Band: "apps"})
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "api", Band: "critical"})
+
+	assignments, _ := store.ListAssignments("host", "apps")
+	if len(assignments) != 1 || assignments[0].Application != "web" {
+		t.Errorf("Unexpected assignments. Got %v", assignments)
+	}
+
+	assignment, _ := store.GetAssignment("host", "sp", "api")
+	if assignment == nil || assignment.Band != "critical" {
+		t.Errorf("Unexpected assignment. Got %v", assignment)
+	}
+
+	store.DeleteAssignment("host", "sp", "api")
+	assignment, _ = store.GetAssignment("host", "sp", "api")
+	if assignment != nil {
+		t.Errorf("Assignment should be deleted. Got %v", assignment)
+	}
+}
+
+func TestPriorityBandMemoryStoreAssignmentsWithHyphens(t *testing.T) {
+	store := NewPriorityBandMemoryStore()
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "a-b", Application: "c", Band: "apps"})
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "a", Application: "b-c", Band: "critical"})
+
+	assignment, _ := store.GetAssignment("host", "a-b", "c")
+	if assignment == nil || assignment.Band != "apps" {
+		t.Errorf("Assignments of applications with the same prefix should not collide. Got %v", assignment)
+	}
+}
+
+func TestPriorityBandFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "priority_bands.json")
+
+	store, err := NewPriorityBandFileStore(path)
+	if err != nil {
+		t.Fatalf("Missing file should give an empty store. Got %v", err)
+	}
+	store.SavePriorityBand("host", PriorityBand{Name: "apps", Base: 2000, Default: true})
+	store.SavePriorityBand("host", PriorityBand{Name: "critical", Base: 1000})
+	store.DeletePriorityBand("host", "critical")
+	store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "web", Band: "apps"})
+
+	// A new store finds the saved state back
+	store, err = NewPriorityBandFileStore(path)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	bands, _ := store.ListPriorityBands("host")
+	if len(bands) != 1 || bands[0].Name != "apps" || bands[0].Base != 2000 || !bands[0].Default {
+		t.Errorf("Unexpected bands. Got %v", bands)
+	}
+
+	assignment, _ := store.GetAssignment("host", "sp", "web")
+	if assignment == nil || assignment.Band != "apps" {
+		t.Errorf("Unexpected assignment. Got %v", assignment)
+	}
+}
+
+func TestPriorityBandConfigStore(t *testing.T) {
+	configured := NewPriorityBandMemoryStore()
+	configured.SavePriorityBand("host", PriorityBand{Name: "apps", Base: 2000})
+	configured.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "web", Band: "apps"})
+	store := NewPriorityBandConfigStore(configured, []string{"host"}, NewPriorityBandMemoryStore())
+
+	// Bands of configured projects are read only
+	bands, _ := store.ListPriorityBands("host")
+	assignment, _ := store.GetAssignment("host", "sp", "web")
+	if len(bands) != 1 || assignment == nil || assignment.Band != "apps" {
+		t.Errorf("Unexpected configured bands. Got %v, %v", bands, assignment)
+	}
+
+	for _, err := range []error{
+		store.SavePriorityBand("host", PriorityBand{Name: "critical", Base: 1000}),
+		store.DeletePriorityBand("host", "apps"),
+		store.SaveAssignment(BandAssignment{Project: "host", ServiceProject: "sp", Application: "api", Band: "apps"}),
+		store.DeleteAssignment("host", "sp", "web"),
+	} {
+		if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusConflict {
+			t.Errorf("Expected a conflict. Got %v", err)
+		}
+	}
+
+	// Other projects are kept in the given store
+	err := store.SavePriorityBand("other-host", PriorityBand{Name: "critical", Base: 1000})
+	bands, _ = store.ListPriorityBands("other-host")
+	if err != nil || len(bands) != 1 {
+		t.Errorf("Unexpected bands. Got %v, %v", bands, err)
+	}
+}
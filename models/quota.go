@@ -0,0 +1,19 @@
+package models
+
+// QuotaUsage describe the usage of a quota. A limit of 0 means no limit
+type QuotaUsage struct {
+	Usage int64 `json:"usage"`
+	Limit int64 `json:"limit"`
+}
+
+// QuotaReport describe an end-user response of the quotas of an application, its service project and the host project
+type QuotaReport struct {
+	Project                    string     `json:"project"`
+	ServiceProject             string     `json:"service_project"`
+	Application                string     `json:"application"`
+	ApplicationRules           QuotaUsage `json:"application_rules"`
+	ServiceProjectRules        QuotaUsage `json:"service_project_rules"`
+	ServiceProjectSourceRanges QuotaUsage `json:"service_project_source_ranges"`
+	ProjectRules               QuotaUsage `json:"project_rules"`
+	ProjectRemainingRules      int64      `json:"project_remaining_rules"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Readiness statuses of the API and of its dependencies
+const (
+	ReadinessUp   = "UP"
+	ReadinessDown = "DOWN"
+)
+
+// DependencyStatus describe the readiness of a dependency of the API
+type DependencyStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Readiness describe the readiness of the API, down as soon as one of its dependencies is
+type Readiness struct {
+	Status       string             `json:"status"`
+	CheckedAt    time.Time          `json:"checked_at"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"google.golang.org/api/compute/v1"
+)
+
+// Rule directions
+const (
+	RuleDirectionIngress = "INGRESS"
+	RuleDirectionEgress  = "EGRESS"
+)
+
+// Rule actions
+const (
+	RuleActionAllow = "allow"
+	RuleActionDeny  = "deny"
+)
+
+// Rule describe a firewall rule independently of the backend enforcing it
+type Rule struct {
+	Name        string
+	Description string
+	// Self-link of the network the rule applies to. Empty when the backend applies rules to the networks it is associated with
+	Network   string
+	Direction string
+	Action    string
+	Priority  int64
+	Protocols []RuleProtocol
+
+	SourceRanges          []string
+	DestinationRanges     []string
+	SourceTags            []string
+	TargetTags            []string
+	SourceServiceAccounts []string
+	TargetServiceAccounts []string
+
+	Disabled        bool
+	Logging         bool
+	LoggingMetadata string
+
+	// Output only fields, set by backends
+	ID                uint64
+	CreationTimestamp string
+	SelfLink          string
+}
+
+// RuleProtocol describe a protocol matched by a rule, and its ports. All ports when empty
+type RuleProtocol struct {
+	Protocol string
+	Ports    []string
+}
+
+// RuleQuota describe the usage of the rules quota of a host project. Limit is 0 when the backend has no known limit
+type RuleQuota struct {
+	Usage float64
+	Limit float64
+}
+
+// RuleBackend contains methods to manage rules of a host project on a firewall backend
+type RuleBackend interface {
+	ListRules(project string) ([]*Rule, error)
+	GetRule(project, name string) (*Rule, error)
+	CreateRule(project string, rule *Rule) (*Rule, error)
+	UpdateRule(project string, rule *Rule) (*Rule, error)
+	DeleteRule(project, name string) error
+	GetRuleQuota(project string) (*RuleQuota, error)
+}
+
+// RuleManager manages rules of a RuleBackend in GCP VPC firewall rules format, which is the format of the API. Implements FirewallRuleManager
+type RuleManager struct {
+	backend RuleBackend
+}
+
+// NewRuleManager RuleManager constructor
+func NewRuleManager(backend RuleBackend) *RuleManager {
+	return &RuleManager{backend: backend}
+}
+
+// ListFirewallRule returns given project's firewall rule
+func (m *RuleManager) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	rules, err := m.backend.ListRules(project)
+	if err != nil {
+		return nil, err
+	}
+
+	var firewallRuleList []*compute.Firewall
+	for _, rule := range rules {
+		firewallRuleList = append(firewallRuleList, rule.Firewall())
+	}
+	return firewallRuleList, nil
+}
+
+// GetFirewallRule returns firewall rule matching given project and name
+func (m *RuleManager) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	rule, err := m.backend.GetRule(project, name)
+	if err != nil {
+		return nil, err
+	}
+	return rule.Firewall(), nil
+}
+
+// CreateFirewallRule create given firewall rule on given project
+func (m *RuleManager) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	created, err := m.backend.CreateRule(project, NewRuleFromFirewall(rule))
+	if err != nil {
+		return nil, err
+	}
+	return created.Firewall(), nil
+}
+
+// UpdateFirewallRule replace the firewall rule matching given rule's name on given project
+func (m *RuleManager) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	updated, err := m.backend.UpdateRule(project, NewRuleFromFirewall(rule))
+	if err != nil {
+		return nil, err
+	}
+	return updated.Firewall(), nil
+}
+
+// DeleteFirewallRule delete firewall rule matching given project and name
+func (m *RuleManager) DeleteFirewallRule(project, name string) error {
+	return m.backend.DeleteRule(project, name)
+}
+
+// GetFirewallQuota returns the firewall rules quota of given project
+func (m *RuleManager) GetFirewallQuota(project string) (*compute.Quota, error) {
+	quota, err := m.backend.GetRuleQuota(project)
+	if err != nil {
+		return nil, err
+	}
+	return &compute.Quota{Metric: "FIREWALLS", Usage: quota.Usage, Limit: quota.Limit}, nil
+}
+
+// NewRuleFromFirewall translates a GCP VPC firewall rule to a Rule
+func NewRuleFromFirewall(firewall *compute.Firewall) *Rule {
+	rule := &Rule{
+		Name:                  firewall.Name,
+		Description:           firewall.Description,
+		Network:               firewall.Network,
+		Direction:             firewall.Direction,
+		Action:                RuleActionAllow,
+		Priority:              firewall.Priority,
+		SourceRanges:          firewall.SourceRanges,
+		DestinationRanges:     firewall.DestinationRanges,
+		SourceTags:            firewall.SourceTags,
+		TargetTags:            firewall.TargetTags,
+		SourceServiceAccounts: firewall.SourceServiceAccounts,
+		TargetServiceAccounts: firewall.TargetServiceAccounts,
+		Disabled:              firewall.Disabled,
+		ID:                    firewall.Id,
+		CreationTimestamp:     firewall.CreationTimestamp,
+		SelfLink:              firewall.SelfLink,
+	}
+
+	for _, allowed := range firewall.Allowed {
+		rule.Protocols = append(rule.Protocols, RuleProtocol{Protocol: allowed.IPProtocol, Ports: allowed.Ports})
+	}
+	if len(firewall.Denied) > 0 {
+		rule.Action = RuleActionDeny
+		for _, denied := range firewall.Denied {
+			rule.Protocols = append(rule.Protocols, RuleProtocol{Protocol: denied.IPProtocol, Ports: denied.Ports})
+		}
+	}
+
+	if firewall.LogConfig != nil {
+		rule.Logging = firewall.LogConfig.Enable
+		rule.LoggingMetadata = firewall.LogConfig.Metadata
+	}
+
+	return rule
+}
+
+// Firewall translates the rule to a GCP VPC firewall rule
+func (r *Rule) Firewall() *compute.Firewall {
+	firewall := &compute.Firewall{
+		Name:                  r.Name,
+		Description:           r.Description,
+		Network:               r.Network,
+		Direction:             r.Direction,
+		Priority:              r.Priority,
+		SourceRanges:          r.SourceRanges,
+		DestinationRanges:     r.DestinationRanges,
+		SourceTags:            r.SourceTags,
+		TargetTags:            r.TargetTags,
+		SourceServiceAccounts: r.SourceServiceAccounts,
+		TargetServiceAccounts: r.TargetServiceAccounts,
+		Disabled:              r.Disabled,
+		Id:                    r.ID,
+		CreationTimestamp:     r.CreationTimestamp,
+		SelfLink:              r.SelfLink,
+	}
+
+	for _, protocol := range r.Protocols {
+		if r.Action == RuleActionDeny {
+			firewall.Denied = append(firewall.Denied, &compute.FirewallDenied{IPProtocol: protocol.Protocol, Ports: protocol.Ports})
+		} else {
+			firewall.Allowed = append(firewall.Allowed, &compute.FirewallAllowed{IPProtocol: protocol.Protocol, Ports: protocol.Ports})
+		}
+	}
+
+	if r.Logging || r.LoggingMetadata != "" {
+		firewall.LogConfig = &compute.FirewallLogConfig{Enable: r.Logging, Metadata: r.LoggingMetadata}
+	}
+
+	return firewall
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+)
+
+func TestRuleFirewallTranslation(t *testing.T) {
+	tests := []struct {
+		Title    string
+		Firewall *compute.Firewall
+		Action   string
+	}{
+		{
+			Title: "Allow",
+			Firewall: &compute.Firewall{
+				Name:              "sp-web-https",
+				Description:       "HTTPS from offices",
+				Network:           "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network",
+				Direction:         RuleDirectionIngress,
+				Priority:          900,
+				Allowed:           []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"443", "8443-8444"}}, &compute.FirewallAllowed{IPProtocol: "icmp"}},
+				SourceRanges:      []string{"10.0.0.0/8"},
+				SourceTags:        []string{"sp-proxy"},
+				TargetTags:        []string{"sp-web-https"},
+				LogConfig:         &compute.FirewallLogConfig{Enable: true, Metadata: "EXCLUDE_ALL_METADATA"},
+				Id:                42,
+				CreationTimestamp: "2020-06-01T12:00:00Z",
+				SelfLink:          "https://www.googleapis.com/compute/v1/projects/host/global/firewalls/sp-web-https",
+			},
+			Action: RuleActionAllow,
+		},
+		{
+			Title: "Deny",
+			Firewall: &compute.Firewall{
+				Name:                  "sp-web-smtp",
+				Direction:             RuleDirectionEgress,
+				Denied:                []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "tcp", Ports: []string{"25"}}},
+				DestinationRanges:     []string{"0.0.0.0/0"},
+				TargetServiceAccounts: []string{"web@sp.iam.gserviceaccount.com"},
+				Disabled:              true,
+			},
+			Action: RuleActionDeny,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			rule := NewRuleFromFirewall(test.Firewall)
+			if rule.Action != test.Action {
+				t.Errorf("Wrong action. Got %s want %s", rule.Action, test.Action)
+			}
+
+			firewall := rule.Firewall()
+			if !reflect.DeepEqual(firewall, test.Firewall) {
+				t.Errorf("Translation is not reversible. Got %+v want %+v", firewall, test.Firewall)
+			}
+		})
+	}
+}
+
+func TestRuleManager(t *testing.T) {
+	manager := NewRuleManager(NewMemoryBackend())
+
+	created, err := manager.CreateFirewallRule("host", &compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https"}})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if created.Id == 0 || created.CreationTimestamp == "" {
+		t.Errorf("Output only fields should be set. Got %+v", created)
+	}
+
+	_, err = manager.CreateFirewallRule("host", &compute.Firewall{Name: "sp-web-https"})
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict. Got %v", err)
+	}
+
+	updated, err := manager.UpdateFirewallRule("host", &compute.Firewall{Name: "sp-web-https", Disabled: true})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if !updated.Disabled || updated.Id != created.Id {
+		t.Errorf("Unexpected updated rule. Got %+v", updated)
+	}
+
+	quota, err := manager.GetFirewallQuota("host")
+	if err != nil || quota.Usage != 1 || quota.Limit != 0 {
+		t.Errorf("Unexpected quota. Got %+v, %v", quota, err)
+	}
+
+	err = manager.DeleteFirewallRule("host", "sp-web-https")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	_, err = manager.GetFirewallRule("host", "sp-web-https")
+	if e, ok := err.(*ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected rule to be deleted. Got %v", err)
+	}
+
+	rules, err := manager.ListFirewallRule("other")
+	if err != nil || len(rules) != 0 {
+		t.Errorf("Expected no rule in other project. Got %v, %v", rules, err)
+	}
+}
+
+func TestMemoryBackendCopiesRules(t *testing.T) {
+	backend := NewMemoryBackend()
+	rule := &Rule{
+		Name:         "sp-web-https",
+		Protocols:    []RuleProtocol{RuleProtocol{Protocol: "tcp", Ports: []string{"443"}}},
+		SourceRanges: []string{"10.0.0.0/8"},
+		TargetTags:   []string{"sp-web-https"},
+	}
+	created, err := backend.CreateRule("host", rule)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	// Neither the given rule nor the returned ones share slices with the stored rule
+	rule.SourceRanges[0] = "0.0.0.0/0"
+	created.TargetTags[0] = "other"
+	created.Protocols[0].Ports[0] = "22"
+	listed, _ := backend.ListRules("host")
+	listed[0].SourceRanges = append(listed[0].SourceRanges[:0], "192.168.0.0/16")
+
+	stored, err := backend.GetRule("host", "sp-web-https")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	expected := &Rule{
+		Name:              "sp-web-https",
+		Protocols:         []RuleProtocol{RuleProtocol{Protocol: "tcp", Ports: []string{"443"}}},
+		SourceRanges:      []string{"10.0.0.0/8"},
+		TargetTags:        []string{"sp-web-https"},
+		ID:                created.ID,
+		CreationTimestamp: created.CreationTimestamp,
+	}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Errorf("Stored rule has been modified. Got %+v", stored)
+	}
+}
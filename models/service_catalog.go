@@ -0,0 +1,61 @@
+package models
+
+import (
+	"sort"
+)
+
+// ServicePort describe a protocol and its ports, as a Google rule's allowed or denied entry
+type ServicePort struct {
+	IPProtocol string   `json:"IPProtocol" yaml:"IPProtocol"`
+	Ports      []string `json:"ports,omitempty" yaml:"ports"`
+}
+
+// Service describe a named set of protocols and ports usable in rules
+type Service struct {
+	Name        string        `json:"name" yaml:"name"`
+	Description string        `json:"description,omitempty" yaml:"description"`
+	Ports       []ServicePort `json:"ports" yaml:"ports"`
+}
+
+// ServiceCatalog describe services usable in rules, by name
+type ServiceCatalog map[string]Service
+
+// Services describe an end-user response listing the service catalog
+type Services struct {
+	Services []Service `json:"data"`
+}
+
+// DefaultServices returns the services available without configuration
+func DefaultServices() ServiceCatalog {
+	catalog := ServiceCatalog{}
+	for _, s := range []Service{
+		{Name: "dns", Description: "Domain Name System", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"53"}}, {IPProtocol: "udp", Ports: []string{"53"}}}},
+		{Name: "http", Description: "Web", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"80"}}}},
+		{Name: "https", Description: "Secure web", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"443"}}}},
+		{Name: "icmp", Description: "Ping and other ICMP messages", Ports: []ServicePort{{IPProtocol: "icmp"}}},
+		{Name: "ldap", Description: "Lightweight Directory Access Protocol", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"389"}}}},
+		{Name: "ldaps", Description: "LDAP over TLS", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"636"}}}},
+		{Name: "mongodb", Description: "MongoDB", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"27017"}}}},
+		{Name: "mysql", Description: "MySQL", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"3306"}}}},
+		{Name: "ntp", Description: "Network Time Protocol", Ports: []ServicePort{{IPProtocol: "udp", Ports: []string{"123"}}}},
+		{Name: "postgres", Description: "PostgreSQL", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"5432"}}}},
+		{Name: "rdp", Description: "Remote Desktop Protocol", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"3389"}}}},
+		{Name: "redis", Description: "Redis", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"6379"}}}},
+		{Name: "smtp", Description: "Simple Mail Transfer Protocol", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"25"}}}},
+		{Name: "ssh", Description: "Secure Shell", Ports: []ServicePort{{IPProtocol: "tcp", Ports: []string{"22"}}}},
+	} {
+		catalog[s.Name] = s
+	}
+	return catalog
+}
+
+// List returns catalog's services sorted by name
+func (c ServiceCatalog) List() []Service {
+	services := make([]Service, 0, len(c))
+	for _, s := range c {
+		services = append(services, s)
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Address group names follow Google resource names
+var addressGroupNameRegexp = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// ListAddressGroups returns address groups of the host project
+func ListAddressGroups(store models.AddressGroupStore, project string) (*models.AddressGroups, error) {
+	groups, err := store.ListAddressGroups(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AddressGroups{Project: project, Groups: groups}, nil
+}
+
+// SaveAddressGroup creates or replaces an address group of the host project,
+// then re-applies it to all rules referencing it, within the host project quotas
+func SaveAddressGroup(ctx context.Context, manager models.FirewallRuleManager, store models.AddressGroupStore, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project string, group models.AddressGroup) (*models.AddressGroupResult, error) {
+	if !addressGroupNameRegexp.MatchString(group.Name) {
+		return nil, models.NewBadRequestError(fmt.Sprintf("Invalid address group name [%s]. Must match %s", group.Name, addressGroupNameRegexp.String()))
+	}
+
+	if len(group.Ranges) == 0 {
+		return nil, models.NewBadRequestError("Address group must contain at least one range")
+	}
+
+	for _, r := range group.Ranges {
+		err := validateRange(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":       project,
+		"address_group": group.Name,
+	}).Debugf("Saving address group with %d ranges", len(group.Ranges))
+
+	err := store.SaveAddressGroup(project, group)
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := store.ListReferences(project, group.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-apply groups to referencing rules
+	results := runBatch(len(references), func(i int) models.BatchRuleResult {
+		ref := references[i]
+		applicationRule, err := reapplyAddressGroups(ctx, manager, store, serviceProjects, policy, project, ref)
+
+		result := newBatchRuleResult(ctx, ref.CustomName, firstRule(applicationRule), err, http.StatusOK)
+		result.ServiceProject = ref.ServiceProject
+		result.Application = ref.Application
+		return result
+	})
+
+	return &models.AddressGroupResult{Project: project, Group: group, Results: results}, nil
+}
+
+// DeleteAddressGroup deletes an address group of the host project which is not referenced by any rule
+func DeleteAddressGroup(ctx context.Context, store models.AddressGroupStore, project, name string) error {
+	references, err := store.ListReferences(project, name)
+	if err != nil {
+		return err
+	}
+
+	if len(references) > 0 {
+		return models.NewConflictError(fmt.Sprintf("Address group [%s] is referenced by %d rules", name, len(references)))
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":       project,
+		"address_group": name,
+	}).Debugln("Deleting address group")
+
+	return store.DeleteAddressGroup(project, name)
+}
+
+// ExpandAddressGroups adds ranges of address groups referenced by given rule request to its source and destination ranges.
+// Returns the reference to track once the rule is written, nil when no group is referenced
+func ExpandAddressGroups(store models.AddressGroupStore, project string, request *models.FirewallRuleRequest) (*models.AddressGroupReference, error) {
+	if len(request.SourceAddressGroups) == 0 && len(request.DestinationAddressGroups) == 0 {
+		return nil, nil
+	}
+
+	reference := &models.AddressGroupReference{
+		SourceAddressGroups:      request.SourceAddressGroups,
+		DestinationAddressGroups: request.DestinationAddressGroups,
+		SourceRanges:             request.SourceRanges,
+		DestinationRanges:        request.DestinationRanges,
+	}
+
+	sourceRanges, err := expandAddressGroups(store, project, request.SourceRanges, request.SourceAddressGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	destinationRanges, err := expandAddressGroups(store, project, request.DestinationRanges, request.DestinationAddressGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	request.SourceRanges = sourceRanges
+	request.DestinationRanges = destinationRanges
+	return reference, nil
+}
+
+// TrackAddressGroups records the address groups referenced by a written rule, so that it is updated when groups change.
+// A nil reference forgets them
+func TrackAddressGroups(store models.AddressGroupStore, project, serviceProject, application, customName string, reference *models.AddressGroupReference) error {
+	if reference == nil {
+		return store.DeleteReference(project, serviceProject, application, customName)
+	}
+
+	reference.ServiceProject = serviceProject
+	reference.Application = application
+	reference.CustomName = customName
+	return store.SaveReference(project, *reference)
+}
+
+// CopyAddressGroups makes a renamed rule reference the address groups of its old name too
+func CopyAddressGroups(store models.AddressGroupStore, project, serviceProject, application, customName, newApplication, newCustomName string) error {
+	reference, err := store.GetReference(project, serviceProject, application, customName)
+	if err != nil || reference == nil {
+		return err
+	}
+
+	return TrackAddressGroups(store, project, serviceProject, newApplication, newCustomName, reference)
+}
+
+// reapplyAddressGroups updates the referencing rule with current address groups ranges
+func reapplyAddressGroups(ctx context.Context, manager models.FirewallRuleManager, store models.AddressGroupStore, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project string, ref models.AddressGroupReference) (*models.ApplicationRule, error) {
+	current, err := GetFirewallRule(ctx, manager, project, ref.ServiceProject, ref.Application, ref.CustomName)
+	if err != nil {
+		return nil, err
+	}
+
+	request := models.FirewallRuleRequest{
+		Firewall:                 cloneRule(current.Rules[0].Rule, project, project),
+		SourceAddressGroups:      ref.SourceAddressGroups,
+		DestinationAddressGroups: ref.DestinationAddressGroups,
+	}
+	request.SourceRanges = ref.SourceRanges
+	request.DestinationRanges = ref.DestinationRanges
+
+	_, err = ExpandAddressGroups(store, project, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	return UpdateFirewallRule(ctx, manager, serviceProjects, policy, project, ref.ServiceProject, ref.Application, ref.CustomName, request.Firewall)
+}
+
+// expandAddressGroups returns given ranges followed by the ranges of given groups, without duplicates
+func expandAddressGroups(store models.AddressGroupStore, project string, ranges []string, groups []string) ([]string, error) {
+	if len(groups) == 0 {
+		return ranges, nil
+	}
+
+	seen := make(map[string]bool)
+	expanded := make([]string, 0, len(ranges))
+	add := func(r string) {
+		if !seen[r] {
+			seen[r] = true
+			expanded = append(expanded, r)
+		}
+	}
+
+	for _, r := range ranges {
+		add(r)
+	}
+
+	for _, name := range groups {
+		group, err := store.GetAddressGroup(project, name)
+		if e, ok := err.(*models.ApplicationError); ok && e.Code == http.StatusNotFound {
+			return nil, models.NewBadRequestError(e.Message)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range group.Ranges {
+			add(r)
+		}
+	}
+
+	return expanded, nil
+}
+
+// validateRange ensures given range is a CIDR range or an IP address
+func validateRange(r string) error {
+	if _, _, err := net.ParseCIDR(r); err == nil {
+		return nil
+	}
+
+	if net.ParseIP(r) != nil {
+		return nil
+	}
+
+	return models.NewBadRequestError(fmt.Sprintf("Invalid range [%s]", r))
+}
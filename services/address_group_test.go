@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestExpandAddressGroups(t *testing.T) {
+	store := models.NewAddressGroupMemoryStore()
+	store.SaveAddressGroup("host", models.AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/16", "192.168.0.1"}})
+	store.SaveAddressGroup("host", models.AddressGroup{Name: "vpn", Ranges: []string{"10.0.0.0/16", "10.1.0.0/16"}})
+
+	// Without group, rule is kept as is
+	request := models.FirewallRuleRequest{Firewall: compute.Firewall{SourceRanges: []string{"172.16.0.0/12"}}}
+	reference, err := ExpandAddressGroups(store, "host", &request)
+	if err != nil || reference != nil {
+		t.Fatalf("Unexpected result. Got %v, %v", reference, err)
+	}
+
+	request = models.FirewallRuleRequest{
+		Firewall:                 compute.Firewall{SourceRanges: []string{"172.16.0.0/12"}},
+		SourceAddressGroups:      []string{"office", "vpn"},
+		DestinationAddressGroups: []string{"vpn"},
+	}
+	reference, err = ExpandAddressGroups(store, "host", &request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := []string{"172.16.0.0/12", "10.0.0.0/16", "192.168.0.1", "10.1.0.0/16"}
+	if !reflect.DeepEqual(request.SourceRanges, expected) {
+		t.Errorf("Unexpected source ranges. Got %v want %v", request.SourceRanges, expected)
+	}
+
+	expected = []string{"10.0.0.0/16", "10.1.0.0/16"}
+	if !reflect.DeepEqual(request.DestinationRanges, expected) {
+		t.Errorf("Unexpected destination ranges. Got %v want %v", request.DestinationRanges, expected)
+	}
+
+	// Reference keeps ranges given along groups
+	if !reflect.DeepEqual(reference.SourceRanges, []string{"172.16.0.0/12"}) || len(reference.DestinationRanges) != 0 {
+		t.Errorf("Unexpected reference. Got %+v", reference)
+	}
+
+	// Unknown group
+	request = models.FirewallRuleRequest{SourceAddressGroups: []string{"partners"}}
+	_, err = ExpandAddressGroups(store, "host", &request)
+	assertErrorCode(t, err, http.StatusBadRequest)
+}
+
+func TestSaveAddressGroup(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	store := models.NewAddressGroupMemoryStore()
+
+	tests := []struct {
+		Title    string
+		Group    models.AddressGroup
+		Expected int
+	}{
+		{Title: "Invalid name", Group: models.AddressGroup{Name: "Office", Ranges: []string{"10.0.0.0/16"}}, Expected: http.StatusBadRequest},
+		{Title: "No range", Group: models.AddressGroup{Name: "office"}, Expected: http.StatusBadRequest},
+		{Title: "Invalid range", Group: models.AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/33"}}, Expected: http.StatusBadRequest},
+		{Title: "Valid group", Group: models.AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/16", "10.1.0.1"}}, Expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			_, err := SaveAddressGroup(context.Background(), manager, store, nil, nil, "host", test.Group)
+			assertErrorCode(t, err, test.Expected)
+		})
+	}
+
+	// Create a rule referencing the group
+	request := models.FirewallRuleRequest{
+		Firewall:            compute.Firewall{SourceRanges: []string{"172.16.0.0/12"}},
+		SourceAddressGroups: []string{"office"},
+	}
+	reference, _ := ExpandAddressGroups(store, "host", &request)
+	_, err := CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "https", request.Firewall)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	TrackAddressGroups(store, "host", "sp", "web", "https", reference)
+
+	// Changing the group re-applies it
+	addressGroupResult, err := SaveAddressGroup(context.Background(), manager, store, nil, nil, "host", models.AddressGroup{Name: "office", Ranges: []string{"10.2.0.0/16"}})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if len(addressGroupResult.Results) != 1 || addressGroupResult.Results[0].Code != http.StatusOK || addressGroupResult.Results[0].Application != "web" {
+		t.Errorf("Unexpected results. Got %+v", addressGroupResult.Results)
+	}
+
+	expected := []string{"172.16.0.0/12", "10.2.0.0/16"}
+	if !reflect.DeepEqual(manager.Rules["host"][0].SourceRanges, expected) {
+		t.Errorf("Rule has not been updated. Got %v want %v", manager.Rules["host"][0].SourceRanges, expected)
+	}
+
+	// Referenced group can't be deleted
+	err = DeleteAddressGroup(context.Background(), store, "host", "office")
+	assertErrorCode(t, err, http.StatusConflict)
+
+	// Renamed rule is still updated
+	err = CopyAddressGroups(store, "host", "sp", "web", "https", "web", "tls")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	TrackAddressGroups(store, "host", "sp", "web", "https", nil)
+	references, _ := store.ListReferences("host", "office")
+	if len(references) != 1 || references[0].CustomName != "tls" {
+		t.Errorf("Reference has not been moved. Got %v", references)
+	}
+
+	TrackAddressGroups(store, "host", "sp", "web", "tls", nil)
+	err = DeleteAddressGroup(context.Background(), store, "host", "office")
+	assertErrorCode(t, err, 0)
+}
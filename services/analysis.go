@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"google.golang.org/api/compute/v1"
+)
+
+// AnalyzeProjectRules reports shadowed, duplicate and overlapping rules of the host project
+func AnalyzeProjectRules(ctx context.Context, manager models.FirewallRuleManager, project string) (*models.RuleAnalysis, error) {
+	rules, err := manager.ListFirewallRule(project)
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Logger(ctx).WithField("project", project).Debugf("Analyzing %d rules", len(rules))
+	return &models.RuleAnalysis{Project: project, Findings: AnalyzeFirewallRules(rules)}, nil
+}
+
+// AnalyzeApplicationRules reports shadowed, duplicate and overlapping rules involving the application rules,
+// against all rules of the host project
+func AnalyzeApplicationRules(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application string) (*models.RuleAnalysis, error) {
+	applicationRule, err := ListFirewallRule(ctx, manager, project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(applicationRule.Rules))
+	for _, rule := range applicationRule.Rules {
+		names = append(names, rule.Rule.Name)
+	}
+
+	analysis, err := AnalyzeProjectRules(ctx, manager, project)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]models.RuleFinding, 0)
+	for _, finding := range analysis.Findings {
+		if containsString(names, finding.Rule) || containsString(names, finding.By) {
+			findings = append(findings, finding)
+		}
+	}
+
+	analysis.ServiceProject = serviceProject
+	analysis.Application = application
+	analysis.Findings = findings
+	return analysis, nil
+}
+
+// analyzedRule is a rule with its sets of targets, peers and ports
+type analyzedRule struct {
+	rule      *compute.Firewall
+	direction string
+	deny      bool
+	targets   targetSet
+	peers     addressSet
+	ports     portSet
+}
+
+func newAnalyzedRule(rule *compute.Firewall) analyzedRule {
+	a := analyzedRule{
+		rule:      rule,
+		direction: rule.Direction,
+		deny:      len(rule.Denied) > 0,
+		targets:   targetSet{tags: rule.TargetTags, serviceAccounts: rule.TargetServiceAccounts},
+		ports:     newPortSet(rule),
+	}
+
+	if a.direction == "" {
+		a.direction = directionIngress
+	}
+
+	if a.direction == directionEgress {
+		a.peers = newAddressSet(rule.DestinationRanges, nil, nil)
+	} else {
+		a.peers = newAddressSet(rule.SourceRanges, rule.SourceTags, rule.SourceServiceAccounts)
+	}
+	return a
+}
+
+// precedes returns whether the rule is evaluated before o: lower priority first, deny first on ties
+func (a analyzedRule) precedes(o analyzedRule) bool {
+	if a.rule.Priority != o.rule.Priority {
+		return a.rule.Priority < o.rule.Priority
+	}
+	return a.deny && !o.deny
+}
+
+// covers returns whether all traffic matching o matches the rule
+func (a analyzedRule) covers(o analyzedRule) bool {
+	return a.targets.covers(o.targets) && a.peers.covers(o.peers) && a.ports.covers(o.ports)
+}
+
+func (a analyzedRule) intersects(o analyzedRule) bool {
+	return a.targets.intersects(o.targets) && a.peers.intersects(o.peers) && a.ports.intersects(o.ports)
+}
+
+func (a analyzedRule) action() string {
+	if a.deny {
+		return "deny"
+	}
+	return "allow"
+}
+
+// AnalyzeFirewallRules compares enabled rules of the same network and direction by pairs, and reports:
+// rules covered by a rule taking precedence as shadowed, rules with the same effect as duplicates,
+// and rules sharing part of their traffic as overlapping. Each rule is reported once as shadowed or duplicate,
+// by the first rule in evaluation order
+func AnalyzeFirewallRules(rules []*compute.Firewall) []models.RuleFinding {
+	analyzed := make([]analyzedRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Disabled {
+			analyzed = append(analyzed, newAnalyzedRule(rule))
+		}
+	}
+
+	// Evaluation order, then names for stable results
+	sort.SliceStable(analyzed, func(i, j int) bool {
+		if analyzed[i].precedes(analyzed[j]) || analyzed[j].precedes(analyzed[i]) {
+			return analyzed[i].precedes(analyzed[j])
+		}
+		return analyzed[i].rule.Name < analyzed[j].rule.Name
+	})
+
+	findings := make([]models.RuleFinding, 0)
+	for j, b := range analyzed {
+		covered := false
+		for i, a := range analyzed {
+			if i == j || a.direction != b.direction || !sameNetwork(a.rule.Network, b.rule.Network) {
+				continue
+			}
+
+			mutual := a.covers(b) && b.covers(a)
+			switch {
+			// Report duplicates on the second rule only
+			case !covered && i < j && mutual && a.deny == b.deny && a.rule.Priority == b.rule.Priority:
+				covered = true
+				findings = append(findings, newRuleFinding(models.FindingDuplicate, b, a,
+					fmt.Sprintf("Rule [%s] has the same effect as rule [%s]", b.rule.Name, a.rule.Name)))
+
+			// Rules are sorted, the first one is evaluated first or has the same effect
+			case !covered && i < j && a.covers(b):
+				covered = true
+				message := fmt.Sprintf("Rule [%s] is redundant, its traffic is already %s by rule [%s]", b.rule.Name, pastAction(a), a.rule.Name)
+				if a.deny != b.deny {
+					message = fmt.Sprintf("Rule [%s] never applies, its traffic is %s by rule [%s] first", b.rule.Name, pastAction(a), a.rule.Name)
+				}
+				findings = append(findings, newRuleFinding(models.FindingShadowed, b, a, message))
+
+			// Report overlaps once per pair
+			case i < j && !a.covers(b) && !b.covers(a) && a.intersects(b):
+				findings = append(findings, newRuleFinding(models.FindingOverlapping, b, a,
+					fmt.Sprintf("Rule [%s] (%s) shares part of its traffic with rule [%s] (%s)", b.rule.Name, b.action(), a.rule.Name, a.action())))
+			}
+		}
+	}
+
+	return findings
+}
+
+func newRuleFinding(kind string, rule, by analyzedRule, message string) models.RuleFinding {
+	return models.RuleFinding{
+		Kind:       kind,
+		Rule:       rule.rule.Name,
+		Priority:   rule.rule.Priority,
+		By:         by.rule.Name,
+		ByPriority: by.rule.Priority,
+		Message:    message,
+	}
+}
+
+func pastAction(a analyzedRule) string {
+	if a.deny {
+		return "denied"
+	}
+	return "allowed"
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestIntervalSet(t *testing.T) {
+	tests := []struct {
+		Title      string
+		Set        []string
+		Other      []string
+		Covers     bool
+		Intersects bool
+	}{
+		{Title: "Same range", Set: []string{"10.0.0.0/8"}, Other: []string{"10.0.0.0/8"}, Covers: true, Intersects: true},
+		{Title: "Sub range", Set: []string{"10.0.0.0/8"}, Other: []string{"10.1.0.0/16", "10.2.3.4"}, Covers: true, Intersects: true},
+		{Title: "Super range", Set: []string{"10.1.0.0/16"}, Other: []string{"10.0.0.0/8"}, Covers: false, Intersects: true},
+		{Title: "Adjacent ranges", Set: []string{"10.0.0.0/9", "10.128.0.0/9"}, Other: []string{"10.0.0.0/8"}, Covers: true, Intersects: true},
+		{Title: "Disjoint ranges", Set: []string{"10.0.0.0/8"}, Other: []string{"192.168.0.0/16"}, Covers: false, Intersects: false},
+		{Title: "IPv6", Set: []string{"2001:db8::/32"}, Other: []string{"2001:db8:1::/48"}, Covers: true, Intersects: true},
+		{Title: "IPv4 and IPv6", Set: []string{"0.0.0.0/0"}, Other: []string{"2001:db8::/32"}, Covers: false, Intersects: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			var set, other intervalSet
+			for _, r := range test.Set {
+				i, _ := ipInterval(r)
+				set = append(set, i)
+			}
+			for _, r := range test.Other {
+				i, _ := ipInterval(r)
+				other = append(other, i)
+			}
+
+			if got := set.covers(other); got != test.Covers {
+				t.Errorf("Covers: got %v want %v", got, test.Covers)
+			}
+			if got := set.intersects(other); got != test.Intersects {
+				t.Errorf("Intersects: got %v want %v", got, test.Intersects)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFirewallRules(t *testing.T) {
+	allow := func(protocol string, ports ...string) []*compute.FirewallAllowed {
+		return []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: protocol, Ports: ports}}
+	}
+	deny := func(protocol string, ports ...string) []*compute.FirewallDenied {
+		return []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: protocol, Ports: ports}}
+	}
+
+	tests := []struct {
+		Title    string
+		Rules    []*compute.Firewall
+		Expected []models.RuleFinding
+	}{
+		{
+			Title: "Shadowed by a deny rule",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "deny-all", Network: testNetwork, Priority: 100, SourceRanges: []string{"0.0.0.0/0"}, Denied: deny("all")},
+				&compute.Firewall{Name: "web", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceRanges: []string{"10.0.0.0/8"}, Allowed: allow("tcp", "443")},
+			},
+			Expected: []models.RuleFinding{{Kind: models.FindingShadowed, Rule: "web", Priority: 1000, By: "deny-all", ByPriority: 100}},
+		},
+		{
+			Title: "Redundant with a broader allow rule",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "web-narrow", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceRanges: []string{"10.1.0.0/16"}, Allowed: allow("tcp", "443")},
+				&compute.Firewall{Name: "web-broad", Network: testNetwork, Priority: 900, SourceRanges: []string{"10.0.0.0/9", "10.128.0.0/9"}, Allowed: allow("tcp", "80", "400-500")},
+			},
+			Expected: []models.RuleFinding{{Kind: models.FindingShadowed, Rule: "web-narrow", Priority: 1000, By: "web-broad", ByPriority: 900}},
+		},
+		{
+			Title: "Duplicates",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "b", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceRanges: []string{"10.0.0.0/8"}, Allowed: allow("6", "443")},
+				&compute.Firewall{Name: "a", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceRanges: []string{"10.0.0.0/8"}, Allowed: allow("tcp", "443")},
+			},
+			Expected: []models.RuleFinding{{Kind: models.FindingDuplicate, Rule: "b", Priority: 1000, By: "a", ByPriority: 1000}},
+		},
+		{
+			Title: "Overlapping",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "deny-range", Network: testNetwork, Priority: 1000, SourceRanges: []string{"10.0.0.0/8"}, Denied: deny("tcp", "8000-9000")},
+				&compute.Firewall{Name: "allow-range", Network: testNetwork, Priority: 1000, SourceRanges: []string{"10.1.0.0/16", "192.168.0.0/16"}, Allowed: allow("tcp", "8080")},
+			},
+			Expected: []models.RuleFinding{{Kind: models.FindingOverlapping, Rule: "allow-range", Priority: 1000, By: "deny-range", ByPriority: 1000}},
+		},
+		{
+			Title: "Exception before a broader rule",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "deny-bad-host", Network: testNetwork, Priority: 100, SourceRanges: []string{"10.6.6.6"}, Denied: deny("all")},
+				&compute.Firewall{Name: "allow-internal", Network: testNetwork, Priority: 1000, SourceRanges: []string{"10.0.0.0/8"}, Allowed: allow("all")},
+			},
+			Expected: []models.RuleFinding{},
+		},
+		{
+			Title: "Independent rules",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "web", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, Allowed: allow("tcp", "443")},
+				&compute.Firewall{Name: "db", Network: testNetwork, Priority: 1000, TargetTags: []string{"db"}, Allowed: allow("tcp", "443")},
+				&compute.Firewall{Name: "web-udp", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, Allowed: allow("udp", "443")},
+				&compute.Firewall{Name: "other-network", Network: "global/networks/other", Priority: 1, Denied: deny("all")},
+				&compute.Firewall{Name: "egress", Network: testNetwork, Direction: "EGRESS", Priority: 1, Denied: deny("all")},
+				&compute.Firewall{Name: "disabled", Network: testNetwork, Priority: 1, Disabled: true, Denied: deny("all")},
+			},
+			Expected: []models.RuleFinding{},
+		},
+		{
+			Title: "Source tags are covered by all IPv4 addresses",
+			Rules: []*compute.Firewall{
+				&compute.Firewall{Name: "from-api", Network: testNetwork, Priority: 1000, SourceTags: []string{"api"}, Allowed: allow("tcp", "9000")},
+				&compute.Firewall{Name: "from-all", Network: testNetwork, Priority: 900, SourceRanges: []string{"0.0.0.0/0"}, Allowed: allow("tcp")},
+			},
+			Expected: []models.RuleFinding{{Kind: models.FindingShadowed, Rule: "from-api", Priority: 1000, By: "from-all", ByPriority: 900}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			findings := AnalyzeFirewallRules(test.Rules)
+			if len(findings) != len(test.Expected) {
+				t.Fatalf("Wrong findings count. Got %+v want %+v", findings, test.Expected)
+			}
+
+			for i, finding := range findings {
+				finding.Message = ""
+				if finding != test.Expected[i] {
+					t.Errorf("Got %+v want %+v", finding, test.Expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeApplicationRules(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.CreateFirewallRule("host", &compute.Firewall{Name: "deny-all", Network: testNetwork, Priority: 100, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}})
+	manager.CreateFirewallRule("host", &compute.Firewall{Name: "deny-all-copy", Network: testNetwork, Priority: 100, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}})
+	CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "https", compute.Firewall{Network: testNetwork, Priority: 1000, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"443"}}}})
+
+	analysis, err := AnalyzeApplicationRules(context.Background(), manager, "host", "sp", "web")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if analysis.Application != "web" || len(analysis.Findings) != 1 || analysis.Findings[0].Rule != "sp-web-https" {
+		t.Errorf("Only findings of application rules are expected. Got %+v", analysis)
+	}
+
+	analysis, err = AnalyzeProjectRules(context.Background(), manager, "host")
+	if err != nil || len(analysis.Findings) != 2 {
+		t.Errorf("Unexpected project analysis. Got %+v, %v", analysis, err)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Maximum number of concurrent Google calls within a batch
+const batchConcurrency = 8
+
+// BatchCreateFirewallRules prepares given named rules as on creation, then creates them in parallel and returns each rule's result.
+// A rule failing to be prepared is reported in its result, without preventing the others from being created
+func BatchCreateFirewallRules(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, prepare PrepareRuleFunc, project, serviceProject, application string, rules []models.BatchRuleRequest) *models.BatchResult {
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+	}).Debugf("Creating %d rules", len(rules))
+
+	// Reject invalid names before calling Google
+	invalid := make(map[int]error)
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		switch {
+		case rule.CustomName == "":
+			invalid[i] = models.NewBadRequestError("Missing custom_name")
+		case seen[rule.CustomName]:
+			invalid[i] = models.NewBadRequestError(fmt.Sprintf("Duplicated custom_name [%s]", rule.CustomName))
+		}
+		seen[rule.CustomName] = true
+	}
+
+	// Prepare rules with valid names, reporting failures as for invalid names
+	for i := range rules {
+		if _, ok := invalid[i]; !ok {
+			err := prepare(rules[i].CustomName, &rules[i].Rule)
+			if err != nil {
+				invalid[i] = err
+			}
+		}
+	}
+
+	// Valid rules are created together or not at all when exceeding quotas
+	valid := make([]*compute.Firewall, 0, len(rules))
+	for i := range rules {
+		if _, ok := invalid[i]; !ok {
+			valid = append(valid, &rules[i].Rule.Firewall)
+		}
+	}
+
+	quotaErr := CheckQuota(ctx, manager, serviceProjects, policy, project, serviceProject, application, valid...)
+
+	results := runBatch(len(rules), func(i int) models.BatchRuleResult {
+		if err, ok := invalid[i]; ok {
+			return newBatchRuleResult(ctx, rules[i].CustomName, nil, err, http.StatusCreated)
+		}
+
+		if quotaErr != nil {
+			return newBatchRuleResult(ctx, rules[i].CustomName, nil, quotaErr, http.StatusCreated)
+		}
+
+		applicationRule, err := createFirewallRule(ctx, manager, project, serviceProject, application, rules[i].CustomName, rules[i].Rule.Firewall)
+		if err != nil {
+			return newBatchRuleResult(ctx, rules[i].CustomName, nil, err, http.StatusCreated)
+		}
+		return newBatchRuleResult(ctx, rules[i].CustomName, &applicationRule.Rules[0].Rule, nil, http.StatusCreated)
+	})
+
+	return &models.BatchResult{
+		Application:    application,
+		Project:        project,
+		ServiceProject: serviceProject,
+		Results:        results,
+	}
+}
+
+// DeleteApplicationFirewallRules deletes all rules of an application in parallel and returns each rule's result
+func DeleteApplicationFirewallRules(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application string) (*models.BatchResult, error) {
+	applicationRule, err := ListFirewallRule(ctx, manager, project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(applicationRule.Rules))
+	for i, rule := range applicationRule.Rules {
+		names[i] = rule.CustomName
+	}
+
+	results := runBatch(len(names), func(i int) models.BatchRuleResult {
+		err := DeleteFirewallRule(ctx, manager, project, serviceProject, application, names[i])
+		return newBatchRuleResult(ctx, names[i], nil, err, http.StatusNoContent)
+	})
+
+	return &models.BatchResult{
+		Application:    application,
+		Project:        project,
+		ServiceProject: serviceProject,
+		Results:        results,
+	}, nil
+}
+
+// runBatch calls fn for each index up to count with bounded concurrency. Results keep the given order
+func runBatch(count int, fn func(i int) models.BatchRuleResult) []models.BatchRuleResult {
+	results := make([]models.BatchRuleResult, count)
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func newBatchRuleResult(ctx context.Context, customName string, rule *compute.Firewall, err error, successCode int) models.BatchRuleResult {
+	if err == nil {
+		return models.BatchRuleResult{CustomName: customName, Code: successCode, Rule: rule}
+	}
+
+	e, ok := err.(*models.ApplicationError)
+	if !ok {
+		helpers.Logger(ctx).WithFields(logrus.Fields{
+			"go-err":      err.Error(),
+			"custom_name": customName,
+		}).Error("Unexpected error")
+		e = models.NewInternalError()
+	}
+
+	return models.BatchRuleResult{CustomName: customName, Code: e.Code, Error: e}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// prepareNothing leaves rule requests as they are
+func prepareNothing(string, *models.FirewallRuleRequest) error {
+	return nil
+}
+
+func TestBatchCreateFirewallRules(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	project := "dummy-project"
+	serviceProject := "dummy-service_project"
+	application := "dummy-application"
+
+	var rules []models.BatchRuleRequest
+	for i := 0; i < 20; i++ {
+		rules = append(rules, models.BatchRuleRequest{
+			CustomName: fmt.Sprintf("rule-%d", i),
+			Rule:       models.FirewallRuleRequest{Firewall: compute.Firewall{Network: "global/networks/default"}},
+		})
+	}
+	rules = append(rules, models.BatchRuleRequest{CustomName: "rule-0"}, models.BatchRuleRequest{})
+
+	batchResult := BatchCreateFirewallRules(context.Background(), manager, nil, nil, prepareNothing, project, serviceProject, application, rules)
+	if len(batchResult.Results) != len(rules) {
+		t.Fatalf("Wrong results count. Got %d expected %d", len(batchResult.Results), len(rules))
+	}
+
+	// Results keep the requested order
+	for i := 0; i < 20; i++ {
+		result := batchResult.Results[i]
+		if result.CustomName != rules[i].CustomName || result.Code != http.StatusCreated || result.Rule == nil {
+			t.Errorf("Unexpected result for %s. Got %+v", rules[i].CustomName, result)
+		}
+	}
+
+	for _, result := range batchResult.Results[20:] {
+		if result.Code != http.StatusBadRequest || result.Error == nil {
+			t.Errorf("Expected a bad request result for %q. Got %+v", result.CustomName, result)
+		}
+	}
+
+	if len(manager.Rules[project]) != 20 {
+		t.Errorf("Wrong rules count. Got %d expected %d", len(manager.Rules[project]), 20)
+	}
+
+	// Creating existing rules should report each failure
+	batchResult = BatchCreateFirewallRules(context.Background(), manager, nil, nil, prepareNothing, project, serviceProject, application, rules[:2])
+	for _, result := range batchResult.Results {
+		if result.Code != http.StatusInternalServerError {
+			t.Errorf("Expected an error result for %s. Got %+v", result.CustomName, result)
+		}
+	}
+}
+
+func TestBatchCreateFirewallRulesPrepareFailure(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		if customName == "ssh" {
+			return models.NewBadRequestError("Unknown address group [office]")
+		}
+		return nil
+	}
+
+	batchResult := BatchCreateFirewallRules(context.Background(), manager, nil, nil, prepare, "host", "sp", "web", []models.BatchRuleRequest{
+		{CustomName: "http"},
+		{CustomName: "ssh"},
+		{CustomName: "https"},
+	})
+
+	// The rule failing to be prepared does not prevent the others from being created
+	expected := []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated}
+	for i, result := range batchResult.Results {
+		if result.Code != expected[i] {
+			t.Errorf("Wrong code for rule %d. Got %d want %d", i, result.Code, expected[i])
+		}
+	}
+
+	if len(manager.Rules["host"]) != 2 {
+		t.Errorf("Wrong rules count. Got %d want %d", len(manager.Rules["host"]), 2)
+	}
+}
+
+func TestDeleteAllApplicationFirewallRules(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	project := "dummy-project"
+	serviceProject := "dummy-service_project"
+
+	for _, application := range []string{"front", "back"} {
+		for i := 0; i < 10; i++ {
+			name := fmt.Sprintf("%s-%s-rule-%d", serviceProject, application, i)
+			manager.Rules[project] = append(manager.Rules[project], &compute.Firewall{Name: name})
+		}
+	}
+
+	batchResult, err := DeleteApplicationFirewallRules(context.Background(), manager, project, serviceProject, "front")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if len(batchResult.Results) != 10 {
+		t.Errorf("Wrong results count. Got %d expected %d", len(batchResult.Results), 10)
+	}
+
+	for _, result := range batchResult.Results {
+		if result.Code != http.StatusNoContent {
+			t.Errorf("Unexpected result for %s. Got %+v", result.CustomName, result)
+		}
+	}
+
+	// Other application rules must be kept
+	applicationRule, _ := ListFirewallRule(context.Background(), manager, project, serviceProject, "back")
+	if len(applicationRule.Rules) != 10 {
+		t.Errorf("Wrong rules count. Got %d expected %d", len(applicationRule.Rules), 10)
+	}
+
+	// Non-existing project
+	_, err = DeleteApplicationFirewallRules(context.Background(), manager, "non-existing-project", serviceProject, "front")
+	if err == nil {
+		t.Errorf("Expected error on a non-existing project")
+	}
+}
+
+func TestDeleteApplicationFirewallRulesSharingPrefix(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	rule := compute.Firewall{Network: "global/networks/default"}
+	for _, application := range []string{"web", "web-admin"} {
+		_, err := CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", application, "https", rule)
+		if err != nil {
+			t.Fatalf("Unexpected error. Got %v", err)
+		}
+	}
+
+	// web-admin-https of web is sp-web-admin-https, named like https of web-admin
+	batchResult, err := DeleteApplicationFirewallRules(context.Background(), manager, "host", "sp", "web")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if len(batchResult.Results) != 1 || batchResult.Results[0].CustomName != "https" {
+		t.Errorf("Expected only web rules to be deleted. Got %+v", batchResult.Results)
+	}
+
+	err = DeleteFirewallRule(context.Background(), manager, "host", "sp", "web", "admin-https")
+	if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusNotFound {
+		t.Errorf("Expected a not found error. Got %v", err)
+	}
+
+	applicationRule, _ := ListFirewallRule(context.Background(), manager, "host", "sp", "web-admin")
+	if len(applicationRule.Rules) != 1 {
+		t.Errorf("Expected web-admin rules to be kept. Got %+v", applicationRule.Rules)
+	}
+}
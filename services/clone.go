@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// PrepareRuleFunc turns the request of a rule named customName into the Google rule to write, in place,
+// the way rule creation does: expanding its references, applying priority band and guardrails, and resolving its network
+type PrepareRuleFunc func(customName string, request *models.FirewallRuleRequest) error
+
+// CloneFirewallRules copies all rules of an application to the destination described by the given request.
+// Rule names, target tags, source applications and priorities are rewritten to the destination's scheme, and address groups
+// are referenced again, then each rule is prepared for the destination as on creation. Networks must be shared with the destination
+// and address groups must exist there
+func CloneFirewallRules(ctx context.Context, manager models.FirewallRuleManager, addressGroupStore models.AddressGroupStore, dependencyStore models.DependencyStore, priorityBandStore models.PriorityBandStore, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, prepare PrepareRuleFunc, project, serviceProject, application string, request models.CloneRequest) (*models.BatchResult, error) {
+	if request.Application == "" {
+		request.Application = application
+	}
+
+	if request.Conflict == "" {
+		request.Conflict = models.CloneConflictFail
+	}
+
+	switch request.Conflict {
+	case models.CloneConflictFail, models.CloneConflictSkip, models.CloneConflictOverwrite:
+	default:
+		return nil, models.NewBadRequestError(fmt.Sprintf("Unknown conflict option [%s]. Expected one of %s, %s or %s", request.Conflict, models.CloneConflictFail, models.CloneConflictSkip, models.CloneConflictOverwrite))
+	}
+
+	if request.Project == project && request.ServiceProject == serviceProject && request.Application == application {
+		return nil, models.NewBadRequestError("Destination is the same as the source")
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":                     project,
+		"service_project":             serviceProject,
+		"application":                 application,
+		"destination_project":         request.Project,
+		"destination_service_project": request.ServiceProject,
+		"destination_application":     request.Application,
+		"conflict":                    request.Conflict,
+		"dry_run":                     request.DryRun,
+	}).Debugln("Cloning rules")
+
+	source, err := ListFirewallRule(ctx, manager, project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	destination, err := ListFirewallRule(ctx, manager, request.Project, request.ServiceProject, request.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool)
+	for _, rule := range destination.Rules {
+		existing[rule.CustomName] = true
+	}
+
+	// Plan each rule's action
+	actions := make([]string, len(source.Rules))
+	var conflicts []string
+	for i, rule := range source.Rules {
+		actions[i] = models.CloneActionCreate
+		if existing[rule.CustomName] {
+			conflicts = append(conflicts, rule.CustomName)
+			actions[i] = models.CloneActionOverwrite
+			if request.Conflict == models.CloneConflictSkip {
+				actions[i] = models.CloneActionSkip
+			}
+		}
+	}
+
+	if len(conflicts) > 0 && request.Conflict == models.CloneConflictFail {
+		return nil, models.NewConflictError(fmt.Sprintf("Rules [%s] already exist in destination", strings.Join(conflicts, ", ")))
+	}
+
+	// Prepare rules for the destination. A rule failing to be prepared is reported in its result
+	rules := make([]compute.Firewall, len(source.Rules))
+	prepareErrs := make([]error, len(source.Rules))
+	var created []*compute.Firewall
+	for i, rule := range source.Rules {
+		if actions[i] == models.CloneActionSkip {
+			continue
+		}
+
+		var cloneRequest *models.FirewallRuleRequest
+		cloneRequest, prepareErrs[i] = cloneRuleRequest(addressGroupStore, dependencyStore, priorityBandStore, project, serviceProject, application, rule, request)
+		if prepareErrs[i] == nil {
+			prepareErrs[i] = prepare(rule.CustomName, cloneRequest)
+		}
+		if prepareErrs[i] != nil {
+			continue
+		}
+
+		rules[i] = cloneRequest.Firewall
+		if actions[i] == models.CloneActionCreate {
+			created = append(created, &rules[i])
+		}
+	}
+
+	err = CheckQuota(ctx, manager, serviceProjects, policy, request.Project, request.ServiceProject, request.Application, created...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := runBatch(len(source.Rules), func(i int) models.BatchRuleResult {
+		customName := source.Rules[i].CustomName
+		rule := rules[i]
+
+		var result models.BatchRuleResult
+		switch {
+		case actions[i] == models.CloneActionSkip:
+			result = newBatchRuleResult(ctx, customName, nil, nil, http.StatusOK)
+		case prepareErrs[i] != nil:
+			result = newBatchRuleResult(ctx, customName, nil, prepareErrs[i], http.StatusOK)
+		case request.DryRun:
+			// Preview the rule as it would be written
+			rule.Name = fmt.Sprintf("%s-%s-%s", request.ServiceProject, request.Application, customName)
+			rule.TargetTags = []string{rule.Name}
+			code := http.StatusCreated
+			if actions[i] == models.CloneActionOverwrite {
+				code = http.StatusOK
+			}
+			result = newBatchRuleResult(ctx, customName, &rule, nil, code)
+		case actions[i] == models.CloneActionOverwrite:
+			applicationRule, err := UpdateFirewallRule(ctx, manager, serviceProjects, policy, request.Project, request.ServiceProject, request.Application, customName, rule)
+			result = newBatchRuleResult(ctx, customName, firstRule(applicationRule), err, http.StatusOK)
+		default:
+			applicationRule, err := createFirewallRule(ctx, manager, request.Project, request.ServiceProject, request.Application, customName, rule)
+			result = newBatchRuleResult(ctx, customName, firstRule(applicationRule), err, http.StatusCreated)
+		}
+
+		result.Action = actions[i]
+		return result
+	})
+
+	return &models.BatchResult{
+		Application:    request.Application,
+		Project:        request.Project,
+		ServiceProject: request.ServiceProject,
+		Results:        results,
+	}, nil
+}
+
+// cloneRuleRequest returns the request of a copy of given application rule in the destination of given clone request.
+// Address groups replace their ranges to be expanded in the destination, source applications of the rule's service project are
+// moved to the destination one, and the priority is made relative to the application band
+func cloneRuleRequest(addressGroupStore models.AddressGroupStore, dependencyStore models.DependencyStore, priorityBandStore models.PriorityBandStore, project, serviceProject, application string, rule models.FirewallRule, request models.CloneRequest) (*models.FirewallRuleRequest, error) {
+	cloneRequest := &models.FirewallRuleRequest{Firewall: cloneRule(rule.Rule, project, request.Project)}
+
+	priority, err := bandedPriority(priorityBandStore, project, serviceProject, application, rule)
+	if err != nil {
+		return nil, err
+	}
+	cloneRequest.Priority = priority
+
+	reference, err := addressGroupStore.GetReference(project, serviceProject, application, rule.CustomName)
+	if err != nil {
+		return nil, err
+	}
+	if reference != nil {
+		cloneRequest.SourceRanges = append([]string(nil), reference.SourceRanges...)
+		cloneRequest.DestinationRanges = append([]string(nil), reference.DestinationRanges...)
+		cloneRequest.SourceAddressGroups = append([]string(nil), reference.SourceAddressGroups...)
+		cloneRequest.DestinationAddressGroups = append([]string(nil), reference.DestinationAddressGroups...)
+	}
+
+	dependency, err := dependencyStore.GetDependency(project, serviceProject, application, rule.CustomName)
+	if err != nil || dependency == nil {
+		return cloneRequest, err
+	}
+
+	// Tags of source applications are expanded again in the destination
+	var tags []string
+	for _, tag := range cloneRequest.SourceTags {
+		if !isSourceApplicationTag(dependency.SourceApplications, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	cloneRequest.SourceTags = tags
+
+	for _, source := range dependency.SourceApplications {
+		if source.ServiceProject == serviceProject {
+			source.ServiceProject = request.ServiceProject
+		}
+		cloneRequest.SourceApplications = append(cloneRequest.SourceApplications, source)
+	}
+	return cloneRequest, nil
+}
+
+// isSourceApplicationTag tells whether given tag is the target tag of a rule of given source applications
+func isSourceApplicationTag(sources []models.SourceApplication, tag string) bool {
+	for _, source := range sources {
+		if strings.HasPrefix(tag, fmt.Sprintf("%s-%s-", source.ServiceProject, source.Application)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneRule returns a copy of given rule without its output only fields, targeting the destination project
+func cloneRule(rule compute.Firewall, project, destinationProject string) compute.Firewall {
+	rule.Id = 0
+	rule.CreationTimestamp = ""
+	rule.SelfLink = ""
+	rule.Kind = ""
+	rule.ServerResponse = googleapi.ServerResponse{}
+	rule.Network = strings.Replace(rule.Network, fmt.Sprintf("/projects/%s/", project), fmt.Sprintf("/projects/%s/", destinationProject), 1)
+	return rule
+}
+
+// firstRule returns the first rule of given application rules, nil if none
+func firstRule(applicationRule *models.ApplicationRule) *compute.Firewall {
+	if applicationRule == nil || len(applicationRule.Rules) == 0 {
+		return nil
+	}
+	return &applicationRule.Rules[0].Rule
+}
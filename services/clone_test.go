@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// newCloneDummyClient returns a manager with 2 rules for application "web" in a dev Landing Zone,
+// and a prod Landing Zone already containing one of them
+func newCloneDummyClient() *fakes.FirewallRuleDummyClient {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	for _, name := range []string{"https", "ssh"} {
+		manager.Rules["dev-host"] = append(manager.Rules["dev-host"], &compute.Firewall{
+			Id:         1,
+			Name:       fmt.Sprintf("dev-sp-web-%s", name),
+			TargetTags: []string{fmt.Sprintf("dev-sp-web-%s", name)},
+			Network:    "https://www.googleapis.com/compute/v1/projects/dev-host/global/networks/dev-network",
+		})
+	}
+	manager.Rules["prod-host"] = []*compute.Firewall{&compute.Firewall{Name: "prod-sp-web-ssh", TargetTags: []string{"prod-sp-web-ssh"}}}
+	return manager
+}
+
+// newCloneNetworkDummyClient returns networks shared with the prod Landing Zone
+func newCloneNetworkDummyClient() *fakes.NetworkDummyClient {
+	return &fakes.NetworkDummyClient{Networks: map[string][]models.Network{
+		"prod-host/prod-sp": []models.Network{
+			models.Network{Name: "dev-network", SelfLink: "https://www.googleapis.com/compute/v1/projects/prod-host/global/networks/dev-network"},
+		},
+	}}
+}
+
+// newClonePrepare returns a preparation of cloned rules for application "web" of the prod Landing Zone, as rule creation does
+func newClonePrepare(manager models.FirewallRuleManager, networkManager models.NetworkManager, priorityBandStore models.PriorityBandStore) PrepareRuleFunc {
+	return func(customName string, request *models.FirewallRuleRequest) error {
+		_, err := ExpandSourceApplications(context.Background(), manager, "prod-host", "prod-sp", request)
+		if err != nil {
+			return err
+		}
+
+		err = ApplyPriorityBand(priorityBandStore, "prod-host", "prod-sp", "web", request)
+		if err != nil {
+			return err
+		}
+
+		return ResolveNetwork(context.Background(), networkManager, "prod-host", "prod-sp", &request.Firewall)
+	}
+}
+
+func cloneFirewallRules(manager models.FirewallRuleManager, networkManager models.NetworkManager, request models.CloneRequest) (*models.BatchResult, error) {
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	prepare := newClonePrepare(manager, networkManager, priorityBandStore)
+	return CloneFirewallRules(context.Background(), manager, models.NewAddressGroupMemoryStore(), models.NewDependencyMemoryStore(), priorityBandStore, nil, nil, prepare, "dev-host", "dev-sp", "web", request)
+}
+
+func TestCloneFirewallRules(t *testing.T) {
+	manager := newCloneDummyClient()
+	request := models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictSkip}
+
+	batchResult, err := cloneFirewallRules(manager, newCloneNetworkDummyClient(), request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := map[string]models.BatchRuleResult{
+		"https": models.BatchRuleResult{Action: "create", Code: http.StatusCreated},
+		"ssh":   models.BatchRuleResult{Action: "skip", Code: http.StatusOK},
+	}
+	for _, result := range batchResult.Results {
+		if result.Action != expected[result.CustomName].Action || result.Code != expected[result.CustomName].Code {
+			t.Errorf("Unexpected result for %s. Got %+v", result.CustomName, result)
+		}
+	}
+
+	rule, err := manager.GetFirewallRule("prod-host", "prod-sp-web-https")
+	if err != nil {
+		t.Fatalf("Rule has not been cloned. Got %v", err)
+	}
+
+	if rule.TargetTags[0] != "prod-sp-web-https" {
+		t.Errorf("Target tag has not been rewritten. Got %s", rule.TargetTags[0])
+	}
+
+	if rule.Id != 0 {
+		t.Errorf("Output only fields should not be cloned. Got ID %d", rule.Id)
+	}
+
+	expectedNetwork := "https://www.googleapis.com/compute/v1/projects/prod-host/global/networks/dev-network"
+	if rule.Network != expectedNetwork {
+		t.Errorf("Network has not been rewritten. Got %s want %s", rule.Network, expectedNetwork)
+	}
+}
+
+func TestCloneFirewallRulesConflicts(t *testing.T) {
+	tests := []struct {
+		Title    string
+		Request  models.CloneRequest
+		Expected int
+		Rules    int
+	}{
+		{Title: "Fail by default", Request: models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp"}, Expected: http.StatusConflict, Rules: 1},
+		{Title: "Unknown option", Request: models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: "foo"}, Expected: http.StatusBadRequest, Rules: 1},
+		{Title: "Same destination", Request: models.CloneRequest{Project: "dev-host", ServiceProject: "dev-sp"}, Expected: http.StatusBadRequest, Rules: 1},
+		{Title: "Dry run", Request: models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictOverwrite, DryRun: true}, Expected: 0, Rules: 1},
+		{Title: "Overwrite", Request: models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictOverwrite}, Expected: 0, Rules: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			manager := newCloneDummyClient()
+			batchResult, err := cloneFirewallRules(manager, newCloneNetworkDummyClient(), test.Request)
+			assertErrorCode(t, err, test.Expected)
+
+			if len(manager.Rules["prod-host"]) != test.Rules {
+				t.Errorf("Wrong destination rules count. Got %d want %d", len(manager.Rules["prod-host"]), test.Rules)
+			}
+
+			if err != nil {
+				return
+			}
+
+			for _, result := range batchResult.Results {
+				if result.Rule == nil || result.Rule.Name != "prod-sp-web-"+result.CustomName {
+					t.Errorf("Unexpected result for %s. Got %+v", result.CustomName, result)
+				}
+			}
+		})
+	}
+}
+
+func TestCloneFirewallRulesNotSharedNetwork(t *testing.T) {
+	manager := newCloneDummyClient()
+	request := models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictSkip}
+
+	batchResult, err := cloneFirewallRules(manager, &fakes.NetworkDummyClient{}, request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	for _, result := range batchResult.Results {
+		if result.Action == "create" && result.Code != http.StatusBadRequest {
+			t.Errorf("Expected a bad request result for %s. Got %+v", result.CustomName, result)
+		}
+	}
+
+	if len(manager.Rules["prod-host"]) != 1 {
+		t.Errorf("No rule should have been cloned. Got %d rules", len(manager.Rules["prod-host"]))
+	}
+}
+
+func TestCloneFirewallRulesPrepared(t *testing.T) {
+	manager := newCloneDummyClient()
+	manager.Rules["dev-host"] = append(manager.Rules["dev-host"], &compute.Firewall{Name: "dev-sp-db-postgres", TargetTags: []string{"dev-sp-db-postgres"}})
+	manager.Rules["dev-host"][0].Priority = 1010
+	manager.Rules["dev-host"][0].SourceTags = []string{"dev-sp-db-postgres", "bastion"}
+	manager.Rules["prod-host"] = append(manager.Rules["prod-host"], &compute.Firewall{Name: "prod-sp-db-postgres", TargetTags: []string{"prod-sp-db-postgres"}})
+
+	dependencyStore := models.NewDependencyMemoryStore()
+	err := dependencyStore.SaveDependency("dev-host", models.ApplicationDependency{
+		ServiceProject:     "dev-sp",
+		Application:        "web",
+		CustomName:         "https",
+		SourceApplications: []models.SourceApplication{models.SourceApplication{ServiceProject: "dev-sp", Application: "db"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Web rules are at the top of the dev band, and in the prod band once cloned
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	priorityBandStore.SavePriorityBand("dev-host", models.PriorityBand{Name: "dev", Base: 1000, Default: true})
+	priorityBandStore.SavePriorityBand("prod-host", models.PriorityBand{Name: "prod", Base: 2000, Default: true})
+
+	prepare := newClonePrepare(manager, newCloneNetworkDummyClient(), priorityBandStore)
+	request := models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictSkip}
+	_, err = CloneFirewallRules(context.Background(), manager, models.NewAddressGroupMemoryStore(), dependencyStore, priorityBandStore, nil, nil, prepare, "dev-host", "dev-sp", "web", request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	rule, err := manager.GetFirewallRule("prod-host", "prod-sp-web-https")
+	if err != nil {
+		t.Fatalf("Rule has not been cloned. Got %v", err)
+	}
+
+	if rule.Priority != 2010 {
+		t.Errorf("Priority has not been moved to the destination band. Got %d", rule.Priority)
+	}
+
+	if len(rule.SourceTags) != 2 || rule.SourceTags[0] != "bastion" || rule.SourceTags[1] != "prod-sp-db-postgres" {
+		t.Errorf("Source application tags have not been rewritten. Got %v", rule.SourceTags)
+	}
+}
+
+func TestCloneFirewallRulesAddressGroups(t *testing.T) {
+	manager := newCloneDummyClient()
+	manager.Rules["dev-host"][0].SourceRanges = []string{"192.168.0.1/32", "10.0.0.0/16"}
+	manager.Rules["dev-host"][1].SourceRanges = []string{"10.8.0.0/16"}
+
+	store := models.NewAddressGroupMemoryStore()
+	store.SaveAddressGroup("dev-host", models.AddressGroup{Name: "office", Ranges: []string{"10.0.0.0/16"}})
+	store.SaveAddressGroup("dev-host", models.AddressGroup{Name: "vpn", Ranges: []string{"10.8.0.0/16"}})
+	store.SaveAddressGroup("prod-host", models.AddressGroup{Name: "office", Ranges: []string{"10.1.0.0/16"}})
+	TrackAddressGroups(store, "dev-host", "dev-sp", "web", "https", &models.AddressGroupReference{SourceAddressGroups: []string{"office"}, SourceRanges: []string{"192.168.0.1/32"}})
+	TrackAddressGroups(store, "dev-host", "dev-sp", "web", "ssh", &models.AddressGroupReference{SourceAddressGroups: []string{"vpn"}})
+
+	// Address groups are expanded in the destination, as on creation
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	clonePrepare := newClonePrepare(manager, newCloneNetworkDummyClient(), priorityBandStore)
+	references := make(map[string]*models.AddressGroupReference)
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		reference, err := ExpandAddressGroups(store, "prod-host", request)
+		if err != nil {
+			return err
+		}
+		references[customName] = reference
+		return clonePrepare(customName, request)
+	}
+
+	request := models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictOverwrite}
+	batchResult, err := CloneFirewallRules(context.Background(), manager, store, models.NewDependencyMemoryStore(), priorityBandStore, nil, nil, prepare, "dev-host", "dev-sp", "web", request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	// Groups missing in the destination fail their rule only
+	expected := map[string]int{"https": http.StatusCreated, "ssh": http.StatusBadRequest}
+	for _, result := range batchResult.Results {
+		if result.Code != expected[result.CustomName] {
+			t.Errorf("Wrong code for %s. Got %d want %d", result.CustomName, result.Code, expected[result.CustomName])
+		}
+	}
+
+	rule, err := manager.GetFirewallRule("prod-host", "prod-sp-web-https")
+	if err != nil {
+		t.Fatalf("Rule has not been cloned. Got %v", err)
+	}
+
+	if len(rule.SourceRanges) != 2 || rule.SourceRanges[0] != "192.168.0.1/32" || rule.SourceRanges[1] != "10.1.0.0/16" {
+		t.Errorf("Address groups have not been expanded in the destination. Got %v", rule.SourceRanges)
+	}
+
+	reference := references["https"]
+	if reference == nil || len(reference.SourceAddressGroups) != 1 || reference.SourceAddressGroups[0] != "office" || len(reference.SourceRanges) != 1 {
+		t.Errorf("Address groups should be referenced by the cloned rule. Got %+v", reference)
+	}
+}
+
+func TestCloneFirewallRulesOutOfBand(t *testing.T) {
+	manager := newCloneDummyClient()
+	manager.Rules["dev-host"][0].Priority = 1010
+	manager.Rules["dev-host"][1].Priority = 500
+
+	// The ssh rule predates the dev band, and has no priority relative to it
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	priorityBandStore.SavePriorityBand("dev-host", models.PriorityBand{Name: "dev", Base: 1000, Default: true})
+	priorityBandStore.SavePriorityBand("prod-host", models.PriorityBand{Name: "prod", Base: 2000, Default: true})
+
+	prepare := newClonePrepare(manager, newCloneNetworkDummyClient(), priorityBandStore)
+	request := models.CloneRequest{Project: "prod-host", ServiceProject: "prod-sp", Conflict: models.CloneConflictOverwrite}
+	batchResult, err := CloneFirewallRules(context.Background(), manager, models.NewAddressGroupMemoryStore(), models.NewDependencyMemoryStore(), priorityBandStore, nil, nil, prepare, "dev-host", "dev-sp", "web", request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := map[string]int{"https": http.StatusCreated, "ssh": http.StatusConflict}
+	for _, result := range batchResult.Results {
+		if result.Code != expected[result.CustomName] {
+			t.Errorf("Wrong code for %s. Got %d want %d", result.CustomName, result.Code, expected[result.CustomName])
+		}
+	}
+
+	rule, _ := manager.GetFirewallRule("prod-host", "prod-sp-web-ssh")
+	if rule.Priority != 0 {
+		t.Errorf("Rule out of band should not be copied. Got priority %d", rule.Priority)
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Priority of the implied rules, the lowest one
+const impliedRulePriority = 65535
+
+// Rule directions
+const (
+	directionIngress = "INGRESS"
+	directionEgress  = "EGRESS"
+)
+
+// CheckConnectivity evaluates whether given traffic to or from the application instances is allowed
+// by the rules of the host project network. Instances are identified by the target tags of the application rules
+func CheckConnectivity(ctx context.Context, manager models.FirewallRuleManager, networkManager models.NetworkManager, project, serviceProject, application string, query models.ConnectivityQuery) (*models.ConnectivityResult, error) {
+	err := validateConnectivityQuery(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use network resolution of rules
+	target := compute.Firewall{Network: query.Network}
+	err = ResolveNetwork(ctx, networkManager, project, serviceProject, &target)
+	if err != nil {
+		return nil, err
+	}
+	query.Network = target.Network
+
+	applicationRule, err := ListFirewallRule(ctx, manager, project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range applicationRule.Rules {
+		query.TargetTags = append(query.TargetTags, rule.Rule.TargetTags...)
+	}
+
+	rules, err := manager.ListFirewallRule(project)
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"network":         query.Network,
+	}).Debugf("Evaluating connectivity against %d rules", len(rules))
+
+	result := EvaluateConnectivity(rules, query)
+	result.Project = project
+	result.ServiceProject = serviceProject
+	result.Application = application
+	return result, nil
+}
+
+// EvaluateConnectivity applies given rules to given traffic the way Google does:
+// matching rules of the network and direction are ordered by priority, deny first on ties,
+// and implied rules deny ingress and allow egress traffic when no rule matches
+func EvaluateConnectivity(rules []*compute.Firewall, query models.ConnectivityQuery) *models.ConnectivityResult {
+	matching := make([]*compute.Firewall, 0)
+	for _, rule := range rules {
+		if ruleMatches(rule, query) {
+			matching = append(matching, rule)
+		}
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		if matching[i].Priority != matching[j].Priority {
+			return matching[i].Priority < matching[j].Priority
+		}
+		return len(matching[i].Denied) > 0 && len(matching[j].Denied) == 0
+	})
+
+	result := &models.ConnectivityResult{Query: query}
+	if len(matching) > 0 {
+		result.Rule = matching[0]
+		result.RuleName = matching[0].Name
+		result.Priority = matching[0].Priority
+		result.Verdict = models.ConnectivityAllowed
+		if len(matching[0].Denied) > 0 {
+			result.Verdict = models.ConnectivityDenied
+		}
+		return result
+	}
+
+	result.Priority = impliedRulePriority
+	if query.Direction == directionEgress {
+		result.RuleName = models.ImpliedAllowEgressRule
+		result.Verdict = models.ConnectivityAllowed
+		return result
+	}
+
+	result.RuleName = models.ImpliedDenyIngressRule
+	result.Verdict = models.ConnectivityDenied
+	return result
+}
+
+// validateConnectivityQuery ensures given query describes a single packet, and sets its default direction
+func validateConnectivityQuery(query *models.ConnectivityQuery) error {
+	query.Direction = strings.ToUpper(query.Direction)
+	if query.Direction == "" {
+		query.Direction = directionIngress
+	}
+
+	var peer string
+	switch query.Direction {
+	case directionIngress:
+		peer = query.Source
+	case directionEgress:
+		peer = query.Destination
+	default:
+		return models.NewBadRequestError(fmt.Sprintf("Invalid direction [%s]. Expected %s or %s", query.Direction, directionIngress, directionEgress))
+	}
+
+	if net.ParseIP(peer) == nil {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid %s address [%s]", strings.ToLower(query.Direction), peer))
+	}
+
+	number, ok := normalizeProtocol(query.Protocol)
+	if !ok || number == "all" {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid protocol [%s]", query.Protocol))
+	}
+
+	if query.Port != 0 && !portProtocols[number] {
+		return models.NewBadRequestError(fmt.Sprintf("Ports are only supported for tcp, udp and sctp protocols. Got [%s]", query.Protocol))
+	}
+
+	if query.Port < 0 || query.Port > 65535 {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid port [%d]", query.Port))
+	}
+
+	return nil
+}
+
+// ruleMatches returns whether given rule applies to given traffic
+func ruleMatches(rule *compute.Firewall, query models.ConnectivityQuery) bool {
+	if rule.Disabled || !sameNetwork(rule.Network, query.Network) {
+		return false
+	}
+
+	direction := rule.Direction
+	if direction == "" {
+		direction = directionIngress
+	}
+	if direction != query.Direction {
+		return false
+	}
+
+	// Rules without target apply to all instances
+	if len(rule.TargetTags) > 0 && !intersects(rule.TargetTags, query.TargetTags) {
+		return false
+	}
+	if len(rule.TargetServiceAccounts) > 0 && !containsString(rule.TargetServiceAccounts, query.ServiceAccount) {
+		return false
+	}
+
+	if direction == directionIngress && !sourceMatches(rule, query) {
+		return false
+	}
+	if direction == directionEgress && !rangesContain(rule.DestinationRanges, query.Destination, true) {
+		return false
+	}
+
+	for _, a := range rule.Allowed {
+		if protocolMatches(a.IPProtocol, a.Ports, query) {
+			return true
+		}
+	}
+	for _, d := range rule.Denied {
+		if protocolMatches(d.IPProtocol, d.Ports, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceMatches returns whether the source of given ingress traffic matches any source of given rule.
+// Rules without source apply to all sources
+func sourceMatches(rule *compute.Firewall, query models.ConnectivityQuery) bool {
+	if len(rule.SourceRanges) == 0 && len(rule.SourceTags) == 0 && len(rule.SourceServiceAccounts) == 0 {
+		return true
+	}
+
+	return rangesContain(rule.SourceRanges, query.Source, false) ||
+		intersects(rule.SourceTags, query.SourceTags) ||
+		(query.SourceServiceAccount != "" && containsString(rule.SourceServiceAccounts, query.SourceServiceAccount))
+}
+
+// protocolMatches returns whether given protocol and ports cover the traffic of given query
+func protocolMatches(protocol string, ports []string, query models.ConnectivityQuery) bool {
+	number, ok := normalizeProtocol(protocol)
+	if !ok {
+		return false
+	}
+
+	queried, _ := normalizeProtocol(query.Protocol)
+	if number != "all" && number != queried {
+		return false
+	}
+
+	if len(ports) == 0 {
+		return true
+	}
+
+	for _, p := range ports {
+		from, to, err := parsePortRange(p)
+		if err == nil && query.Port >= from && query.Port <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// rangesContain returns whether given address is in any of given ranges. Empty ranges contain all addresses when whenEmpty
+func rangesContain(ranges []string, address string, whenEmpty bool) bool {
+	if len(ranges) == 0 {
+		return whenEmpty
+	}
+
+	ip := net.ParseIP(address)
+	for _, r := range ranges {
+		if _, ipNet, err := net.ParseCIDR(r); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+		if rangeIP := net.ParseIP(r); rangeIP != nil && rangeIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameNetwork returns whether given network references point to the same network
+func sameNetwork(a, b string) bool {
+	projectA, nameA := parseNetwork(a)
+	projectB, nameB := parseNetwork(b)
+	return nameA == nameB && (projectA == "" || projectB == "" || projectA == projectB)
+}
+
+func intersects(a, b []string) bool {
+	for _, v := range a {
+		if containsString(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
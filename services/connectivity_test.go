@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+const testNetwork = "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"
+
+func TestEvaluateConnectivity(t *testing.T) {
+	rules := []*compute.Firewall{
+		&compute.Firewall{Name: "web-https", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceRanges: []string{"10.0.0.0/8"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"443", "8000-8080"}}}},
+		&compute.Firewall{Name: "deny-bad-host", Network: testNetwork, Priority: 900, SourceRanges: []string{"10.6.6.6"}, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}},
+		&compute.Firewall{Name: "tie-allow", Network: testNetwork, Priority: 500, SourceRanges: []string{"10.9.0.0/16"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp"}}},
+		&compute.Firewall{Name: "tie-deny", Network: testNetwork, Priority: 500, SourceRanges: []string{"10.9.0.0/16"}, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "6", Ports: []string{"443"}}}},
+		&compute.Firewall{Name: "from-api", Network: testNetwork, Priority: 1000, TargetTags: []string{"web"}, SourceTags: []string{"api"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"9000"}}}},
+		&compute.Firewall{Name: "from-sa", Network: testNetwork, Priority: 1000, TargetServiceAccounts: []string{"web@sp.iam.gserviceaccount.com"}, SourceServiceAccounts: []string{"batch@sp.iam.gserviceaccount.com"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "udp"}}},
+		&compute.Firewall{Name: "icmp", Network: testNetwork, Priority: 1000, SourceRanges: []string{"0.0.0.0/0"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "icmp"}}},
+		&compute.Firewall{Name: "disabled", Network: testNetwork, Priority: 1, Disabled: true, SourceRanges: []string{"0.0.0.0/0"}, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}},
+		&compute.Firewall{Name: "other-network", Network: "https://www.googleapis.com/compute/v1/projects/host/global/networks/other", Priority: 1, SourceRanges: []string{"0.0.0.0/0"}, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}},
+		&compute.Firewall{Name: "deny-egress-internet", Network: testNetwork, Direction: "EGRESS", Priority: 1000, TargetTags: []string{"web"}, DestinationRanges: []string{"0.0.0.0/0"}, Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "all"}}},
+		&compute.Firewall{Name: "allow-egress-internal", Network: testNetwork, Direction: "EGRESS", Priority: 900, DestinationRanges: []string{"10.0.0.0/8"}, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "all"}}},
+	}
+
+	tests := []struct {
+		Title   string
+		Query   models.ConnectivityQuery
+		Verdict string
+		Rule    string
+	}{
+		{Title: "Allowed by range and port", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.1.2.3", TargetTags: []string{"web"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityAllowed, Rule: "web-https"},
+		{Title: "Allowed by port range", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.1.2.3", TargetTags: []string{"web"}, Protocol: "TCP", Port: 8080}, Verdict: models.ConnectivityAllowed, Rule: "web-https"},
+		{Title: "Other port", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.1.2.3", TargetTags: []string{"web"}, Protocol: "tcp", Port: 22}, Verdict: models.ConnectivityDenied, Rule: models.ImpliedDenyIngressRule},
+		{Title: "Other target", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.1.2.3", TargetTags: []string{"db"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityDenied, Rule: models.ImpliedDenyIngressRule},
+		{Title: "Higher priority deny", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.6.6.6", TargetTags: []string{"web"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityDenied, Rule: "deny-bad-host"},
+		{Title: "Deny wins on same priority", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.9.1.1", Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityDenied, Rule: "tie-deny"},
+		{Title: "Allow on same priority for other port", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "10.9.1.1", Protocol: "tcp", Port: 80}, Verdict: models.ConnectivityAllowed, Rule: "tie-allow"},
+		{Title: "Source tags", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "172.16.0.1", SourceTags: []string{"api"}, TargetTags: []string{"web"}, Protocol: "tcp", Port: 9000}, Verdict: models.ConnectivityAllowed, Rule: "from-api"},
+		{Title: "Service accounts", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "172.16.0.1", SourceServiceAccount: "batch@sp.iam.gserviceaccount.com", ServiceAccount: "web@sp.iam.gserviceaccount.com", Protocol: "udp", Port: 53}, Verdict: models.ConnectivityAllowed, Rule: "from-sa"},
+		{Title: "Other target service account", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "172.16.0.1", SourceServiceAccount: "batch@sp.iam.gserviceaccount.com", ServiceAccount: "db@sp.iam.gserviceaccount.com", Protocol: "udp", Port: 53}, Verdict: models.ConnectivityDenied, Rule: models.ImpliedDenyIngressRule},
+		{Title: "Protocol without port", Query: models.ConnectivityQuery{Direction: "INGRESS", Source: "8.8.8.8", Protocol: "icmp"}, Verdict: models.ConnectivityAllowed, Rule: "icmp"},
+		{Title: "Egress denied", Query: models.ConnectivityQuery{Direction: "EGRESS", Destination: "8.8.8.8", TargetTags: []string{"web"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityDenied, Rule: "deny-egress-internet"},
+		{Title: "Egress allowed", Query: models.ConnectivityQuery{Direction: "EGRESS", Destination: "10.1.2.3", TargetTags: []string{"web"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityAllowed, Rule: "allow-egress-internal"},
+		{Title: "Implied egress", Query: models.ConnectivityQuery{Direction: "EGRESS", Destination: "8.8.8.8", TargetTags: []string{"db"}, Protocol: "tcp", Port: 443}, Verdict: models.ConnectivityAllowed, Rule: models.ImpliedAllowEgressRule},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			test.Query.Network = testNetwork
+			result := EvaluateConnectivity(rules, test.Query)
+			if result.Verdict != test.Verdict || result.RuleName != test.Rule {
+				t.Errorf("Got %s by %s want %s by %s", result.Verdict, result.RuleName, test.Verdict, test.Rule)
+			}
+		})
+	}
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	networkManager := newNetworkDummyClient()
+	CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "https", compute.Firewall{
+		Network:      testNetwork,
+		Priority:     1000,
+		SourceRanges: []string{"10.0.0.0/8"},
+		Allowed:      []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"443"}}},
+	})
+
+	result, err := CheckConnectivity(context.Background(), manager, networkManager, "host", "sp", "web", models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "tcp", Port: 443})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if result.Verdict != models.ConnectivityAllowed || result.RuleName != "sp-web-https" || result.Rule == nil || result.Query.Direction != "INGRESS" {
+		t.Errorf("Unexpected result. Got %+v", result)
+	}
+
+	result, err = CheckConnectivity(context.Background(), manager, networkManager, "host", "sp", "api", models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "tcp", Port: 443})
+	if err != nil || result.Verdict != models.ConnectivityDenied || result.Rule != nil || result.Priority != 65535 {
+		t.Errorf("Unexpected result. Got %+v, %v", result, err)
+	}
+
+	tests := []struct {
+		Title          string
+		ServiceProject string
+		Query          models.ConnectivityQuery
+	}{
+		{Title: "Invalid direction", ServiceProject: "sp", Query: models.ConnectivityQuery{Direction: "inbound", Source: "10.1.2.3", Protocol: "tcp", Port: 443}},
+		{Title: "Missing source", ServiceProject: "sp", Query: models.ConnectivityQuery{Protocol: "tcp", Port: 443}},
+		{Title: "Invalid destination", ServiceProject: "sp", Query: models.ConnectivityQuery{Direction: "egress", Destination: "10.1.2", Protocol: "tcp", Port: 443}},
+		{Title: "All protocols", ServiceProject: "sp", Query: models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "all"}},
+		{Title: "Port on icmp", ServiceProject: "sp", Query: models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "icmp", Port: 8}},
+		{Title: "Invalid port", ServiceProject: "sp", Query: models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "tcp", Port: 70000}},
+		{Title: "Ambiguous network", ServiceProject: "sp-multi", Query: models.ConnectivityQuery{Source: "10.1.2.3", Protocol: "tcp", Port: 443}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			_, err := CheckConnectivity(context.Background(), manager, networkManager, "host", test.ServiceProject, "web", test.Query)
+			assertErrorCode(t, err, http.StatusBadRequest)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ETag returns a strong entity tag describing the current state of the given application rules, as stored by the backend.
+// Compute firewall rules do not expose a fingerprint, so the tag is derived from each rule's
+// identity (ID and creation timestamp) and content. It is computed before priorities are made relative to the application band,
+// so that moving bands does not change it
+func ETag(applicationRule *models.ApplicationRule) string {
+	h := sha256.New()
+	for _, rule := range applicationRule.Rules {
+		fmt.Fprintf(h, "%d/%s/", rule.Rule.Id, rule.Rule.CreationTimestamp)
+		content, _ := json.Marshal(&rule.Rule)
+		h.Write(content)
+	}
+
+	return fmt.Sprintf(`"%x"`, h.Sum(nil))
+}
+
+// CheckIfMatch ensures the matching firewall rule current state satisfies the given If-Match header value.
+// An empty value disables the check. Compute firewall rules cannot be written conditionally, so the rule
+// may still be modified between this check and the caller's write
+func CheckIfMatch(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application, ruleName, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	applicationRule, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		// No current representation matches, not even "*" (RFC 7232 section 3.1)
+		if e, ok := err.(*models.ApplicationError); ok && e.Code == http.StatusNotFound {
+			return models.NewPreconditionFailedError(fmt.Sprintf("Rule [%s] does not exist", ruleName))
+		}
+		return err
+	}
+
+	current := ETag(applicationRule)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		// Weak tags never match with the strong comparison required by If-Match
+		if candidate == "*" || candidate == current {
+			return nil
+		}
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"if_match":        ifMatch,
+		"etag":            current,
+	}).Debugln("Precondition failed")
+	return models.NewPreconditionFailedError(fmt.Sprintf("Rule [%s] has been modified. Current ETag is %s", ruleName, current))
+}
+
+// CheckIfNoneMatch ensures the matching firewall rule does not exist when given If-None-Match header value is "*".
+// An empty value disables the check
+func CheckIfNoneMatch(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application, ruleName, ifNoneMatch string) error {
+	if ifNoneMatch == "" {
+		return nil
+	}
+
+	if strings.TrimSpace(ifNoneMatch) != "*" {
+		return models.NewBadRequestError("Only 'If-None-Match: *' is supported")
+	}
+
+	_, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err == nil {
+		return models.NewPreconditionFailedError(fmt.Sprintf("Rule [%s] already exists", ruleName))
+	}
+
+	// Rule not found, precondition is met
+	if e, ok := err.(*models.ApplicationError); ok && e.Code == http.StatusNotFound {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestETag(t *testing.T) {
+	rule := compute.Firewall{Id: 1, Name: "sp-app-web", CreationTimestamp: "2020-04-01T00:00:00.000-07:00", Network: "global/networks/default"}
+	applicationRule := &models.ApplicationRule{Rules: models.FirewallRules{models.FirewallRule{Rule: rule, CustomName: "web"}}}
+
+	first := ETag(applicationRule)
+	if first != ETag(applicationRule) {
+		t.Errorf("ETag should be stable for the same content")
+	}
+
+	if first[0] != '"' || first[len(first)-1] != '"' {
+		t.Errorf("ETag should be a quoted string. Got %s", first)
+	}
+
+	// Changing content should change the tag
+	applicationRule.Rules[0].Rule.SourceRanges = []string{"10.0.0.0/8"}
+	if first == ETag(applicationRule) {
+		t.Errorf("ETag should change when rule content changes")
+	}
+
+	// Re-creating a rule with same content should change the tag
+	applicationRule.Rules[0].Rule.SourceRanges = nil
+	applicationRule.Rules[0].Rule.Id = 2
+	if first == ETag(applicationRule) {
+		t.Errorf("ETag should change when rule is re-created")
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	project := "dummy-project"
+	serviceProject := "dummy-service_project"
+	application := "dummy-application"
+	customName := "allow-tcp-443"
+	rule := compute.Firewall{Network: "global/networks/default", Priority: 1010, Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{Ports: []string{"443"}, IPProtocol: "TCP"}}}
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	priorityBandStore.SavePriorityBand(project, models.PriorityBand{Name: "apps", Base: 1000, Default: true})
+
+	applicationRule, err := CreateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, customName, rule)
+	if err != nil {
+		t.Fatalf("Something wrong during rule creation. Got error %v\n", err)
+	}
+	etag := ETag(applicationRule)
+
+	// Tags are computed on the stored rule, not on priorities relative to the application band sent to clients
+	RelativePriorities(priorityBandStore, applicationRule)
+	relativeETag := ETag(applicationRule)
+
+	tests := []struct {
+		Title    string
+		IfMatch  string
+		Expected int
+	}{
+		{Title: "No header", IfMatch: "", Expected: 0},
+		{Title: "Matching ETag", IfMatch: etag, Expected: 0},
+		{Title: "Matching ETag in a list", IfMatch: `"foo", ` + etag, Expected: 0},
+		{Title: "Wildcard", IfMatch: "*", Expected: 0},
+		{Title: "Stale ETag", IfMatch: `"foo"`, Expected: http.StatusPreconditionFailed},
+		{Title: "Weak ETag", IfMatch: "W/" + etag, Expected: http.StatusPreconditionFailed},
+		{Title: "ETag of relative priorities", IfMatch: relativeETag, Expected: http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			err := CheckIfMatch(context.Background(), manager, project, serviceProject, application, customName, test.IfMatch)
+			assertErrorCode(t, err, test.Expected)
+		})
+	}
+
+	// Missing rule matches no tag, even a wildcard
+	err = CheckIfMatch(context.Background(), manager, project, serviceProject, application, "missing", etag)
+	assertErrorCode(t, err, http.StatusPreconditionFailed)
+	err = CheckIfMatch(context.Background(), manager, project, serviceProject, application, "missing", "*")
+	assertErrorCode(t, err, http.StatusPreconditionFailed)
+}
+
+func TestCheckIfNoneMatch(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	project := "dummy-project"
+	serviceProject := "dummy-service_project"
+	application := "dummy-application"
+	customName := "allow-tcp-443"
+
+	// Rule does not exist yet
+	err := CheckIfNoneMatch(context.Background(), manager, project, serviceProject, application, customName, "*")
+	assertErrorCode(t, err, 0)
+
+	_, err = CreateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, customName, compute.Firewall{Network: "global/networks/default"})
+	if err != nil {
+		t.Fatalf("Something wrong during rule creation. Got error %v\n", err)
+	}
+
+	err = CheckIfNoneMatch(context.Background(), manager, project, serviceProject, application, customName, "*")
+	assertErrorCode(t, err, http.StatusPreconditionFailed)
+
+	err = CheckIfNoneMatch(context.Background(), manager, project, serviceProject, application, customName, "")
+	assertErrorCode(t, err, 0)
+
+	err = CheckIfNoneMatch(context.Background(), manager, project, serviceProject, application, customName, `"foo"`)
+	assertErrorCode(t, err, http.StatusBadRequest)
+}
+
+// assertErrorCode ensures given error is nil when expected code is 0, or an ApplicationError with expected code
+func assertErrorCode(t *testing.T, err error, expected int) {
+	t.Helper()
+
+	if expected == 0 {
+		if err != nil {
+			t.Errorf("Unexpected error. Got %v", err)
+		}
+		return
+	}
+
+	e, ok := err.(*models.ApplicationError)
+	if !ok {
+		t.Errorf("Expected an ApplicationError with code %d. Got %v", expected, err)
+		return
+	}
+
+	if e.Code != expected {
+		t.Errorf("Unexpected error code. Got %d want %d", e.Code, expected)
+	}
+}
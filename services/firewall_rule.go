@@ -1,17 +1,44 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"strings"
 
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
 	"github.com/adeo/iwc-gcp-firewall-api/models"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/compute/v1"
 )
 
+// Marker prepended to the description of rules, naming their application. Names alone cannot tell the rules of application
+// web-admin from the ones of application web named admin-*, both starting with <service project>-web-
+const applicationMarkerFormat = "[application %s] "
+
+var applicationMarkerRegexp = regexp.MustCompile(`^\[application ([^\]]+)\] `)
+
+// withApplicationMarker returns given description marked with given application, replacing any previous marker
+func withApplicationMarker(description, application string) string {
+	return fmt.Sprintf(applicationMarkerFormat, application) + applicationMarkerRegexp.ReplaceAllString(description, "")
+}
+
+// ownedRule returns the custom name of given rule in given application of given service project, and whether the rule belongs to it.
+// Rules without application marker, created before it, belong to the application their name starts with
+func ownedRule(rule *compute.Firewall, serviceProject, application string) (string, bool) {
+	prefix := fmt.Sprintf("%s-%s-", serviceProject, application)
+	if !strings.HasPrefix(rule.Name, prefix) {
+		return "", false
+	}
+	if match := applicationMarkerRegexp.FindStringSubmatch(rule.Description); match != nil && match[1] != application {
+		return "", false
+	}
+	return rule.Name[len(prefix):], true
+}
+
 // ListFirewallRule returns a set of firewall rules related to an application
-func ListFirewallRule(manager models.FirewallRuleManager, project, serviceProject, application string) (*models.ApplicationRule, error) {
-	logrus.WithFields(logrus.Fields{
+func ListFirewallRule(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application string) (*models.ApplicationRule, error) {
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
@@ -33,11 +60,9 @@ func ListFirewallRule(manager models.FirewallRuleManager, project, serviceProjec
 	endUserResultRules := make(models.FirewallRules, 0)
 
 	// For each obtains Google rules
-	prefix := fmt.Sprintf("%s-%s-", serviceProject, application)
 	for _, gRule := range gRules {
 		// Filter with managed rules with this application
-		if strings.HasPrefix(gRule.Name, prefix) {
-			customName := gRule.Name[len(prefix):]
+		if customName, ok := ownedRule(gRule, serviceProject, application); ok {
 			endUserResultRules = append(endUserResultRules, models.FirewallRule{
 				Rule:       *gRule,
 				CustomName: customName,
@@ -46,7 +71,7 @@ func ListFirewallRule(manager models.FirewallRuleManager, project, serviceProjec
 	}
 
 	endUserResult.Rules = endUserResultRules
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
@@ -54,13 +79,25 @@ func ListFirewallRule(manager models.FirewallRuleManager, project, serviceProjec
 	return &endUserResult, nil
 }
 
-// CreateFirewallRule create given firewall rule on given project
-func CreateFirewallRule(manager models.FirewallRuleManager, project string, serviceProject string, application string, ruleName string, rule compute.Firewall) (*models.ApplicationRule, error) {
+// CreateFirewallRule create given firewall rule on given project, within the host project quotas.
+// The rule targets its own tag, and the given additional ones
+func CreateFirewallRule(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project string, serviceProject string, application string, ruleName string, rule compute.Firewall, targetTags ...string) (*models.ApplicationRule, error) {
+	err := CheckQuota(ctx, manager, serviceProjects, policy, project, serviceProject, application, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return createFirewallRule(ctx, manager, project, serviceProject, application, ruleName, rule, targetTags...)
+}
+
+// createFirewallRule create given firewall rule on given project, quotas being already checked
+func createFirewallRule(ctx context.Context, manager models.FirewallRuleManager, project string, serviceProject string, application string, ruleName string, rule compute.Firewall, targetTags ...string) (*models.ApplicationRule, error) {
 	customNameAndTargetTag := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
 	rule.Name = customNameAndTargetTag
-	rule.TargetTags = []string{customNameAndTargetTag}
+	rule.Description = withApplicationMarker(rule.Description, application)
+	rule.TargetTags = append([]string{customNameAndTargetTag}, targetTags...)
 
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
@@ -87,11 +124,64 @@ func CreateFirewallRule(manager models.FirewallRuleManager, project string, serv
 	}, nil
 }
 
+// UpdateFirewallRule replace the matching firewall rule with the given one, within the host project quotas.
+// The additional target tags of the rule, such as the old tag of a pending rename, and its rename record are kept
+func UpdateFirewallRule(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project string, serviceProject string, application string, ruleName string, rule compute.Firewall) (*models.ApplicationRule, error) {
+	current, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	customNameAndTargetTag := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
+	rule.Name = customNameAndTargetTag
+	rule.Description = withApplicationMarker(rule.Description, application)
+	if marker := renameMarkerRegexp.FindString(current.Rules[0].Rule.Description); marker != "" && !renameMarkerRegexp.MatchString(rule.Description) {
+		rule.Description += marker
+	}
+	rule.TargetTags = []string{customNameAndTargetTag}
+	for _, tag := range current.Rules[0].Rule.TargetTags {
+		if tag != customNameAndTargetTag {
+			rule.TargetTags = append(rule.TargetTags, tag)
+		}
+	}
+
+	err = checkQuota(ctx, manager, serviceProjects, policy, project, serviceProject, application, customNameAndTargetTag, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule_name":       customNameAndTargetTag,
+		"target_tag":      customNameAndTargetTag,
+	}).Debugln("Updating rule")
+
+	gRule, err := manager.UpdateFirewallRule(project, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("%s-%s-", serviceProject, application)
+	updatedRule := models.FirewallRule{
+		Rule:       *gRule,
+		CustomName: gRule.Name[len(prefix):],
+	}
+
+	return &models.ApplicationRule{
+		Application:    application,
+		Project:        project,
+		ServiceProject: serviceProject,
+		Rules:          models.FirewallRules{updatedRule},
+	}, nil
+}
+
 // GetFirewallRule return matching firewall rule
-func GetFirewallRule(manager models.FirewallRuleManager, project string, serviceProject string, application string, ruleName string) (*models.ApplicationRule, error) {
+func GetFirewallRule(ctx context.Context, manager models.FirewallRuleManager, project string, serviceProject string, application string, ruleName string) (*models.ApplicationRule, error) {
 	n := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
 
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
@@ -102,8 +192,11 @@ func GetFirewallRule(manager models.FirewallRuleManager, project string, service
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := ownedRule(gRule, serviceProject, application); !ok {
+		return nil, models.NewNotFoundError(fmt.Sprintf("Rule [%s] not found in application [%s]", ruleName, application))
+	}
 
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
@@ -125,14 +218,19 @@ func GetFirewallRule(manager models.FirewallRuleManager, project string, service
 }
 
 // DeleteFirewallRule delete firewall rule mathing project, service project, application name and rule name
-func DeleteFirewallRule(manager models.FirewallRuleManager, project, serviceProject, application, customName string) error {
+func DeleteFirewallRule(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application, customName string) error {
 	ruleName := fmt.Sprintf("%s-%s-%s", serviceProject, application, customName)
-	logrus.WithFields(logrus.Fields{
+	helpers.Logger(ctx).WithFields(logrus.Fields{
 		"project":         project,
 		"service_project": serviceProject,
 		"application":     application,
 		"rule_name":       ruleName,
 	}).Debugln("Deleting rule")
 
+	// Rules of another application named alike are left untouched
+	_, err := GetFirewallRule(ctx, manager, project, serviceProject, application, customName)
+	if err != nil {
+		return err
+	}
 	return manager.DeleteFirewallRule(project, ruleName)
 }
@@ -1,72 +1,25 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"testing"
 
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
 	"github.com/adeo/iwc-gcp-firewall-api/models"
 	"github.com/sirupsen/logrus"
 	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
 )
 
 func init() {
 	logrus.SetOutput(ioutil.Discard)
 }
 
-// FirewallRuleDummyClient provides primitives to collect rules from in-memory rules list
-type FirewallRuleDummyClient struct {
-	Rules map[string][]*compute.Firewall
-}
-
-func NewFirewallRuleDummyClient() (*FirewallRuleDummyClient, error) {
-	manager := FirewallRuleDummyClient{}
-	manager.Rules = make(map[string][]*compute.Firewall)
-	return &manager, nil
-}
-
-func (f *FirewallRuleDummyClient) ListFirewallRule(project string) ([]*compute.Firewall, error) {
-	if value, ok := f.Rules[project]; ok {
-		return value, nil
-	}
-	return nil, fmt.Errorf("Project not found")
-}
-
-func (f *FirewallRuleDummyClient) GetFirewallRule(project, name string) (*compute.Firewall, error) {
-	for _, rule := range f.Rules[project] {
-		if rule.Name == name {
-			return rule, nil
-		}
-	}
-	return nil, fmt.Errorf("Rule not found")
-}
-
-func (f *FirewallRuleDummyClient) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
-	for _, r := range f.Rules[project] {
-		if r.Name == rule.Name {
-			return nil, fmt.Errorf("Rule already exists")
-		}
-	}
-
-	f.Rules[project] = append(f.Rules[project], rule)
-	return rule, nil
-}
-
-func (f *FirewallRuleDummyClient) DeleteFirewallRule(project, name string) error {
-	rules := f.Rules[project]
-	for i, rule := range rules {
-		if rule.Name == name {
-			// Delete matching route
-			rules[i] = rules[len(rules)-1]
-			f.Rules[project] = rules[:len(rules)-1]
-			return nil
-		}
-	}
-	return fmt.Errorf("Rule not found")
-}
-
 func TestCreateFirewallRule(t *testing.T) {
-	manager, _ := NewFirewallRuleDummyClient()
+	manager, _ := fakes.NewFirewallRuleDummyClient()
 	project := "dummy-project"
 	serviceProject := "dummy-service_project"
 	application := "dummy-application"
@@ -79,7 +32,7 @@ func TestCreateFirewallRule(t *testing.T) {
 
 	// Create dummy rule
 	for _, rule := range rules {
-		_, err := CreateFirewallRule(manager, project, serviceProject, application, rule.CustomName, rule.Rule)
+		_, err := CreateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, rule.CustomName, rule.Rule)
 		if err != nil {
 			t.Fatalf("Something wrong during rule creation. Got error %v\n", err)
 		}
@@ -93,16 +46,56 @@ func TestCreateFirewallRule(t *testing.T) {
 	}
 
 	// Inster existing rule should trigger error
-	_, err := CreateFirewallRule(manager, project, serviceProject, application, rule.CustomName, rule.Rule)
+	_, err := CreateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, rule.CustomName, rule.Rule)
 	if err == nil {
 		t.Errorf("Expected error during insert if rule already exists")
 	}
 
 }
 
+func TestUpdateFirewallRule(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	project := "dummy-project"
+	serviceProject := "dummy-service_project"
+	application := "dummy-application"
+	customName := "allow-tcp-22"
+	rule := compute.Firewall{Network: "global/networks/default", Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{Ports: []string{"22"}, IPProtocol: "TCP"}}}
+
+	// Update non-existing rule should trigger error
+	_, err := UpdateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, customName, rule)
+	if err == nil {
+		t.Fatalf("Expected error during update if rule does not exist")
+	}
+
+	_, err = CreateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, customName, rule)
+	if err != nil {
+		t.Fatalf("Something wrong during rule creation. Got error %v\n", err)
+	}
+
+	// Replace allowed ports
+	rule.Allowed = []*compute.FirewallAllowed{&compute.FirewallAllowed{Ports: []string{"2222"}, IPProtocol: "TCP"}}
+	applicationRule, err := UpdateFirewallRule(context.Background(), manager, nil, nil, project, serviceProject, application, customName, rule)
+	if err != nil {
+		t.Fatalf("Something wrong during rule update. Got error %v\n", err)
+	}
+
+	if applicationRule.Rules[0].CustomName != customName {
+		t.Errorf("Custom name don't match. Got %s, expected %s\n", applicationRule.Rules[0].CustomName, customName)
+	}
+
+	expected := fmt.Sprintf("%s-%s-%s", serviceProject, application, customName)
+	if manager.Rules[project][0].TargetTags[0] != expected {
+		t.Errorf("Target tag don't match format. Got %s, expected %s\n", manager.Rules[project][0].TargetTags[0], expected)
+	}
+
+	if manager.Rules[project][0].Allowed[0].Ports[0] != "2222" {
+		t.Errorf("Rule has not been updated. Got port %s, expected %s\n", manager.Rules[project][0].Allowed[0].Ports[0], "2222")
+	}
+}
+
 func TestListFirewallRule(t *testing.T) {
 	// Add dummy content
-	manager, _ := NewFirewallRuleDummyClient()
+	manager, _ := fakes.NewFirewallRuleDummyClient()
 
 	project := "kubernetes-host-project"
 	serviceProjects := []string{"kubernetes-demo", "kubernetes-training"}
@@ -118,7 +111,7 @@ func TestListFirewallRule(t *testing.T) {
 	}
 
 	// Test no rule are returned
-	applicationRule, err := ListFirewallRule(manager, project, serviceProjects[0], "non-existing-application")
+	applicationRule, err := ListFirewallRule(context.Background(), manager, project, serviceProjects[0], "non-existing-application")
 	if err != nil {
 		t.Fatalf("Expected error during ListApplicationFirewallRules on a non-existing-application")
 	}
@@ -128,13 +121,13 @@ func TestListFirewallRule(t *testing.T) {
 	}
 
 	// Ask for non-existing project
-	_, err = ListFirewallRule(manager, "non-existing-project", serviceProjects[0], applications[0])
+	_, err = ListFirewallRule(context.Background(), manager, "non-existing-project", serviceProjects[0], applications[0])
 	if err == nil {
 		t.Fatalf("Expected error during ListApplicationFirewallRules on a non-existing project")
 	}
 
 	// Ask for one application in a random project
-	applicationRule, err = ListFirewallRule(manager, project, serviceProjects[0], applications[0])
+	applicationRule, err = ListFirewallRule(context.Background(), manager, project, serviceProjects[0], applications[0])
 	if err != nil {
 		t.Fatalf("Something wrong during ListApplicationFirewallRules. Got error : %v\n", err)
 	}
@@ -147,7 +140,7 @@ func TestListFirewallRule(t *testing.T) {
 
 func TestDeleteApplicationFirewallRules(t *testing.T) {
 	// Add dummy content
-	manager, _ := NewFirewallRuleDummyClient()
+	manager, _ := fakes.NewFirewallRuleDummyClient()
 	project := "nginx-host-project"
 	serviceProject := "nginx-demo"
 	application := "front"
@@ -157,13 +150,13 @@ func TestDeleteApplicationFirewallRules(t *testing.T) {
 	manager.Rules[project] = append(manager.Rules[project], &gRule)
 
 	// Ask to delete a rule
-	err := DeleteFirewallRule(manager, project, serviceProject, application, ruleCustomName)
+	err := DeleteFirewallRule(context.Background(), manager, project, serviceProject, application, ruleCustomName)
 	if err != nil {
 		t.Fatalf("Unexpected error during Delete. Got %v\n", err)
 	}
 
 	// Verify empty rules
-	apprules, err := ListFirewallRule(manager, project, serviceProject, application)
+	apprules, err := ListFirewallRule(context.Background(), manager, project, serviceProject, application)
 	if err != nil {
 		t.Fatalf("Unexpected error during Delete. Got %v\n", err)
 	}
@@ -173,8 +166,86 @@ func TestDeleteApplicationFirewallRules(t *testing.T) {
 	}
 
 	// Try to delete on non-existing project
-	err = DeleteFirewallRule(manager, project, serviceProject, application, ruleCustomName)
+	err = DeleteFirewallRule(context.Background(), manager, project, serviceProject, application, ruleCustomName)
 	if err == nil {
 		t.Fatalf("Expected error during Delete on non existing project. Got %v\n", err)
 	}
 }
+
+func TestFirewallRuleOnFirewallPolicies(t *testing.T) {
+	secureTags := map[string]string{"sp-web-https": "tagValues/2"}
+	tests := []struct {
+		Title   string
+		Backend func(computeService *compute.Service) models.RuleBackend
+		Listed  int
+	}{
+		{Title: "Network firewall policy", Backend: func(computeService *compute.Service) models.RuleBackend {
+			return models.NewNetworkFirewallPolicyBackend(computeService, "lh-policy", secureTags)
+		}, Listed: 2},
+		{Title: "Hierarchical firewall policy", Backend: func(computeService *compute.Service) models.RuleBackend {
+			return models.NewHierarchicalFirewallPolicyBackend(computeService, "lh-policy", secureTags)
+		}, Listed: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			srv := fakes.NewFirewallPolicyServer("lh-policy", []*compute.FirewallPolicyRule{
+				&compute.FirewallPolicyRule{RuleName: "sp-web-ssh", Action: "allow", Priority: 1000, TargetResources: []string{"projects/other-host/global/networks/lh-network"}},
+			})
+			defer srv.Close()
+			computeService, err := compute.NewService(context.Background(), option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatal(err)
+			}
+			manager := models.NewRuleManager(test.Backend(computeService))
+
+			rule := compute.Firewall{Network: "global/networks/lh-network", Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"443"}}}}
+			_, err = CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "https", rule)
+			if err != nil {
+				t.Fatalf("Unexpected error. Got %v", err)
+			}
+			created := srv.Rules()[1]
+			if created.TargetSecureTags[0].Name != "tagValues/2" {
+				t.Errorf("Expected target tag to be translated. Got %v", created.TargetSecureTags[0])
+			}
+
+			// Rules of other host projects sharing a hierarchical policy are not listed, unlike the ones of a network policy
+			applicationRule, err := ListFirewallRule(context.Background(), manager, "host", "sp", "web")
+			if err != nil {
+				t.Fatalf("Unexpected error. Got %v", err)
+			}
+			listed := make(map[string][]string)
+			for _, rule := range applicationRule.Rules {
+				listed[rule.CustomName] = rule.Rule.TargetTags
+			}
+			if len(listed) != test.Listed || listed["https"][0] != "sp-web-https" {
+				t.Errorf("Unexpected rules. Got %v", listed)
+			}
+
+			// Tags without secure tag value are refused
+			_, err = CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "http", rule)
+			if e, ok := err.(*models.ApplicationError); !ok || e.Code != http.StatusBadRequest {
+				t.Errorf("Expected a bad request error. Got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateFirewallRuleKeepsRename(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Name: "sp-web-tls", Description: "[application web] HTTPS [renamed from web/https, complete after 2030-01-01T00:00:00Z]", TargetTags: []string{"sp-web-tls", "sp-web-https"}}}
+
+	applicationRule, err := UpdateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "web", "tls", compute.Firewall{Description: "TLS"})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	// The old tag and rename record are kept until the rename is completed
+	rule := applicationRule.Rules[0].Rule
+	if len(rule.TargetTags) != 2 || rule.TargetTags[1] != "sp-web-https" {
+		t.Errorf("Expected additional target tags to be kept. Got %v", rule.TargetTags)
+	}
+	if rule.Description != "[application web] TLS [renamed from web/https, complete after 2030-01-01T00:00:00Z]" {
+		t.Errorf("Expected rename record to be kept. Got %q", rule.Description)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadGuardrailPolicy returns the guardrail policy of the given JSON or YAML file. Without file, there is no guardrail
+func LoadGuardrailPolicy(path string) (models.GuardrailPolicy, error) {
+	policy := models.GuardrailPolicy{}
+	if path == "" {
+		return policy, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalJSONOrYAML(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid guardrail policy %s: %v", path, err)
+	}
+
+	for project, guardrails := range policy {
+		if guardrails.LoggingMetadata != "" && !validLoggingMetadata(guardrails.LoggingMetadata) {
+			return nil, fmt.Errorf("invalid guardrail policy %s: project %s: invalid logging metadata %s", path, project, guardrails.LoggingMetadata)
+		}
+	}
+
+	logrus.Debugf("Loaded guardrails of %d host projects from %s", len(policy), path)
+	return policy, nil
+}
+
+// unmarshalJSONOrYAML decodes given JSON, or YAML when not JSON. JSON is decoded as such, as YAML does not accept all JSON
+// documents, such as the ones indented with tabs
+func unmarshalJSONOrYAML(data []byte, v interface{}) error {
+	if json.Valid(data) {
+		return json.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// ApplyGuardrails ensures given rule complies with the host project guardrails, enabling its logging when forced
+func ApplyGuardrails(policy models.GuardrailPolicy, project string, rule *compute.Firewall) error {
+	guardrails := policy.For(project)
+	if rule.Disabled && guardrails.ForbidDisable {
+		return models.NewForbiddenError(fmt.Sprintf("Disabling rules is forbidden by [%s] policy", project))
+	}
+
+	if rule.LogConfig != nil {
+		err := validateLogging(models.LoggingRequest{Enable: rule.LogConfig.Enable, Metadata: rule.LogConfig.Metadata})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !guardrails.ForceLogging {
+		return nil
+	}
+
+	if rule.LogConfig == nil {
+		rule.LogConfig = &compute.FirewallLogConfig{}
+	}
+	rule.LogConfig.Enable = true
+	if guardrails.LoggingMetadata != "" {
+		rule.LogConfig.Metadata = guardrails.LoggingMetadata
+	}
+	return nil
+}
+
+// checkLoggingGuardrails ensures given logging complies with the host project guardrails
+func checkLoggingGuardrails(policy models.GuardrailPolicy, project string, logging models.LoggingRequest) error {
+	guardrails := policy.For(project)
+	if !guardrails.ForceLogging {
+		return nil
+	}
+
+	if !logging.Enable {
+		return models.NewForbiddenError(fmt.Sprintf("Logging is forced by [%s] policy", project))
+	}
+
+	if guardrails.LoggingMetadata != "" && logging.Metadata != "" && logging.Metadata != guardrails.LoggingMetadata {
+		return models.NewForbiddenError(fmt.Sprintf("Logging metadata is forced to [%s] by [%s] policy", guardrails.LoggingMetadata, project))
+	}
+	return nil
+}
+
+// validateLogging ensures metadata are only given along enabled logging, with a known option
+func validateLogging(logging models.LoggingRequest) error {
+	if logging.Metadata == "" {
+		return nil
+	}
+
+	if !logging.Enable {
+		return models.NewBadRequestError("Logging metadata can only be given when logging is enabled")
+	}
+
+	if !validLoggingMetadata(logging.Metadata) {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid logging metadata [%s]. Expected %s or %s", logging.Metadata, models.LoggingIncludeAllMetadata, models.LoggingExcludeAllMetadata))
+	}
+	return nil
+}
+
+func validLoggingMetadata(metadata string) bool {
+	return metadata == models.LoggingIncludeAllMetadata || metadata == models.LoggingExcludeAllMetadata
+}
@@ -1,10 +1,12 @@
 package services
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"strings"
 
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
 	"github.com/adeo/iwc-gcp-firewall-api/models"
 	"github.com/sirupsen/logrus"
 )
@@ -16,9 +18,9 @@ type JWT struct {
 	EmailVerified bool   `json:"email_verified"`
 }
 
-// GetUserEmailFromJWT parse the given JWT and return the user email
-func GetUserEmailFromJWT(token string) (string, error) {
-	logrus.Debugln("Decoding token")
+// GetUserEmailFromJWT parse the given JWT and return the user email, if issued by one of the trusted issuers
+func GetUserEmailFromJWT(ctx context.Context, token string, trustedIssuers []string) (string, error) {
+	helpers.Logger(ctx).Debugln("Decoding token")
 
 	// Ensure token contains 3 parts
 	tokenParts := strings.Split(token, ".")
@@ -30,25 +32,19 @@ func GetUserEmailFromJWT(token string) (string, error) {
 
 	data, err := base64.StdEncoding.DecodeString(tokenPart)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"go-err":     err,
-			"token-part": tokenParts[1],
-		}).Error("Error while base64 decoding token")
+		helpers.Logger(ctx).WithField("go-err", err).Debugln("Error while base64 decoding token")
 		return "", models.NewBadTokenError()
 	}
 
 	var t JWT
 	err = json.Unmarshal(data, &t)
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"go-err":     err,
-			"token-data": string(data),
-		}).Error("Error while JSON decoding token")
+		helpers.Logger(ctx).WithField("go-err", err).Debugln("Error while JSON decoding token")
 		return "", models.NewBadTokenError()
 	}
 
-	if t.Iss != "https://accounts.google.com" {
-		logrus.WithFields(logrus.Fields{
+	if !containsString(trustedIssuers, t.Iss) {
+		helpers.Logger(ctx).WithFields(logrus.Fields{
 			"issuer": t.Iss,
 		}).Warningln("Invalid issuer")
 		return "", models.NewBadTokenError("Invalid issuer")
@@ -58,7 +54,8 @@ func GetUserEmailFromJWT(token string) (string, error) {
 		return "", models.NewBadTokenError("Email not veried")
 	}
 
-	logrus.Debugf("User found: %s", t.Email)
+	helpers.SetUser(ctx, t.Email)
+	helpers.Logger(ctx).Debugln("User found")
 	return t.Email, nil
 }
 
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -59,7 +60,7 @@ func TestGetUserEmailFromJWT(t *testing.T) {
 	var expected string
 	for _, test := range tests {
 		t.Run(test.Title, func(t *testing.T) {
-			result, err := GetUserEmailFromJWT(test.Test)
+			result, err := GetUserEmailFromJWT(context.Background(), test.Test, []string{"https://accounts.google.com"})
 
 			// Cast okay, we have an error
 			if err != nil {
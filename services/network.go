@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// ListSharedNetworks returns host project's networks and subnetworks shared with the service project
+func ListSharedNetworks(ctx context.Context, networkManager models.NetworkManager, project, serviceProject string) (*models.SharedNetworks, error) {
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+	}).Debugln("Listing shared networks")
+
+	networks, err := networkManager.ListSharedNetworks(project, serviceProject)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SharedNetworks{
+		Project:        project,
+		ServiceProject: serviceProject,
+		Networks:       networks,
+	}, nil
+}
+
+// ResolveNetwork ensures the network of given rules is a network of the host project shared with the service project,
+// and normalizes it to its self-link. Rules without network use the shared network when there is a single one
+func ResolveNetwork(ctx context.Context, networkManager models.NetworkManager, project, serviceProject string, rules ...*compute.Firewall) error {
+	networks, err := networkManager.ListSharedNetworks(project, serviceProject)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		err := resolveNetwork(ctx, networks, project, serviceProject, rule)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveNetwork(ctx context.Context, networks []models.Network, project, serviceProject string, rule *compute.Firewall) error {
+	names := make([]string, 0, len(networks))
+	for _, n := range networks {
+		names = append(names, n.SelfLink)
+	}
+
+	if rule.Network == "" {
+		if len(networks) != 1 {
+			return models.NewBadRequestError(fmt.Sprintf("Missing network. Expected one of [%s]", strings.Join(names, ", ")))
+		}
+
+		helpers.Logger(ctx).WithFields(logrus.Fields{
+			"project":         project,
+			"service_project": serviceProject,
+			"network":         networks[0].SelfLink,
+		}).Debugln("Using default network")
+		rule.Network = networks[0].SelfLink
+		return nil
+	}
+
+	networkProject, name := parseNetwork(rule.Network)
+	if networkProject == "" || networkProject == project {
+		for _, n := range networks {
+			if n.Name == name {
+				rule.Network = n.SelfLink
+				return nil
+			}
+		}
+	}
+
+	return models.NewBadRequestError(fmt.Sprintf("Network [%s] is not a network of [%s] shared with [%s]. Expected one of [%s]", rule.Network, project, serviceProject, strings.Join(names, ", ")))
+}
+
+// parseNetwork returns the project and the name of a network reference. Project is empty for relative references.
+// Supported references are full or partial URLs, such as
+// https://www.googleapis.com/compute/v1/projects/<project>/global/networks/<name>, projects/<project>/global/networks/<name>,
+// global/networks/<name> or <name>
+func parseNetwork(network string) (project, name string) {
+	parts := strings.Split(strings.Trim(network, "/"), "/")
+	name = parts[len(parts)-1]
+
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "projects" {
+			project = parts[i+1]
+			break
+		}
+	}
+
+	// Only global networks are supported
+	if len(parts) > 1 && (len(parts) < 3 || parts[len(parts)-2] != "networks" || parts[len(parts)-3] != "global") {
+		return project, ""
+	}
+
+	return project, name
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func newNetworkDummyClient() *fakes.NetworkDummyClient {
+	return &fakes.NetworkDummyClient{Networks: map[string][]models.Network{
+		"host/sp": []models.Network{
+			models.Network{Name: "lh-network", SelfLink: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"},
+		},
+		"host/sp-multi": []models.Network{
+			models.Network{Name: "lh-network", SelfLink: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"},
+			models.Network{Name: "lh-network-2", SelfLink: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network-2"},
+		},
+	}}
+}
+
+func TestListSharedNetworks(t *testing.T) {
+	sharedNetworks, err := ListSharedNetworks(context.Background(), newNetworkDummyClient(), "host", "sp")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if sharedNetworks.Project != "host" || sharedNetworks.ServiceProject != "sp" || len(sharedNetworks.Networks) != 1 {
+		t.Errorf("Unexpected shared networks. Got %+v", sharedNetworks)
+	}
+}
+
+func TestResolveNetwork(t *testing.T) {
+	networkManager := newNetworkDummyClient()
+	selfLink := "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network"
+
+	tests := []struct {
+		Title          string
+		ServiceProject string
+		Network        string
+		Expected       int
+		Resolved       string
+	}{
+		{Title: "Default network", ServiceProject: "sp", Network: "", Resolved: selfLink},
+		{Title: "Ambiguous default network", ServiceProject: "sp-multi", Network: "", Expected: http.StatusBadRequest},
+		{Title: "No shared network", ServiceProject: "sp-none", Network: "", Expected: http.StatusBadRequest},
+		{Title: "Name", ServiceProject: "sp-multi", Network: "lh-network-2", Resolved: "https://www.googleapis.com/compute/v1/projects/host/global/networks/lh-network-2"},
+		{Title: "Relative reference", ServiceProject: "sp", Network: "global/networks/lh-network", Resolved: selfLink},
+		{Title: "Partial reference", ServiceProject: "sp", Network: "projects/host/global/networks/lh-network", Resolved: selfLink},
+		{Title: "Self-link", ServiceProject: "sp", Network: selfLink, Resolved: selfLink},
+		{Title: "Beta self-link", ServiceProject: "sp", Network: "https://www.googleapis.com/compute/beta/projects/host/global/networks/lh-network", Resolved: selfLink},
+		{Title: "Other project", ServiceProject: "sp", Network: "https://www.googleapis.com/compute/v1/projects/other/global/networks/lh-network", Expected: http.StatusBadRequest},
+		{Title: "Not shared network", ServiceProject: "sp", Network: "global/networks/lh-network-2", Expected: http.StatusBadRequest},
+		{Title: "Not a network", ServiceProject: "sp", Network: "regions/europe-west1/subnetworks/lh-network", Expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			rule := &compute.Firewall{Network: test.Network}
+			err := ResolveNetwork(context.Background(), networkManager, "host", test.ServiceProject, rule)
+			assertErrorCode(t, err, test.Expected)
+
+			if err == nil && rule.Network != test.Resolved {
+				t.Errorf("Unexpected network. Got %s want %s", rule.Network, test.Resolved)
+			}
+		})
+	}
+}
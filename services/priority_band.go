@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Google priority numbers bands can use. Priority 0 is left out, as an unset priority which Google replaces with 1000
+const (
+	minPriority = 1
+	maxPriority = 65535
+)
+
+// Priority band names are lowercase letters, digits and hyphens, starting with a letter
+var priorityBandNameRegexp = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// LoadPriorityBands returns a store of the priority bands set by configuration of given host projects,
+// checked as on creation through the API
+func LoadPriorityBands(hostProjects []config.HostProject) (*models.PriorityBandMemoryStore, error) {
+	store := models.NewPriorityBandMemoryStore()
+	for _, hostProject := range hostProjects {
+		for _, band := range hostProject.PriorityBands {
+			err := SavePriorityBand(context.Background(), store, hostProject.Project, models.PriorityBand{Name: band.Name, Base: band.Base, Default: band.Default})
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority bands of host project %s: %v", hostProject.Project, configurationError(err))
+			}
+
+			for _, application := range band.Applications {
+				parts := strings.SplitN(application, "/", 2)
+				store.SaveAssignment(models.BandAssignment{Project: hostProject.Project, ServiceProject: parts[0], Application: parts[1], Band: band.Name})
+			}
+		}
+
+		if len(hostProject.PriorityBands) > 0 {
+			logrus.Debugf("Loaded %d priority bands of host project %s", len(hostProject.PriorityBands), hostProject.Project)
+		}
+	}
+	return store, nil
+}
+
+// ListPriorityBands returns priority bands of the host project
+func ListPriorityBands(store models.PriorityBandStore, project string) (*models.PriorityBands, error) {
+	bands, err := store.ListPriorityBands(project)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PriorityBands{Project: project, Bands: bands}, nil
+}
+
+// SavePriorityBand creates or replaces a priority band of the host project, not overlapping other bands.
+// A single band can be the default one
+func SavePriorityBand(ctx context.Context, store models.PriorityBandStore, project string, band models.PriorityBand) error {
+	if !priorityBandNameRegexp.MatchString(band.Name) {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid priority band name [%s]. Must match %s", band.Name, priorityBandNameRegexp.String()))
+	}
+
+	if band.Base < minPriority || band.Base+models.PriorityBandWidth-1 > maxPriority {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid priority band base [%d]. Must be between %d and %d", band.Base, minPriority, maxPriority-models.PriorityBandWidth+1))
+	}
+
+	bands, err := store.ListPriorityBands(project)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range bands {
+		if other.Name == band.Name {
+			continue
+		}
+
+		if band.Base < other.Base+models.PriorityBandWidth && other.Base < band.Base+models.PriorityBandWidth {
+			return models.NewConflictError(fmt.Sprintf("Priority band [%s] overlaps priority band [%s] starting at %d", band.Name, other.Name, other.Base))
+		}
+
+		if band.Default && other.Default {
+			return models.NewConflictError(fmt.Sprintf("Priority band [%s] is already the default one", other.Name))
+		}
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":       project,
+		"priority_band": band.Name,
+	}).Debugf("Saving priority band starting at %d", band.Base)
+
+	return store.SavePriorityBand(project, band)
+}
+
+// DeletePriorityBand deletes a priority band of the host project which has no application
+func DeletePriorityBand(ctx context.Context, store models.PriorityBandStore, project, name string) error {
+	assignments, err := store.ListAssignments(project, name)
+	if err != nil {
+		return err
+	}
+
+	if len(assignments) > 0 {
+		return models.NewConflictError(fmt.Sprintf("Priority band [%s] has %d applications", name, len(assignments)))
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":       project,
+		"priority_band": name,
+	}).Debugln("Deleting priority band")
+
+	return store.DeletePriorityBand(project, name)
+}
+
+// GetPriorityBandAssignment returns the priority band of the application, the default one if it has none
+func GetPriorityBandAssignment(store models.PriorityBandStore, project, serviceProject, application string) (*models.BandAssignment, error) {
+	band, err := applicationPriorityBand(store, project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	if band == nil {
+		e := models.NewNotFoundError()
+		e.Message = fmt.Sprintf("Application [%s] has no priority band in project [%s]", application, project)
+		return nil, e
+	}
+
+	return &models.BandAssignment{Project: project, ServiceProject: serviceProject, Application: application, Band: band.Name}, nil
+}
+
+// AssignPriorityBand sets the priority band of the application. Existing rules are kept as is
+func AssignPriorityBand(ctx context.Context, store models.PriorityBandStore, assignment models.BandAssignment) error {
+	_, err := store.GetPriorityBand(assignment.Project, assignment.Band)
+	if err != nil {
+		return err
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         assignment.Project,
+		"service_project": assignment.ServiceProject,
+		"application":     assignment.Application,
+		"priority_band":   assignment.Band,
+	}).Debugln("Assigning priority band")
+
+	return store.SaveAssignment(assignment)
+}
+
+// ApplyPriorityBand translates the priority of given rule request, relative to the application band, into a Google priority.
+// Requests without priority take the middle of the band. Host projects without bands keep given priorities
+func ApplyPriorityBand(store models.PriorityBandStore, project, serviceProject, application string, request *models.FirewallRuleRequest) error {
+	bands, err := store.ListPriorityBands(project)
+	if err != nil || len(bands) == 0 {
+		return err
+	}
+
+	band, err := applicationPriorityBand(store, project, serviceProject, application)
+	if err != nil {
+		return err
+	}
+
+	if band == nil {
+		return models.NewBadRequestError(fmt.Sprintf("Application [%s] has no priority band in project [%s]", application, project))
+	}
+
+	if request.PriorityUnset {
+		request.Priority = models.DefaultRelativePriority
+	}
+
+	if request.Priority < 0 || request.Priority >= models.PriorityBandWidth {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid priority [%d]. Must be between 0 and %d in priority band [%s]", request.Priority, models.PriorityBandWidth-1, band.Name))
+	}
+
+	request.Priority += band.Base
+	return nil
+}
+
+// RelativePriority translates a Google priority of an application rule back into a priority relative to the application band.
+// Host projects without bands are kept as is. Priorities out of the band, such as set before the band, are kept as is and
+// reported out of band, as no relative priority gives them back
+func RelativePriority(store models.PriorityBandStore, project, serviceProject, application string, priority int64) (int64, bool, error) {
+	band, err := applicationPriorityBand(store, project, serviceProject, application)
+	if err != nil || band == nil {
+		return priority, false, err
+	}
+
+	if priority < band.Base || priority >= band.Base+models.PriorityBandWidth {
+		return priority, true, nil
+	}
+	return priority - band.Base, false, nil
+}
+
+// RelativePriorities translates Google priorities of given application rules, in place, into the priorities clients give.
+// Rules out of their band keep their Google priority, and are flagged so
+func RelativePriorities(store models.PriorityBandStore, applicationRule *models.ApplicationRule) error {
+	for i := range applicationRule.Rules {
+		outOfBand, err := relativeRulePriority(store, applicationRule.Project, applicationRule.ServiceProject, applicationRule.Application, &applicationRule.Rules[i].Rule)
+		if err != nil {
+			return err
+		}
+		applicationRule.Rules[i].OutOfBand = outOfBand
+	}
+	return nil
+}
+
+// RelativeBatchPriorities translates Google priorities of the rules of given batch results into the priorities clients give.
+// Results without service project or application are of the given ones. Rules are copied, as they may be shared with the manager
+func RelativeBatchPriorities(store models.PriorityBandStore, project, serviceProject, application string, results []models.BatchRuleResult) error {
+	for i, result := range results {
+		if result.Rule == nil {
+			continue
+		}
+
+		if result.ServiceProject == "" {
+			result.ServiceProject = serviceProject
+		}
+		if result.Application == "" {
+			result.Application = application
+		}
+
+		rule := *result.Rule
+		outOfBand, err := relativeRulePriority(store, project, result.ServiceProject, result.Application, &rule)
+		if err != nil {
+			return err
+		}
+		results[i].Rule = &rule
+		results[i].OutOfBand = outOfBand
+	}
+	return nil
+}
+
+// relativeRulePriority translates the Google priority of given application rule into a priority relative to its band,
+// and tells whether it is out of the band. Priority 0 is then a real priority, sent even though Google rules omit it
+func relativeRulePriority(store models.PriorityBandStore, project, serviceProject, application string, rule *compute.Firewall) (bool, error) {
+	priority, outOfBand, err := RelativePriority(store, project, serviceProject, application, rule.Priority)
+	if err != nil || priority == rule.Priority {
+		return outOfBand, err
+	}
+
+	rule.Priority = priority
+	rule.ForceSendFields = append(rule.ForceSendFields, "Priority")
+	return false, nil
+}
+
+// bandedPriority returns the priority of given application rule relative to its band, to write it again.
+// Rules out of their band are refused, as writing them again would move them
+func bandedPriority(store models.PriorityBandStore, project, serviceProject, application string, rule models.FirewallRule) (int64, error) {
+	priority, outOfBand, err := RelativePriority(store, project, serviceProject, application, rule.Rule.Priority)
+	if err != nil {
+		return 0, err
+	}
+	if outOfBand {
+		return 0, models.NewConflictError(fmt.Sprintf("Rule [%s] has priority [%d], out of the priority band of application [%s]. Update its priority first", rule.CustomName, rule.Rule.Priority, application))
+	}
+	return priority, nil
+}
+
+// configurationError returns the message of given error, without its HTTP code
+func configurationError(err error) string {
+	if e, ok := err.(*models.ApplicationError); ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
+// applicationPriorityBand returns the band of the application, the default one if it has none. Nil if there is none
+func applicationPriorityBand(store models.PriorityBandStore, project, serviceProject, application string) (*models.PriorityBand, error) {
+	assignment, err := store.GetAssignment(project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	if assignment != nil {
+		return store.GetPriorityBand(project, assignment.Band)
+	}
+
+	bands, err := store.ListPriorityBands(project)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, band := range bands {
+		if band.Default {
+			return &band, nil
+		}
+	}
+	return nil, nil
+}
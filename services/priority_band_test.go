@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestSavePriorityBand(t *testing.T) {
+	store := models.NewPriorityBandMemoryStore()
+	store.SavePriorityBand("host", models.PriorityBand{Name: "security", Base: 200})
+	store.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 1000, Default: true})
+
+	tests := []struct {
+		Title    string
+		Band     models.PriorityBand
+		Expected int
+	}{
+		{Title: "Invalid name", Band: models.PriorityBand{Name: "Apps", Base: 2000}, Expected: http.StatusBadRequest},
+		{Title: "Negative base", Band: models.PriorityBand{Name: "low", Base: -1}, Expected: http.StatusBadRequest},
+		{Title: "Unset priority base", Band: models.PriorityBand{Name: "low", Base: 0}, Expected: http.StatusBadRequest},
+		{Title: "First band", Band: models.PriorityBand{Name: "top", Base: 1}},
+		{Title: "Base too high", Band: models.PriorityBand{Name: "low", Base: 65500}, Expected: http.StatusBadRequest},
+		{Title: "Overlapping band", Band: models.PriorityBand{Name: "critical", Base: 1050}, Expected: http.StatusConflict},
+		{Title: "Second default band", Band: models.PriorityBand{Name: "critical", Base: 500, Default: true}, Expected: http.StatusConflict},
+		{Title: "Adjacent band", Band: models.PriorityBand{Name: "critical", Base: 900}},
+		{Title: "Last band", Band: models.PriorityBand{Name: "low", Base: 65436}},
+		{Title: "Moved band", Band: models.PriorityBand{Name: "apps", Base: 1010, Default: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			assertErrorCode(t, SavePriorityBand(context.Background(), store, "host", test.Band), test.Expected)
+		})
+	}
+}
+
+func TestLoadPriorityBands(t *testing.T) {
+	hostProjects := []config.HostProject{
+		{Project: "host", PriorityBands: []config.PriorityBand{
+			{Name: "apps", Base: 2000, Default: true, Applications: []string{"sp/web", "sp-a/front"}},
+			{Name: "critical", Base: 1000},
+		}},
+		{Project: "other-host"},
+	}
+
+	store, err := LoadPriorityBands(hostProjects)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	bands, _ := store.ListPriorityBands("host")
+	assignments, _ := store.ListAssignments("host", "apps")
+	if len(bands) != 2 || len(assignments) != 2 {
+		t.Errorf("Unexpected bands. Got %v, %v", bands, assignments)
+	}
+
+	tests := []struct {
+		Title    string
+		Bands    []config.PriorityBand
+		Expected string
+	}{
+		{Title: "Invalid name", Bands: []config.PriorityBand{{Name: "Apps", Base: 2000}}, Expected: "Invalid priority band name [Apps]"},
+		{Title: "Invalid base", Bands: []config.PriorityBand{{Name: "apps"}}, Expected: "Invalid priority band base [0]"},
+		{Title: "Overlapping bands", Bands: []config.PriorityBand{{Name: "apps", Base: 2000}, {Name: "critical", Base: 2050}}, Expected: "Priority band [critical] overlaps priority band [apps]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			_, err := LoadPriorityBands([]config.HostProject{{Project: "host", PriorityBands: test.Bands}})
+			if err == nil || !strings.Contains(err.Error(), "invalid priority bands of host project host: "+test.Expected) {
+				t.Errorf("Unexpected error. Got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyPriorityBand(t *testing.T) {
+	store := models.NewPriorityBandMemoryStore()
+
+	// Without bands, priorities are kept
+	rule := models.FirewallRuleRequest{Firewall: compute.Firewall{Priority: 1000}}
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "web", &rule), 0)
+	if rule.Priority != 1000 {
+		t.Errorf("Priority should be kept. Got %d", rule.Priority)
+	}
+
+	store.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 2000})
+	store.SavePriorityBand("host", models.PriorityBand{Name: "critical", Base: 1000})
+
+	// Without default band, applications must have a band
+	rule = models.FirewallRuleRequest{Firewall: compute.Firewall{Priority: 10}}
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "web", &rule), http.StatusBadRequest)
+
+	assertErrorCode(t, AssignPriorityBand(context.Background(), store, models.BandAssignment{Project: "host", ServiceProject: "sp", Application: "web", Band: "missing"}), http.StatusNotFound)
+	assertErrorCode(t, AssignPriorityBand(context.Background(), store, models.BandAssignment{Project: "host", ServiceProject: "sp", Application: "web", Band: "critical"}), 0)
+
+	assignment, err := GetPriorityBandAssignment(store, "host", "sp", "web")
+	if err != nil || assignment.Band != "critical" {
+		t.Errorf("Unexpected assignment. Got %+v, %v", assignment, err)
+	}
+
+	_, err = GetPriorityBandAssignment(store, "host", "sp", "api")
+	assertErrorCode(t, err, http.StatusNotFound)
+
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "web", &rule), 0)
+	if rule.Priority != 1010 {
+		t.Errorf("Wrong priority. Got %d want %d", rule.Priority, 1010)
+	}
+
+	// Default band
+	store.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 2000, Default: true})
+	rule = models.FirewallRuleRequest{Firewall: compute.Firewall{Priority: 99}}
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "api", &rule), 0)
+	if rule.Priority != 2099 {
+		t.Errorf("Wrong priority. Got %d want %d", rule.Priority, 2099)
+	}
+
+	// Without priority, rules take the middle of the band
+	rule = models.FirewallRuleRequest{PriorityUnset: true}
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "api", &rule), 0)
+	if rule.Priority != 2050 {
+		t.Errorf("Wrong default priority. Got %d want %d", rule.Priority, 2050)
+	}
+
+	// Out of band
+	rule = models.FirewallRuleRequest{Firewall: compute.Firewall{Priority: 100}}
+	assertErrorCode(t, ApplyPriorityBand(store, "host", "sp", "api", &rule), http.StatusBadRequest)
+
+	// Bands with applications are kept
+	assertErrorCode(t, DeletePriorityBand(context.Background(), store, "host", "critical"), http.StatusConflict)
+	store.DeleteAssignment("host", "sp", "web")
+	assertErrorCode(t, DeletePriorityBand(context.Background(), store, "host", "critical"), 0)
+}
+
+func TestRelativePriorities(t *testing.T) {
+	store := models.NewPriorityBandMemoryStore()
+	store.SavePriorityBand("host", models.PriorityBand{Name: "apps", Base: 2000, Default: true})
+
+	applicationRule := &models.ApplicationRule{Project: "host", ServiceProject: "sp", Application: "web", Rules: models.FirewallRules{
+		{Rule: compute.Firewall{Name: "sp-web-https", Priority: 2010}},
+		{Rule: compute.Firewall{Name: "sp-web-ssh", Priority: 2000}},
+		{Rule: compute.Firewall{Name: "sp-web-legacy", Priority: 1000}},
+	}}
+	assertErrorCode(t, RelativePriorities(store, applicationRule), 0)
+
+	for i, expected := range []int64{10, 0, 1000} {
+		if applicationRule.Rules[i].Rule.Priority != expected {
+			t.Errorf("Wrong priority of rule [%s]. Got %d want %d", applicationRule.Rules[i].Rule.Name, applicationRule.Rules[i].Rule.Priority, expected)
+		}
+	}
+
+	// Priorities out of the band are flagged, not to be taken for relative ones
+	for i, expected := range []bool{false, false, true} {
+		if applicationRule.Rules[i].OutOfBand != expected {
+			t.Errorf("Wrong out of band flag of rule [%s]. Got %v want %v", applicationRule.Rules[i].Rule.Name, applicationRule.Rules[i].OutOfBand, expected)
+		}
+	}
+
+	// Priority 0 of the band is sent to clients
+	content, _ := json.Marshal(&applicationRule.Rules[1].Rule)
+	if !strings.Contains(string(content), `"priority":0`) {
+		t.Errorf("Priority 0 should be sent. Got %s", content)
+	}
+
+	// Rules of batch results are copied
+	rule := &compute.Firewall{Name: "sp-api-https", Priority: 2010}
+	results := []models.BatchRuleResult{
+		{CustomName: "https", Application: "api", Rule: rule},
+		{CustomName: "ssh"},
+		{CustomName: "legacy", Rule: &compute.Firewall{Name: "sp-web-legacy", Priority: 1000}},
+	}
+	assertErrorCode(t, RelativeBatchPriorities(store, "host", "sp", "web", results), 0)
+	if results[0].Rule.Priority != 10 || rule.Priority != 2010 || results[0].OutOfBand {
+		t.Errorf("Wrong batch priority. Got %d, original %d", results[0].Rule.Priority, rule.Priority)
+	}
+	if results[2].Rule.Priority != 1000 || !results[2].OutOfBand {
+		t.Errorf("Priority out of the band should be flagged. Got %+v", results[2])
+	}
+}
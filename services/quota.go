@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// CheckQuota ensures creating given rules keeps the application and its service project within the host project quotas.
+// Service projects of the host project tell rules of the service project from the ones of service projects sharing its prefix
+func CheckQuota(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project, serviceProject, application string, rules ...*compute.Firewall) error {
+	return checkQuota(ctx, manager, serviceProjects, policy, project, serviceProject, application, "", rules...)
+}
+
+// checkQuota is CheckQuota, the rule of given name being replaced by given rules if not empty
+func checkQuota(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project, serviceProject, application, replaced string, rules ...*compute.Firewall) error {
+	guardrails := policy.For(project)
+	if guardrails.MaxRulesPerApplication == 0 && guardrails.MaxRulesPerServiceProject == 0 && guardrails.MaxSourceRanges == 0 {
+		return nil
+	}
+
+	gRules, err := manager.ListFirewallRule(project)
+	if err != nil {
+		return err
+	}
+
+	siblings, err := siblingServiceProjects(serviceProjects, project, serviceProject)
+	if err != nil {
+		return err
+	}
+
+	applicationRules, serviceProjectRules, sourceRanges := quotaUsage(gRules, serviceProject, application, siblings, replaced)
+	for _, rule := range rules {
+		sourceRanges += int64(len(rule.SourceRanges))
+	}
+
+	exceeded := func(usage, limit int64) bool {
+		return limit > 0 && usage > limit
+	}
+
+	count := int64(len(rules))
+	switch {
+	case exceeded(applicationRules+count, guardrails.MaxRulesPerApplication):
+		return models.NewForbiddenError(fmt.Sprintf("Quota exceeded: application [%s] is limited to %d rules, it has %d", application, guardrails.MaxRulesPerApplication, applicationRules))
+	case exceeded(serviceProjectRules+count, guardrails.MaxRulesPerServiceProject):
+		return models.NewForbiddenError(fmt.Sprintf("Quota exceeded: service project [%s] is limited to %d rules, it has %d", serviceProject, guardrails.MaxRulesPerServiceProject, serviceProjectRules))
+	case exceeded(sourceRanges, guardrails.MaxSourceRanges):
+		return models.NewForbiddenError(fmt.Sprintf("Quota exceeded: service project [%s] is limited to %d source ranges, it would have %d", serviceProject, guardrails.MaxSourceRanges, sourceRanges))
+	}
+
+	return nil
+}
+
+// GetQuota returns the quotas usage of the application, of its service project and of the host project
+func GetQuota(ctx context.Context, manager models.FirewallRuleManager, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, project, serviceProject, application string) (*models.QuotaReport, error) {
+	gRules, err := manager.ListFirewallRule(project)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings, err := siblingServiceProjects(serviceProjects, project, serviceProject)
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := manager.GetFirewallQuota(project)
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+	}).Debugf("Host project uses %.0f rules of %.0f", quota.Usage, quota.Limit)
+
+	// Backends without known limit have no remaining rules
+	var remaining int64
+	if quota.Limit > 0 {
+		remaining = int64(quota.Limit - quota.Usage)
+	}
+
+	guardrails := policy.For(project)
+	applicationRules, serviceProjectRules, sourceRanges := quotaUsage(gRules, serviceProject, application, siblings, "")
+	return &models.QuotaReport{
+		Project:                    project,
+		ServiceProject:             serviceProject,
+		Application:                application,
+		ApplicationRules:           models.QuotaUsage{Usage: applicationRules, Limit: guardrails.MaxRulesPerApplication},
+		ServiceProjectRules:        models.QuotaUsage{Usage: serviceProjectRules, Limit: guardrails.MaxRulesPerServiceProject},
+		ServiceProjectSourceRanges: models.QuotaUsage{Usage: sourceRanges, Limit: guardrails.MaxSourceRanges},
+		ProjectRules:               models.QuotaUsage{Usage: int64(quota.Usage), Limit: int64(quota.Limit)},
+		ProjectRemainingRules:      remaining,
+	}, nil
+}
+
+// quotaUsage returns the count of rules of the application, and the count of rules and source ranges of its service project.
+// Rules of given sibling service projects, whose names start like the service project ones, and the replaced rule are left out,
+// as rules of applications named alike
+func quotaUsage(rules []*compute.Firewall, serviceProject, application string, siblings []string, replaced string) (applicationRules, serviceProjectRules, sourceRanges int64) {
+	serviceProjectPrefix := fmt.Sprintf("%s-", serviceProject)
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Name, serviceProjectPrefix) || rule.Name == replaced || isSiblingRule(rule.Name, siblings) {
+			continue
+		}
+
+		serviceProjectRules++
+		sourceRanges += int64(len(rule.SourceRanges))
+		if _, ok := ownedRule(rule, serviceProject, application); ok {
+			applicationRules++
+		}
+	}
+	return applicationRules, serviceProjectRules, sourceRanges
+}
+
+// siblingServiceProjects returns service projects of the host project whose ID starts with the one of given service project,
+// such as foo-bar for foo. Without lister, there is none
+func siblingServiceProjects(serviceProjects models.ServiceProjectLister, project, serviceProject string) ([]string, error) {
+	if serviceProjects == nil {
+		return nil, nil
+	}
+
+	all, err := serviceProjects.ListServiceProjects(project)
+	if err != nil {
+		return nil, err
+	}
+
+	var siblings []string
+	for _, other := range all {
+		if strings.HasPrefix(other, serviceProject+"-") {
+			siblings = append(siblings, other)
+		}
+	}
+	return siblings, nil
+}
+
+// isSiblingRule tells whether given rule name is the one of a rule of given sibling service projects
+func isSiblingRule(name string, siblings []string) bool {
+	for _, sibling := range siblings {
+		if strings.HasPrefix(name, sibling+"-") {
+			return true
+		}
+	}
+	return false
+}
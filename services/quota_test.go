@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestCheckQuota(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerApplication: 2, MaxRulesPerServiceProject: 3, MaxSourceRanges: 4}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8"}})
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "api", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8"}})
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "other-sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16"}})
+
+	tests := []struct {
+		Title       string
+		Application string
+		Rules       []*compute.Firewall
+		Expected    int
+	}{
+		{Title: "Within quotas", Application: "web", Rules: []*compute.Firewall{&compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16"}}}},
+		{Title: "Application rules", Application: "web", Rules: []*compute.Firewall{&compute.Firewall{}, &compute.Firewall{}}, Expected: http.StatusForbidden},
+		{Title: "Service project rules", Application: "batch", Rules: []*compute.Firewall{&compute.Firewall{}, &compute.Firewall{}}, Expected: http.StatusForbidden},
+		{Title: "Source ranges", Application: "batch", Rules: []*compute.Firewall{&compute.Firewall{SourceRanges: []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}}}, Expected: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			assertErrorCode(t, CheckQuota(context.Background(), manager, nil, policy, "host", "sp", test.Application, test.Rules...), test.Expected)
+		})
+	}
+
+	// Enforced on creation
+	_, err := CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "http", compute.Firewall{})
+	assertErrorCode(t, err, 0)
+	_, err = CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "ssh", compute.Firewall{})
+	assertErrorCode(t, err, http.StatusForbidden)
+
+	// Without quotas
+	assertErrorCode(t, CheckQuota(context.Background(), manager, nil, nil, "host", "sp", "web", &compute.Firewall{}, &compute.Firewall{}), 0)
+}
+
+func TestBatchCreateFirewallRulesQuota(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"*": models.ProjectGuardrails{MaxRulesPerApplication: 2}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{})
+
+	batchResult := BatchCreateFirewallRules(context.Background(), manager, nil, policy, prepareNothing, "host", "sp", "web", []models.BatchRuleRequest{
+		{CustomName: "http"},
+		{CustomName: "ssh"},
+		{CustomName: ""},
+	})
+
+	expected := []int{http.StatusForbidden, http.StatusForbidden, http.StatusBadRequest}
+	for i, result := range batchResult.Results {
+		if result.Code != expected[i] {
+			t.Errorf("Wrong code for rule %d. Got %d want %d", i, result.Code, expected[i])
+		}
+	}
+
+	applicationRule, _ := ListFirewallRule(context.Background(), manager, "host", "sp", "web")
+	if len(applicationRule.Rules) != 1 {
+		t.Errorf("No rule should be created. Got %d rules", len(applicationRule.Rules))
+	}
+}
+
+func TestGetQuota(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerApplication: 10, MaxSourceRanges: 100}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8"}})
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "api", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16"}})
+	manager.CreateFirewallRule("host", &compute.Firewall{Name: "host-rule"})
+
+	report, err := GetQuota(context.Background(), manager, nil, policy, "host", "sp", "web")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := models.QuotaReport{
+		Project:                    "host",
+		ServiceProject:             "sp",
+		Application:                "web",
+		ApplicationRules:           models.QuotaUsage{Usage: 1, Limit: 10},
+		ServiceProjectRules:        models.QuotaUsage{Usage: 2, Limit: 0},
+		ServiceProjectSourceRanges: models.QuotaUsage{Usage: 3, Limit: 100},
+		ProjectRules:               models.QuotaUsage{Usage: 3, Limit: 500},
+		ProjectRemainingRules:      497,
+	}
+	if *report != expected {
+		t.Errorf("Got %+v want %+v", *report, expected)
+	}
+}
+
+func TestCheckQuotaSiblingServiceProjects(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerServiceProject: 1, MaxSourceRanges: 2}}
+	serviceProjects := &fakes.GoogleDummyClient{HostProjects: map[string]string{"sp": "host", "sp-a": "host", "other-sp": "other-host"}}
+	CreateFirewallRule(context.Background(), manager, serviceProjects, policy, "host", "sp-a", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8"}})
+
+	// Rules of service project sp-a are not the ones of sp
+	_, err := CreateFirewallRule(context.Background(), manager, serviceProjects, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16"}})
+	assertErrorCode(t, err, 0)
+
+	report, _ := GetQuota(context.Background(), manager, serviceProjects, policy, "host", "sp", "web")
+	if report.ServiceProjectRules.Usage != 1 || report.ServiceProjectSourceRanges.Usage != 2 {
+		t.Errorf("Unexpected usage. Got %+v", report)
+	}
+
+	// Without service projects, prefixes are matched
+	assertErrorCode(t, CheckQuota(context.Background(), manager, nil, policy, "host", "sp", "web"), http.StatusForbidden)
+}
+
+func TestUpdateFirewallRuleQuota(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerApplication: 1, MaxSourceRanges: 2}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8"}})
+
+	// The replaced rule is not counted
+	_, err := UpdateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16"}})
+	assertErrorCode(t, err, 0)
+
+	_, err = UpdateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{SourceRanges: []string{"10.0.0.0/8", "10.1.0.0/16", "10.2.0.0/16"}})
+	assertErrorCode(t, err, http.StatusForbidden)
+
+	rule, _ := manager.GetFirewallRule("host", "sp-web-https")
+	if len(rule.SourceRanges) != 2 {
+		t.Errorf("Rule should not be updated beyond quotas. Got %v", rule.SourceRanges)
+	}
+}
+
+func TestCheckQuotaApplicationsSharingPrefix(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerApplication: 1}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web-admin", "https", compute.Firewall{})
+
+	// Rule sp-web-admin-https of web-admin is not a rule of web
+	_, err := CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{})
+	assertErrorCode(t, err, 0)
+
+	report, _ := GetQuota(context.Background(), manager, nil, policy, "host", "sp", "web")
+	if report.ApplicationRules.Usage != 1 {
+		t.Errorf("Unexpected usage. Got %+v", report.ApplicationRules)
+	}
+}
+
+func TestRenameFirewallRuleQuota(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = nil
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{MaxRulesPerApplication: 1, MaxRulesPerServiceProject: 1}}
+	CreateFirewallRule(context.Background(), manager, nil, policy, "host", "sp", "web", "https", compute.Firewall{})
+
+	// The renamed rule is replaced by the new one
+	prepare := func(customName string, request *models.FirewallRuleRequest) error { return nil }
+	_, err := RenameFirewallRule(context.Background(), manager, newRenameInstanceDummyClient(), models.NewPriorityBandMemoryStore(), nil, policy, prepare, "host", "sp", "web", "https", models.RenameRequest{Name: "tls", GracePeriod: "24h"})
+	assertErrorCode(t, err, 0)
+}
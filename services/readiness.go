@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ReadinessCheck describe how to check a dependency. Check returns why the dependency is not ready, if so
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// ReadinessChecker runs readiness checks, in order, and keeps their result for a while not to flood dependencies
+// with probes
+type ReadinessChecker struct {
+	checks []ReadinessCheck
+	ttl    time.Duration
+
+	mutex     sync.Mutex
+	readiness *models.Readiness
+}
+
+// NewReadinessChecker ReadinessChecker constructor
+func NewReadinessChecker(ttl time.Duration, checks ...ReadinessCheck) *ReadinessChecker {
+	return &ReadinessChecker{checks: checks, ttl: ttl}
+}
+
+// Check returns the readiness of the API. Dependencies following a down one are not checked, as they
+// usually rely on it, and reported down
+func (c *ReadinessChecker) Check(ctx context.Context) models.Readiness {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.readiness != nil && time.Since(c.readiness.CheckedAt) < c.ttl {
+		return *c.readiness
+	}
+
+	readiness := models.Readiness{Status: models.ReadinessUp, CheckedAt: time.Now(), Dependencies: []models.DependencyStatus{}}
+	down := ""
+	for _, check := range c.checks {
+		status := models.DependencyStatus{Name: check.Name, Status: models.ReadinessUp}
+		if down != "" {
+			status.Status = models.ReadinessDown
+			status.Error = fmt.Sprintf("Not checked as [%s] is down", down)
+			readiness.Dependencies = append(readiness.Dependencies, status)
+			continue
+		}
+
+		err := check.Check(ctx)
+		if err != nil {
+			helpers.Logger(ctx).WithFields(logrus.Fields{
+				"go-err":     err.Error(),
+				"dependency": check.Name,
+			}).Warningln("Dependency is not ready")
+
+			down = check.Name
+			readiness.Status = models.ReadinessDown
+			status.Status = models.ReadinessDown
+			status.Error = err.Error()
+		}
+		readiness.Dependencies = append(readiness.Dependencies, status)
+	}
+
+	c.readiness = &readiness
+	return readiness
+}
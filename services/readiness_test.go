@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+)
+
+func TestReadinessChecker(t *testing.T) {
+	calls := 0
+	credentials := errors.New("Fail to mint a token")
+	var credentialsErr error
+	checker := NewReadinessChecker(time.Hour,
+		ReadinessCheck{Name: "clients", Check: func(ctx context.Context) error { calls++; return nil }},
+		ReadinessCheck{Name: "credentials", Check: func(ctx context.Context) error { return credentialsErr }},
+		ReadinessCheck{Name: "compute", Check: func(ctx context.Context) error { calls++; return nil }},
+	)
+
+	readiness := checker.Check(context.Background())
+	if readiness.Status != models.ReadinessUp || len(readiness.Dependencies) != 3 || calls != 2 {
+		t.Fatalf("Expected API to be up after checking all dependencies, got %+v", readiness)
+	}
+
+	// Cached
+	credentialsErr = credentials
+	readiness = checker.Check(context.Background())
+	if readiness.Status != models.ReadinessUp || calls != 2 {
+		t.Errorf("Expected cached readiness, got %+v after %d calls", readiness, calls)
+	}
+
+	// Degraded
+	checker.readiness.CheckedAt = time.Now().Add(-2 * time.Hour)
+	readiness = checker.Check(context.Background())
+	if readiness.Status != models.ReadinessDown {
+		t.Fatalf("Expected API to be down, got %+v", readiness)
+	}
+
+	expected := []string{models.ReadinessUp, models.ReadinessDown, models.ReadinessDown}
+	for i, dependency := range readiness.Dependencies {
+		if dependency.Status != expected[i] {
+			t.Errorf("Expected %s to be %s, got %s", dependency.Name, expected[i], dependency.Status)
+		}
+	}
+	if readiness.Dependencies[1].Error != credentials.Error() || calls != 3 {
+		t.Errorf("Expected compute not to be checked once credentials are down, got %+v after %d calls", readiness, calls)
+	}
+}
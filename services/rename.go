@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Marker appended to the description of a renamed rule until its rename is completed, naming the old application and rule
+const renameMarkerFormat = " [renamed from %s/%s, complete after %s]"
+
+var renameMarkerRegexp = regexp.MustCompile(` \[renamed from ([^/\]]+)/([^\]]+), complete after ([^\]]+)\]$`)
+
+// RenameFirewallRule renames the matching rule and/or moves it to another application of the same service project.
+// The new rule is prepared as on creation and created before the old one is removed, so instances keep their traffic:
+//   - with a grace period, the old rule is kept alongside the new one until the rename is completed, see CompleteRename
+//   - when asked, the new rule also targets the old tag until the rename is completed
+//   - otherwise, the old rule is deleted right away, provided no instance carries its tag
+//
+// Failures once the new rule is created are reported in the result
+func RenameFirewallRule(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, priorityBandStore models.PriorityBandStore, serviceProjects models.ServiceProjectLister, policy models.GuardrailPolicy, prepare PrepareRuleFunc, project, serviceProject, application, ruleName string, request models.RenameRequest) (*models.RenameResult, error) {
+	if request.Application == "" {
+		request.Application = application
+	}
+
+	if request.Name == "" {
+		request.Name = ruleName
+	}
+
+	if request.Application == application && request.Name == ruleName {
+		return nil, models.NewBadRequestError("New name is the same as the current one")
+	}
+
+	var gracePeriod time.Duration
+	if request.GracePeriod != "" {
+		var err error
+		gracePeriod, err = time.ParseDuration(request.GracePeriod)
+		if err != nil || gracePeriod < 0 {
+			return nil, models.NewBadRequestError(fmt.Sprintf("Invalid grace_period [%s]. Expected a positive duration, such as 24h", request.GracePeriod))
+		}
+	}
+
+	oldName := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
+	newName := fmt.Sprintf("%s-%s-%s", serviceProject, request.Application, request.Name)
+	fields := logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule_name":       oldName,
+		"new_rule_name":   newName,
+		"keep_old_tag":    request.KeepOldTag,
+		"grace_period":    gracePeriod.String(),
+	}
+	helpers.Logger(ctx).WithFields(fields).Debugln("Renaming rule")
+
+	current, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, _, pending := parseRenameMarker(current.Rules[0].Rule.Description); pending {
+		return nil, models.NewConflictError(fmt.Sprintf("Rule [%s] has a rename to complete first", oldName))
+	}
+
+	// The new rule is written as if created by the client, with a priority relative to the application band
+	priority, err := bandedPriority(priorityBandStore, project, serviceProject, application, current.Rules[0])
+	if err != nil {
+		return nil, err
+	}
+
+	ruleRequest := &models.FirewallRuleRequest{Firewall: cloneRule(current.Rules[0].Rule, project, project)}
+	ruleRequest.Priority = priority
+	err = prepare(request.Name, ruleRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without grace period nor old tag, the old rule is deleted right away, which instances carrying its tag only would lose
+	if gracePeriod == 0 && !request.KeepOldTag {
+		instances, err := listTaggedInstances(instanceManager, serviceProject, oldName)
+		if err != nil {
+			return nil, err
+		}
+		if len(instances) > 0 {
+			return nil, models.NewConflictError(fmt.Sprintf("%d instances carry the tag of [%s]. Retag them first, or give a grace_period or keep_old_tag", len(instances), oldName))
+		}
+	}
+
+	var targetTags []string
+	if request.KeepOldTag {
+		targetTags = append(targetTags, oldName)
+	}
+
+	// Until completed, the rename is recorded on the new rule
+	completeAfter := ""
+	if gracePeriod > 0 || request.KeepOldTag {
+		completeAfter = time.Now().Add(gracePeriod).UTC().Format(time.RFC3339)
+		ruleRequest.Description += fmt.Sprintf(renameMarkerFormat, application, ruleName, completeAfter)
+	}
+
+	// The old rule is replaced by the new one, so it is left out of quotas usage
+	err = checkQuota(ctx, manager, serviceProjects, policy, project, serviceProject, request.Application, oldName, &ruleRequest.Firewall)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := createFirewallRule(ctx, manager, project, serviceProject, request.Application, request.Name, ruleRequest.Firewall, targetTags...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RenameResult{
+		ApplicationRule: *created,
+		OldApplication:  application,
+		OldCustomName:   ruleName,
+		OldTargetTag:    oldName,
+		CompleteAfter:   completeAfter,
+	}
+
+	if gracePeriod == 0 {
+		err = manager.DeleteFirewallRule(project, oldName)
+		if err != nil {
+			helpers.Logger(ctx).WithFields(fields).Warningln("New rule created but old rule could not be deleted")
+			result.Error = renameError(ctx, err)
+		}
+		result.OldRuleDeleted = err == nil
+	}
+
+	// Report instances which still rely on the old tag
+	result.OldTargetTagInstances, err = listTaggedInstances(instanceManager, serviceProject, oldName)
+	if err != nil && result.Error == nil {
+		result.Error = renameError(ctx, err)
+	}
+
+	helpers.Logger(ctx).WithFields(fields).Debugf("Rule renamed, %d instances still carry the old tag", len(result.OldTargetTagInstances))
+	return result, nil
+}
+
+// CompleteRename ends the rename which created the matching rule: the old rule is deleted, and the old tag and rename record
+// are removed from the rule. It is rejected before the end of the grace period, unless no instance carries the old tag anymore.
+// The old rule is deleted first so that a failure can be retried. Failures once it is deleted are reported in the result
+func CompleteRename(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, project, serviceProject, application, ruleName string) (*models.RenameResult, error) {
+	current, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	oldApplication, oldRuleName, completeAfter, pending := parseRenameMarker(current.Rules[0].Rule.Description)
+	if !pending {
+		return nil, models.NewConflictError(fmt.Sprintf("Rule [%s] has no rename to complete", current.Rules[0].Rule.Name))
+	}
+
+	oldName := fmt.Sprintf("%s-%s-%s", serviceProject, oldApplication, oldRuleName)
+	fields := logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule_name":       current.Rules[0].Rule.Name,
+		"old_rule_name":   oldName,
+		"complete_after":  completeAfter,
+	}
+	helpers.Logger(ctx).WithFields(fields).Debugln("Completing rename")
+
+	instances, err := listTaggedInstances(instanceManager, serviceProject, oldName)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, err := time.Parse(time.RFC3339, completeAfter)
+	if err == nil && time.Now().Before(deadline) && len(instances) > 0 {
+		return nil, models.NewConflictError(fmt.Sprintf("Rename from [%s] can be completed after %s, %d instances still carry its tag", oldName, completeAfter, len(instances)))
+	}
+
+	// The old rule may already be gone, from a previous attempt or by hand
+	err = manager.DeleteFirewallRule(project, oldName)
+	if e, ok := err.(*models.ApplicationError); ok && e.Code == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.RenameResult{
+		ApplicationRule:       *current,
+		OldApplication:        oldApplication,
+		OldCustomName:         oldRuleName,
+		OldTargetTag:          oldName,
+		OldTargetTagInstances: instances,
+		OldRuleDeleted:        true,
+	}
+
+	completed, err := changeFirewallRule(ctx, manager, project, serviceProject, application, ruleName, func(rule *compute.Firewall) {
+		rule.Description = renameMarkerRegexp.ReplaceAllString(rule.Description, "")
+
+		targetTags := make([]string, 0, len(rule.TargetTags))
+		for _, tag := range rule.TargetTags {
+			if tag != oldName {
+				targetTags = append(targetTags, tag)
+			}
+		}
+		rule.TargetTags = targetTags
+	})
+	if err != nil {
+		helpers.Logger(ctx).WithFields(fields).Warningln("Old rule deleted but rename record could not be removed")
+		result.Error = renameError(ctx, err)
+		result.CompleteAfter = completeAfter
+		return result, nil
+	}
+
+	result.ApplicationRule = *completed
+	helpers.Logger(ctx).WithFields(fields).Debugf("Rename completed, %d instances still carry the old tag", len(instances))
+	return result, nil
+}
+
+// parseRenameMarker returns the rename recorded in given rule description, if any
+func parseRenameMarker(description string) (application, ruleName, completeAfter string, pending bool) {
+	match := renameMarkerRegexp.FindStringSubmatch(description)
+	if match == nil {
+		return "", "", "", false
+	}
+	return match[1], match[2], match[3], true
+}
+
+// listTaggedInstances returns zone/name of the instances of given service project carrying given tag
+func listTaggedInstances(instanceManager models.InstanceManager, serviceProject, tag string) ([]string, error) {
+	instances, err := instanceManager.ListInstancesWithTag(serviceProject, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		names = append(names, fmt.Sprintf("%s/%s", path.Base(instance.Zone), instance.Name))
+	}
+	return names, nil
+}
+
+// renameError returns given error of a rename step as an application error
+func renameError(ctx context.Context, err error) *models.ApplicationError {
+	if e, ok := err.(*models.ApplicationError); ok {
+		return e
+	}
+
+	helpers.Logger(ctx).WithField("go-err", err.Error()).Error("Unexpected error")
+	return models.NewInternalError()
+}
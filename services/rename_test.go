@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// newRenameInstanceDummyClient returns instances of service project "sp", one still carrying the tag of rule "https" of application "web"
+func newRenameInstanceDummyClient() *fakes.InstanceDummyClient {
+	return &fakes.InstanceDummyClient{Instances: map[string][]*compute.Instance{
+		"sp": []*compute.Instance{
+			&compute.Instance{Name: "web-1", Zone: "https://www.googleapis.com/compute/v1/projects/sp/zones/europe-west1-b", Tags: &compute.Tags{Items: []string{"sp-web-https"}}},
+			&compute.Instance{Name: "web-2", Zone: "https://www.googleapis.com/compute/v1/projects/sp/zones/europe-west1-c", Tags: &compute.Tags{Items: []string{"sp-front-https"}}},
+		},
+	}}
+}
+
+// renameFirewallRule renames rule "https" of application "web", preparing the new rule within the bands of given store
+func renameFirewallRule(manager models.FirewallRuleManager, instanceManager models.InstanceManager, priorityBandStore models.PriorityBandStore, request models.RenameRequest) (*models.RenameResult, error) {
+	newApplication := request.Application
+	if newApplication == "" {
+		newApplication = "web"
+	}
+	prepare := func(customName string, request *models.FirewallRuleRequest) error {
+		return ApplyPriorityBand(priorityBandStore, "host", "sp", newApplication, request)
+	}
+
+	return RenameFirewallRule(context.Background(), manager, instanceManager, priorityBandStore, nil, nil, prepare, "host", "sp", "web", "https", request)
+}
+
+func TestRenameFirewallRule(t *testing.T) {
+	tests := []struct {
+		Title          string
+		Request        models.RenameRequest
+		Expected       int
+		ExpectedNames  []string
+		ExpectedTags   int
+		ExpectedMarker bool
+		// Instances are retagged before the rename
+		Retagged bool
+	}{
+		{Title: "Same name", Request: models.RenameRequest{}, Expected: http.StatusBadRequest},
+		{Title: "Invalid grace period", Request: models.RenameRequest{Name: "tls", GracePeriod: "tomorrow"}, Expected: http.StatusBadRequest},
+		{Title: "Rename rule with tagged instances", Request: models.RenameRequest{Name: "tls"}, Expected: http.StatusConflict},
+		{Title: "Rename rule", Request: models.RenameRequest{Name: "tls"}, Retagged: true, ExpectedNames: []string{"sp-web-tls"}, ExpectedTags: 1},
+		{Title: "Move to application keeping old tag", Request: models.RenameRequest{Application: "front", KeepOldTag: true}, ExpectedNames: []string{"sp-front-https"}, ExpectedTags: 2, ExpectedMarker: true},
+		{Title: "Rename with grace period", Request: models.RenameRequest{Name: "tls", GracePeriod: "24h"}, ExpectedNames: []string{"sp-web-https", "sp-web-tls"}, ExpectedTags: 1, ExpectedMarker: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			manager, _ := fakes.NewFirewallRuleDummyClient()
+			manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Id: 1, Name: "sp-web-https", TargetTags: []string{"sp-web-https"}}}
+
+			instanceManager := newRenameInstanceDummyClient()
+			if test.Retagged {
+				instanceManager.Instances["sp"][0].Tags = &compute.Tags{Items: []string{"sp-web-tls"}}
+			}
+
+			renameResult, err := renameFirewallRule(manager, instanceManager, models.NewPriorityBandMemoryStore(), test.Request)
+			assertErrorCode(t, err, test.Expected)
+			if err != nil {
+				return
+			}
+
+			if len(manager.Rules["host"]) != len(test.ExpectedNames) {
+				t.Fatalf("Wrong rules after rename. Got %v want %v", manager.Rules["host"], test.ExpectedNames)
+			}
+			for i, name := range test.ExpectedNames {
+				if manager.Rules["host"][i].Name != name {
+					t.Fatalf("Wrong rules after rename. Got %v want %v", manager.Rules["host"], test.ExpectedNames)
+				}
+			}
+
+			rule := manager.Rules["host"][len(manager.Rules["host"])-1]
+			if len(rule.TargetTags) != test.ExpectedTags {
+				t.Errorf("Wrong target tags. Got %v", rule.TargetTags)
+			}
+
+			if (renameResult.CompleteAfter != "") != test.ExpectedMarker || strings.Contains(rule.Description, "renamed from web/https") != test.ExpectedMarker {
+				t.Errorf("Wrong rename record. Got %s, %q", renameResult.CompleteAfter, rule.Description)
+			}
+
+			if renameResult.OldRuleDeleted != (len(test.ExpectedNames) == 1) {
+				t.Errorf("Wrong old rule deletion. Got %v", renameResult.OldRuleDeleted)
+			}
+
+			if renameResult.OldTargetTag != "sp-web-https" {
+				t.Errorf("Wrong old target tag. Got %s", renameResult.OldTargetTag)
+			}
+
+			if test.Retagged {
+				if len(renameResult.OldTargetTagInstances) != 0 {
+					t.Errorf("Expected no instance to carry old tag. Got %v", renameResult.OldTargetTagInstances)
+				}
+			} else if len(renameResult.OldTargetTagInstances) != 1 || renameResult.OldTargetTagInstances[0] != "europe-west1-b/web-1" {
+				t.Errorf("Wrong instances still carrying old tag. Got %v", renameResult.OldTargetTagInstances)
+			}
+		})
+	}
+}
+
+func TestRenameFirewallRulePrepared(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Id: 1, Name: "sp-web-https", TargetTags: []string{"sp-web-https"}, Priority: 1010}}
+
+	priorityBandStore := models.NewPriorityBandMemoryStore()
+	priorityBandStore.SavePriorityBand("host", models.PriorityBand{Name: "web", Base: 1000, Default: true})
+	priorityBandStore.SavePriorityBand("host", models.PriorityBand{Name: "front", Base: 2000})
+	priorityBandStore.SaveAssignment(models.BandAssignment{Project: "host", ServiceProject: "sp", Application: "front", Band: "front"})
+
+	_, err := renameFirewallRule(manager, newRenameInstanceDummyClient(), priorityBandStore, models.RenameRequest{Application: "front", KeepOldTag: true})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if manager.Rules["host"][0].Priority != 2010 {
+		t.Errorf("Priority has not been moved to the new application band. Got %d", manager.Rules["host"][0].Priority)
+	}
+}
+
+func TestRenameFirewallRulePartialFailure(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Id: 1, Name: "sp-web-https", TargetTags: []string{"sp-web-https"}}}
+	manager.DeleteErrors = map[string]error{"sp-web-https": models.NewForbiddenError()}
+
+	instanceManager := newRenameInstanceDummyClient()
+	instanceManager.Instances["sp"][0].Tags = &compute.Tags{}
+
+	renameResult, err := renameFirewallRule(manager, instanceManager, models.NewPriorityBandMemoryStore(), models.RenameRequest{Name: "tls"})
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if renameResult.OldRuleDeleted || renameResult.Error == nil || renameResult.Error.Code != http.StatusForbidden {
+		t.Errorf("Expected old rule deletion failure to be reported. Got %+v", renameResult)
+	}
+
+	if len(manager.Rules["host"]) != 2 {
+		t.Errorf("Expected both rules to be kept. Got %v", manager.Rules["host"])
+	}
+}
+
+func TestCompleteRename(t *testing.T) {
+	tests := []struct {
+		Title         string
+		Request       models.RenameRequest
+		Untag         bool
+		Expected      int
+		ExpectedRules int
+	}{
+		{Title: "Within grace period", Request: models.RenameRequest{Name: "tls", GracePeriod: "24h"}, Expected: http.StatusConflict, ExpectedRules: 2},
+		{Title: "Within grace period without tagged instances", Request: models.RenameRequest{Name: "tls", GracePeriod: "24h"}, Untag: true, ExpectedRules: 1},
+		{Title: "After grace period", Request: models.RenameRequest{Name: "tls", GracePeriod: "1ns"}, ExpectedRules: 1},
+		{Title: "Keeping old tag", Request: models.RenameRequest{Name: "tls", KeepOldTag: true}, ExpectedRules: 1},
+		{Title: "Nothing to complete", Request: models.RenameRequest{Name: "tls"}, Untag: true, Expected: http.StatusConflict, ExpectedRules: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			manager, _ := fakes.NewFirewallRuleDummyClient()
+			manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Id: 1, Name: "sp-web-https", Description: "HTTPS", TargetTags: []string{"sp-web-https"}}}
+			instanceManager := newRenameInstanceDummyClient()
+			if test.Untag {
+				instanceManager.Instances["sp"][0].Tags = &compute.Tags{Items: []string{"sp-web-tls"}}
+			}
+
+			_, err := renameFirewallRule(manager, instanceManager, models.NewPriorityBandMemoryStore(), test.Request)
+			if err != nil {
+				t.Fatalf("Unexpected error. Got %v", err)
+			}
+
+			renameResult, err := CompleteRename(context.Background(), manager, instanceManager, "host", "sp", "web", "tls")
+			assertErrorCode(t, err, test.Expected)
+
+			if len(manager.Rules["host"]) != test.ExpectedRules {
+				t.Fatalf("Wrong rules after completion. Got %v", manager.Rules["host"])
+			}
+			if err != nil {
+				return
+			}
+
+			rule := manager.Rules["host"][0]
+			if rule.Name != "sp-web-tls" || rule.Description != "[application web] HTTPS" || len(rule.TargetTags) != 1 {
+				t.Errorf("Rename record has not been removed. Got %+v", rule)
+			}
+
+			if !renameResult.OldRuleDeleted || renameResult.OldApplication != "web" || renameResult.OldCustomName != "https" {
+				t.Errorf("Unexpected result. Got %+v", renameResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"math/big"
+	"net"
+	"sort"
+
+	"google.golang.org/api/compute/v1"
+)
+
+// Port interval standing for all ports, including traffic without port
+var allPorts = interval{from: big.NewInt(0), to: big.NewInt(65535)}
+
+// interval is an inclusive range of IP addresses or ports
+type interval struct {
+	from, to *big.Int
+}
+
+func (i interval) contains(o interval) bool {
+	return i.from.Cmp(o.from) <= 0 && i.to.Cmp(o.to) >= 0
+}
+
+func (i interval) intersects(o interval) bool {
+	return i.from.Cmp(o.to) <= 0 && o.from.Cmp(i.to) <= 0
+}
+
+// intervalSet is a union of intervals
+type intervalSet []interval
+
+// merged returns the set as sorted and disjoint intervals, adjacent ones being merged
+func (s intervalSet) merged() intervalSet {
+	sorted := make(intervalSet, len(s))
+	copy(sorted, s)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].from.Cmp(sorted[j].from) < 0 })
+
+	merged := make(intervalSet, 0, len(sorted))
+	for _, i := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && new(big.Int).Add(merged[last].to, big.NewInt(1)).Cmp(i.from) >= 0 {
+			if i.to.Cmp(merged[last].to) > 0 {
+				merged[last].to = i.to
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// covers returns whether all intervals of o are within the set
+func (s intervalSet) covers(o intervalSet) bool {
+	merged := s.merged()
+	for _, i := range o {
+		covered := false
+		for _, m := range merged {
+			if m.contains(i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func (s intervalSet) intersects(o intervalSet) bool {
+	for _, i := range s {
+		for _, j := range o {
+			if i.intersects(j) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ipInterval returns the addresses of given CIDR range or IP address, IPv4 ones being mapped into IPv6
+func ipInterval(r string) (interval, bool) {
+	if _, ipNet, err := net.ParseCIDR(r); err == nil {
+		from := ipNet.IP.To16()
+		to := make(net.IP, len(from))
+		mask := ipNet.Mask
+		if len(mask) == net.IPv4len {
+			mask = append(net.CIDRMask(96, 128)[:12], mask...)
+		}
+		for i := range from {
+			to[i] = from[i] | ^mask[i]
+		}
+		return interval{from: new(big.Int).SetBytes(from), to: new(big.Int).SetBytes(to)}, true
+	}
+
+	if ip := net.ParseIP(r); ip != nil {
+		n := new(big.Int).SetBytes(ip.To16())
+		return interval{from: n, to: n}, true
+	}
+	return interval{}, false
+}
+
+// addressSet describe the sources or destinations of a rule
+type addressSet struct {
+	all             bool
+	ranges          intervalSet
+	tags            []string
+	serviceAccounts []string
+}
+
+func newAddressSet(ranges, tags, serviceAccounts []string) addressSet {
+	set := addressSet{tags: tags, serviceAccounts: serviceAccounts}
+	if len(ranges) == 0 && len(tags) == 0 && len(serviceAccounts) == 0 {
+		set.all = true
+		return set
+	}
+
+	for _, r := range ranges {
+		if i, ok := ipInterval(r); ok {
+			set.ranges = append(set.ranges, i)
+		}
+	}
+	return set
+}
+
+// covers returns whether all addresses of o are within the set. Instances of the network are matched by
+// tags and service accounts, or by ranges covering all IPv4 addresses
+func (s addressSet) covers(o addressSet) bool {
+	if s.all {
+		return true
+	}
+	if o.all {
+		return false
+	}
+
+	allIPv4, _ := ipInterval("0.0.0.0/0")
+	coversInstances := s.ranges.covers(intervalSet{allIPv4})
+	return s.ranges.covers(o.ranges) &&
+		(coversInstances || isSubset(o.tags, s.tags)) &&
+		(coversInstances || isSubset(o.serviceAccounts, s.serviceAccounts))
+}
+
+// intersects returns whether both sets may share an address
+func (s addressSet) intersects(o addressSet) bool {
+	return s.all || o.all ||
+		s.ranges.intersects(o.ranges) ||
+		intersects(s.tags, o.tags) ||
+		intersects(s.serviceAccounts, o.serviceAccounts)
+}
+
+// targetSet describe the instances a rule applies to
+type targetSet struct {
+	tags            []string
+	serviceAccounts []string
+}
+
+func (s targetSet) all() bool {
+	return len(s.tags) == 0 && len(s.serviceAccounts) == 0
+}
+
+func (s targetSet) covers(o targetSet) bool {
+	if s.all() {
+		return true
+	}
+	if o.all() {
+		return false
+	}
+	return len(o.tags) > 0 && isSubset(o.tags, s.tags) || len(o.serviceAccounts) > 0 && isSubset(o.serviceAccounts, s.serviceAccounts)
+}
+
+// intersects returns whether both sets may share an instance. An instance may have both a tag and a service account
+func (s targetSet) intersects(o targetSet) bool {
+	if s.all() || o.all() {
+		return true
+	}
+	if len(s.tags) > 0 && len(o.tags) > 0 {
+		return intersects(s.tags, o.tags)
+	}
+	if len(s.serviceAccounts) > 0 && len(o.serviceAccounts) > 0 {
+		return intersects(s.serviceAccounts, o.serviceAccounts)
+	}
+	return true
+}
+
+// portSet describe the ports of a rule by protocol number, "all" standing for all protocols
+type portSet map[string]intervalSet
+
+func newPortSet(rule *compute.Firewall) portSet {
+	set := portSet{}
+	add := func(protocol string, ports []string) {
+		number, ok := normalizeProtocol(protocol)
+		if !ok {
+			return
+		}
+
+		if len(ports) == 0 {
+			set[number] = append(set[number], allPorts)
+			return
+		}
+
+		for _, p := range ports {
+			from, to, err := parsePortRange(p)
+			if err == nil {
+				set[number] = append(set[number], interval{from: big.NewInt(int64(from)), to: big.NewInt(int64(to))})
+			}
+		}
+	}
+
+	for _, a := range rule.Allowed {
+		add(a.IPProtocol, a.Ports)
+	}
+	for _, d := range rule.Denied {
+		add(d.IPProtocol, d.Ports)
+	}
+	return set
+}
+
+func (s portSet) covers(o portSet) bool {
+	if _, ok := s["all"]; ok {
+		return true
+	}
+
+	for protocol, ports := range o {
+		if protocol == "all" || !s[protocol].covers(ports) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s portSet) intersects(o portSet) bool {
+	for protocol, ports := range s {
+		for otherProtocol, otherPorts := range o {
+			if protocol == "all" || otherProtocol == "all" {
+				return true
+			}
+			if protocol == otherProtocol && ports.intersects(otherPorts) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSubset returns whether all values of a are in b
+func isSubset(a, b []string) bool {
+	for _, v := range a {
+		if !containsString(b, v) {
+			return false
+		}
+	}
+	return true
+}
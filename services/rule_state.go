@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// SetFirewallRuleDisabled disables or enables back the matching rule, keeping it as is otherwise
+func SetFirewallRuleDisabled(ctx context.Context, manager models.FirewallRuleManager, policy models.GuardrailPolicy, project, serviceProject, application, ruleName string, disabled bool) (*models.ApplicationRule, error) {
+	if disabled && policy.For(project).ForbidDisable {
+		return nil, models.NewForbiddenError(fmt.Sprintf("Disabling rules is forbidden by [%s] policy", project))
+	}
+
+	return changeFirewallRule(ctx, manager, project, serviceProject, application, ruleName, func(rule *compute.Firewall) {
+		rule.Disabled = disabled
+	})
+}
+
+// SetFirewallRuleLogging switches logging of the matching rule, keeping it as is otherwise
+func SetFirewallRuleLogging(ctx context.Context, manager models.FirewallRuleManager, policy models.GuardrailPolicy, project, serviceProject, application, ruleName string, logging models.LoggingRequest) (*models.ApplicationRule, error) {
+	err := validateLogging(logging)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkLoggingGuardrails(policy, project, logging)
+	if err != nil {
+		return nil, err
+	}
+
+	// Forced metadata apply when none is given
+	if logging.Enable && logging.Metadata == "" {
+		logging.Metadata = policy.For(project).LoggingMetadata
+	}
+
+	return changeFirewallRule(ctx, manager, project, serviceProject, application, ruleName, func(rule *compute.Firewall) {
+		rule.LogConfig = &compute.FirewallLogConfig{Enable: logging.Enable, Metadata: logging.Metadata}
+	})
+}
+
+// changeFirewallRule applies given change to the matching rule. Unlike UpdateFirewallRule, target tags are kept
+func changeFirewallRule(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject, application, ruleName string, change func(*compute.Firewall)) (*models.ApplicationRule, error) {
+	current, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := current.Rules[0].Rule
+	change(&rule)
+
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"rule_name":       rule.Name,
+		"disabled":        rule.Disabled,
+		"logging":         rule.LogConfig != nil && rule.LogConfig.Enable,
+	}).Debugln("Changing rule")
+
+	gRule, err := manager.UpdateFirewallRule(project, &rule)
+	if err != nil {
+		return nil, err
+	}
+
+	current.Rules[0].Rule = *gRule
+	return current, nil
+}
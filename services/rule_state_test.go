@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestSetFirewallRuleDisabled(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	policy := models.GuardrailPolicy{"locked": models.ProjectGuardrails{ForbidDisable: true}}
+
+	// Old target tags are kept
+	rule := compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https", "sp-web-old"}}
+	manager.CreateFirewallRule("host", &rule)
+	manager.CreateFirewallRule("locked", &rule)
+
+	applicationRule, err := SetFirewallRuleDisabled(context.Background(), manager, policy, "host", "sp", "web", "https", true)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	got := applicationRule.Rules[0]
+	if !got.Rule.Disabled || got.CustomName != "https" || len(got.Rule.TargetTags) != 2 {
+		t.Errorf("Rule should be disabled as is. Got %+v", got)
+	}
+
+	applicationRule, err = SetFirewallRuleDisabled(context.Background(), manager, policy, "host", "sp", "web", "https", false)
+	if err != nil || applicationRule.Rules[0].Rule.Disabled {
+		t.Errorf("Rule should be enabled. Got %+v, %v", applicationRule, err)
+	}
+
+	_, err = SetFirewallRuleDisabled(context.Background(), manager, policy, "locked", "sp", "web", "https", true)
+	assertErrorCode(t, err, http.StatusForbidden)
+
+	_, err = SetFirewallRuleDisabled(context.Background(), manager, policy, "locked", "sp", "web", "https", false)
+	assertErrorCode(t, err, 0)
+
+	_, err = SetFirewallRuleDisabled(context.Background(), manager, policy, "host", "sp", "web", "missing", true)
+	assertErrorCode(t, err, http.StatusNotFound)
+}
+
+func TestSetFirewallRuleLogging(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	policy := models.GuardrailPolicy{
+		"*":      models.ProjectGuardrails{ForceLogging: true, LoggingMetadata: models.LoggingExcludeAllMetadata},
+		"host":   models.ProjectGuardrails{},
+		"logged": models.ProjectGuardrails{ForceLogging: true},
+	}
+	for _, project := range []string{"host", "logged", "other"} {
+		manager.CreateFirewallRule(project, &compute.Firewall{Name: "sp-web-https"})
+	}
+
+	tests := []struct {
+		Title    string
+		Project  string
+		Logging  models.LoggingRequest
+		Expected int
+		Metadata string
+	}{
+		{Title: "Enable", Project: "host", Logging: models.LoggingRequest{Enable: true}},
+		{Title: "Enable with metadata", Project: "host", Logging: models.LoggingRequest{Enable: true, Metadata: models.LoggingExcludeAllMetadata}, Metadata: models.LoggingExcludeAllMetadata},
+		{Title: "Disable", Project: "host", Logging: models.LoggingRequest{}},
+		{Title: "Invalid metadata", Project: "host", Logging: models.LoggingRequest{Enable: true, Metadata: "SOME"}, Expected: http.StatusBadRequest},
+		{Title: "Metadata without logging", Project: "host", Logging: models.LoggingRequest{Metadata: models.LoggingIncludeAllMetadata}, Expected: http.StatusBadRequest},
+		{Title: "Disable forced logging", Project: "logged", Logging: models.LoggingRequest{}, Expected: http.StatusForbidden},
+		{Title: "Enable forced logging", Project: "logged", Logging: models.LoggingRequest{Enable: true, Metadata: models.LoggingIncludeAllMetadata}, Metadata: models.LoggingIncludeAllMetadata},
+		{Title: "Default forced metadata", Project: "other", Logging: models.LoggingRequest{Enable: true}, Metadata: models.LoggingExcludeAllMetadata},
+		{Title: "Other than forced metadata", Project: "other", Logging: models.LoggingRequest{Enable: true, Metadata: models.LoggingIncludeAllMetadata}, Expected: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			applicationRule, err := SetFirewallRuleLogging(context.Background(), manager, policy, test.Project, "sp", "web", "https", test.Logging)
+			assertErrorCode(t, err, test.Expected)
+			if err != nil {
+				return
+			}
+
+			logConfig := applicationRule.Rules[0].Rule.LogConfig
+			if logConfig.Enable != test.Logging.Enable || logConfig.Metadata != test.Metadata {
+				t.Errorf("Unexpected log config. Got %+v", logConfig)
+			}
+		})
+	}
+}
+
+func TestApplyGuardrails(t *testing.T) {
+	policy := models.GuardrailPolicy{"host": models.ProjectGuardrails{ForceLogging: true, LoggingMetadata: models.LoggingIncludeAllMetadata, ForbidDisable: true}}
+
+	rule := compute.Firewall{}
+	assertErrorCode(t, ApplyGuardrails(policy, "host", &rule), 0)
+	if rule.LogConfig == nil || !rule.LogConfig.Enable || rule.LogConfig.Metadata != models.LoggingIncludeAllMetadata {
+		t.Errorf("Logging should be forced. Got %+v", rule.LogConfig)
+	}
+
+	rule = compute.Firewall{Disabled: true}
+	assertErrorCode(t, ApplyGuardrails(policy, "host", &rule), http.StatusForbidden)
+
+	rule = compute.Firewall{Disabled: true}
+	assertErrorCode(t, ApplyGuardrails(policy, "other", &rule), 0)
+	if rule.LogConfig != nil {
+		t.Errorf("Rule should be kept as is. Got %+v", rule.LogConfig)
+	}
+
+	rule = compute.Firewall{LogConfig: &compute.FirewallLogConfig{Metadata: models.LoggingIncludeAllMetadata}}
+	assertErrorCode(t, ApplyGuardrails(policy, "other", &rule), http.StatusBadRequest)
+}
+
+func TestLoadGuardrailPolicy(t *testing.T) {
+	policy, err := LoadGuardrailPolicy("")
+	if err != nil || len(policy) != 0 {
+		t.Fatalf("Expected empty policy. Got %v, %v", policy, err)
+	}
+
+	f, err := ioutil.TempFile("", "guardrails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"*": {"force_logging": true}, "host": {"forbid_disable": true, "logging_metadata": "EXCLUDE_ALL_METADATA"}}`)
+	f.Close()
+
+	policy, err = LoadGuardrailPolicy(f.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if !policy.For("other").ForceLogging || policy.For("host").ForceLogging || !policy.For("host").ForbidDisable {
+		t.Errorf("Unexpected policy. Got %+v", policy)
+	}
+
+	// YAML policies
+	ioutil.WriteFile(f.Name(), []byte("\"*\":\n  max_source_ranges: 10\nhost:\n  force_logging: true\n  logging_metadata: EXCLUDE_ALL_METADATA\n"), 0600)
+	policy, err = LoadGuardrailPolicy(f.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if policy.For("other").MaxSourceRanges != 10 || !policy.For("host").ForceLogging || policy.For("host").LoggingMetadata != models.LoggingExcludeAllMetadata {
+		t.Errorf("Unexpected policy. Got %+v", policy)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte(`{"host": {"logging_metadata": "SOME"}}`), 0600)
+	_, err = LoadGuardrailPolicy(f.Name())
+	if err == nil {
+		t.Errorf("Expected error on invalid metadata")
+	}
+}
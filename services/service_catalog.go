@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// Protocols accepted by Google rules by name, with their IANA number
+// https://cloud.google.com/compute/docs/reference/rest/v1/firewalls
+var protocolNumbers = map[string]string{
+	"icmp": "1",
+	"ipip": "4",
+	"tcp":  "6",
+	"udp":  "17",
+	"esp":  "50",
+	"ah":   "51",
+	"sctp": "132",
+	"all":  "all",
+}
+
+// Protocols accepting ports, by number
+var portProtocols = map[string]bool{
+	"6":   true,
+	"17":  true,
+	"132": true,
+}
+
+// LoadServiceCatalog returns default services, completed or overridden by the services of the given JSON or YAML file, if any
+func LoadServiceCatalog(path string) (models.ServiceCatalog, error) {
+	catalog := models.DefaultServices()
+	if path == "" {
+		return catalog, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var custom []models.Service
+	err = unmarshalJSONOrYAML(data, &custom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service catalog %s: %v", path, err)
+	}
+
+	for _, s := range custom {
+		if s.Name == "" || len(s.Ports) == 0 {
+			return nil, fmt.Errorf("invalid service catalog %s: services must have a name and ports", path)
+		}
+
+		for _, p := range s.Ports {
+			err := ValidatePorts(p.IPProtocol, p.Ports)
+			if err != nil {
+				return nil, fmt.Errorf("invalid service catalog %s: service %s: %v", path, s.Name, err)
+			}
+		}
+		catalog[s.Name] = s
+	}
+
+	logrus.Debugf("Loaded %d custom services from %s", len(custom), path)
+	return catalog, nil
+}
+
+// ExpandServices adds protocols and ports of allowed services referenced by given rule request to its allowed entries,
+// and the ones of denied services to its denied entries. Then validates all entries
+func ExpandServices(catalog models.ServiceCatalog, request *models.FirewallRuleRequest) error {
+	for _, name := range request.Services {
+		s, err := lookupService(catalog, name)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range s.Ports {
+			request.Allowed = append(request.Allowed, &compute.FirewallAllowed{IPProtocol: p.IPProtocol, Ports: p.Ports})
+		}
+	}
+
+	for _, name := range request.DeniedServices {
+		s, err := lookupService(catalog, name)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range s.Ports {
+			request.Denied = append(request.Denied, &compute.FirewallDenied{IPProtocol: p.IPProtocol, Ports: p.Ports})
+		}
+	}
+
+	if len(request.Allowed) > 0 && len(request.Denied) > 0 {
+		return models.NewBadRequestError("A rule either allows or denies traffic. Got both allowed and denied entries")
+	}
+
+	for _, a := range request.Allowed {
+		err := ValidatePorts(a.IPProtocol, a.Ports)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, d := range request.Denied {
+		err := ValidatePorts(d.IPProtocol, d.Ports)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupService returns the service of given name from the catalog
+func lookupService(catalog models.ServiceCatalog, name string) (models.Service, error) {
+	s, ok := catalog[name]
+	if !ok {
+		return models.Service{}, models.NewBadRequestError(fmt.Sprintf("Unknown service [%s]. See GET /services", name))
+	}
+	return s, nil
+}
+
+// ValidatePorts ensures given protocol is known and given ports are single ports or ranges between 1 and 65535
+func ValidatePorts(protocol string, ports []string) error {
+	number, ok := normalizeProtocol(protocol)
+	if !ok {
+		return models.NewBadRequestError(fmt.Sprintf("Invalid protocol [%s]", protocol))
+	}
+
+	if len(ports) > 0 && !portProtocols[number] {
+		return models.NewBadRequestError(fmt.Sprintf("Ports are only supported for tcp, udp and sctp protocols. Got [%s]", protocol))
+	}
+
+	for _, p := range ports {
+		_, _, err := parsePortRange(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeProtocol returns the number of the given protocol name or number, "all" for all protocols.
+// Returns false for unknown protocols
+func normalizeProtocol(protocol string) (string, bool) {
+	protocol = strings.ToLower(protocol)
+	if number, ok := protocolNumbers[protocol]; ok {
+		return number, true
+	}
+
+	n, err := parseNumber(protocol)
+	if err != nil || n > 255 {
+		return "", false
+	}
+	return strconv.Itoa(n), true
+}
+
+// parsePortRange returns the bounds of a single port or a port range such as 8000-8080
+func parsePortRange(p string) (from, to int, err error) {
+	invalid := models.NewBadRequestError(fmt.Sprintf("Invalid port [%s]. Expected a port or a range between 1 and 65535", p))
+
+	bounds := strings.SplitN(p, "-", 2)
+	from, err = parseNumber(bounds[0])
+	if err != nil {
+		return 0, 0, invalid
+	}
+
+	to = from
+	if len(bounds) == 2 {
+		to, err = parseNumber(bounds[1])
+		if err != nil {
+			return 0, 0, invalid
+		}
+	}
+
+	if from < 1 || to > 65535 || from > to {
+		return 0, 0, invalid
+	}
+
+	return from, to, nil
+}
+
+// parseNumber parses a decimal number made of digits only, unlike strconv.Atoi which accepts signs
+func parseNumber(s string) (int, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid number [%s]", s)
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		Title    string
+		Protocol string
+		Ports    []string
+		Expected int
+	}{
+		{Title: "Single port", Protocol: "tcp", Ports: []string{"443"}},
+		{Title: "Upper case protocol", Protocol: "TCP", Ports: []string{"22", "3389"}},
+		{Title: "Range", Protocol: "udp", Ports: []string{"8000-8080"}},
+		{Title: "No port", Protocol: "icmp"},
+		{Title: "Protocol number", Protocol: "6", Ports: []string{"443"}},
+		{Title: "Unknown protocol", Protocol: "foo", Expected: http.StatusBadRequest},
+		{Title: "Protocol number out of range", Protocol: "256", Expected: http.StatusBadRequest},
+		{Title: "Ports on icmp", Protocol: "icmp", Ports: []string{"8"}, Expected: http.StatusBadRequest},
+		{Title: "Port 0", Protocol: "tcp", Ports: []string{"0"}, Expected: http.StatusBadRequest},
+		{Title: "Port too high", Protocol: "tcp", Ports: []string{"65536"}, Expected: http.StatusBadRequest},
+		{Title: "Reversed range", Protocol: "tcp", Ports: []string{"8080-8000"}, Expected: http.StatusBadRequest},
+		{Title: "Not a number", Protocol: "tcp", Ports: []string{"https"}, Expected: http.StatusBadRequest},
+		{Title: "Open range", Protocol: "tcp", Ports: []string{"8000-"}, Expected: http.StatusBadRequest},
+		{Title: "Signed port", Protocol: "tcp", Ports: []string{"+80"}, Expected: http.StatusBadRequest},
+		{Title: "Negative zero", Protocol: "tcp", Ports: []string{"-0"}, Expected: http.StatusBadRequest},
+		{Title: "Signed range bound", Protocol: "tcp", Ports: []string{"80-+90"}, Expected: http.StatusBadRequest},
+		{Title: "Signed protocol number", Protocol: "+6", Expected: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			assertErrorCode(t, ValidatePorts(test.Protocol, test.Ports), test.Expected)
+		})
+	}
+}
+
+func TestExpandServices(t *testing.T) {
+	catalog := models.DefaultServices()
+
+	request := models.FirewallRuleRequest{
+		Firewall: compute.Firewall{Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"8443"}}}},
+		Services: []string{"https", "dns"},
+	}
+	err := ExpandServices(catalog, &request)
+	assertErrorCode(t, err, 0)
+	if len(request.Allowed) != 4 || request.Allowed[1].Ports[0] != "443" || request.Allowed[3].IPProtocol != "udp" {
+		t.Errorf("Unexpected allowed entries. Got %d entries", len(request.Allowed))
+	}
+
+	// Deny rules
+	request = models.FirewallRuleRequest{
+		Firewall:       compute.Firewall{Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "icmp"}}},
+		DeniedServices: []string{"ssh"},
+	}
+	err = ExpandServices(catalog, &request)
+	assertErrorCode(t, err, 0)
+	if len(request.Denied) != 2 || len(request.Allowed) != 0 {
+		t.Errorf("Services should be denied. Got %d denied entries", len(request.Denied))
+	}
+
+	// Denied services only
+	request = models.FirewallRuleRequest{DeniedServices: []string{"ssh"}}
+	err = ExpandServices(catalog, &request)
+	assertErrorCode(t, err, 0)
+	if len(request.Denied) != 1 || len(request.Allowed) != 0 {
+		t.Errorf("Services should be denied. Got %d denied entries", len(request.Denied))
+	}
+
+	// Allowed services are not turned into denied entries
+	request = models.FirewallRuleRequest{
+		Firewall: compute.Firewall{Denied: []*compute.FirewallDenied{&compute.FirewallDenied{IPProtocol: "icmp"}}},
+		Services: []string{"ssh"},
+	}
+	assertErrorCode(t, ExpandServices(catalog, &request), http.StatusBadRequest)
+
+	// Unknown service
+	request = models.FirewallRuleRequest{Services: []string{"foo"}}
+	assertErrorCode(t, ExpandServices(catalog, &request), http.StatusBadRequest)
+
+	// Given entries are validated too
+	request = models.FirewallRuleRequest{Firewall: compute.Firewall{Allowed: []*compute.FirewallAllowed{&compute.FirewallAllowed{IPProtocol: "tcp", Ports: []string{"70000"}}}}}
+	assertErrorCode(t, ExpandServices(catalog, &request), http.StatusBadRequest)
+}
+
+func TestLoadServiceCatalog(t *testing.T) {
+	catalog, err := LoadServiceCatalog("")
+	if err != nil || len(catalog) != len(models.DefaultServices()) {
+		t.Fatalf("Expected default catalog. Got %v, %v", catalog, err)
+	}
+
+	f, err := ioutil.TempFile("", "services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`[{"name": "app", "ports": [{"IPProtocol": "tcp", "ports": ["8000-8080"]}]}, {"name": "https", "ports": [{"IPProtocol": "tcp", "ports": ["443", "8443"]}]}]`)
+	f.Close()
+
+	catalog, err = LoadServiceCatalog(f.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if _, ok := catalog["app"]; !ok {
+		t.Errorf("Custom service should be added")
+	}
+
+	if len(catalog["https"].Ports[0].Ports) != 2 {
+		t.Errorf("Custom service should override default one. Got %v", catalog["https"])
+	}
+
+	// YAML catalogs
+	ioutil.WriteFile(f.Name(), []byte("- name: app\n  ports:\n    - IPProtocol: udp\n      ports: [\"9000\"]\n"), 0600)
+	catalog, err = LoadServiceCatalog(f.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	if catalog["app"].Ports[0].IPProtocol != "udp" || catalog["app"].Ports[0].Ports[0] != "9000" {
+		t.Errorf("Unexpected YAML service. Got %+v", catalog["app"])
+	}
+
+	ioutil.WriteFile(f.Name(), []byte(`[{"name": "app", "ports": [{"IPProtocol": "tcp", "ports": ["0"]}]}]`), 0600)
+	_, err = LoadServiceCatalog(f.Name())
+	if err == nil {
+		t.Errorf("Expected error on invalid port")
+	}
+
+	_, err = LoadServiceCatalog(f.Name() + ".missing")
+	if err == nil {
+		t.Errorf("Expected error on missing file")
+	}
+}
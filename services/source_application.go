@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+)
+
+// ExpandSourceApplications adds target tags of source applications referenced by given rule request to its source tags.
+// Returns the dependency to track once the rule is written, nil when no application is referenced
+func ExpandSourceApplications(ctx context.Context, manager models.FirewallRuleManager, project, serviceProject string, request *models.FirewallRuleRequest) (*models.ApplicationDependency, error) {
+	if len(request.SourceApplications) == 0 {
+		return nil, nil
+	}
+
+	dependency := &models.ApplicationDependency{SourceApplications: make([]models.SourceApplication, len(request.SourceApplications))}
+	seen := make(map[string]bool)
+	for _, tag := range request.SourceTags {
+		seen[tag] = true
+	}
+
+	for i, source := range request.SourceApplications {
+		if source.Application == "" {
+			return nil, models.NewBadRequestError("Source applications must have an application")
+		}
+
+		if source.ServiceProject == "" {
+			source.ServiceProject = serviceProject
+		}
+		dependency.SourceApplications[i] = source
+
+		tags, err := sourceApplicationTags(ctx, manager, project, source)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			if !seen[tag] {
+				seen[tag] = true
+				request.SourceTags = append(request.SourceTags, tag)
+			}
+		}
+	}
+
+	return dependency, nil
+}
+
+// TrackSourceApplications records the source applications of a written rule, so that deleting them warns about it.
+// A nil dependency forgets them
+func TrackSourceApplications(store models.DependencyStore, project, serviceProject, application, customName string, dependency *models.ApplicationDependency) error {
+	if dependency == nil {
+		return store.DeleteDependency(project, serviceProject, application, customName)
+	}
+
+	dependency.ServiceProject = serviceProject
+	dependency.Application = application
+	dependency.CustomName = customName
+	return store.SaveDependency(project, *dependency)
+}
+
+// CopySourceApplications makes a renamed rule depend on the source applications of its old name too
+func CopySourceApplications(store models.DependencyStore, project, serviceProject, application, customName, newApplication, newCustomName string) error {
+	dependency, err := store.GetDependency(project, serviceProject, application, customName)
+	if err != nil || dependency == nil {
+		return err
+	}
+
+	return TrackSourceApplications(store, project, serviceProject, newApplication, newCustomName, dependency)
+}
+
+// ListDependentRules returns names of rules of other applications allowing traffic from given application.
+// When a rule is given, only rules depending on it are returned
+func ListDependentRules(store models.DependencyStore, project, serviceProject, application, ruleName string) ([]string, error) {
+	dependencies, err := store.ListDependencies(project, serviceProject, application)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, dependency := range dependencies {
+		if dependency.ServiceProject == serviceProject && dependency.Application == application {
+			continue
+		}
+
+		for _, source := range dependency.SourceApplications {
+			if source.ServiceProject == serviceProject && source.Application == application && (ruleName == "" || source.Rule == "" || source.Rule == ruleName) {
+				names = append(names, fmt.Sprintf("%s-%s-%s", dependency.ServiceProject, dependency.Application, dependency.CustomName))
+				break
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// sourceApplicationTags returns the target tags of the given source application rules
+func sourceApplicationTags(ctx context.Context, manager models.FirewallRuleManager, project string, source models.SourceApplication) ([]string, error) {
+	applicationRule, err := ListFirewallRule(ctx, manager, project, source.ServiceProject, source.Application)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(applicationRule.Rules))
+	for _, rule := range applicationRule.Rules {
+		if source.Rule == "" || rule.CustomName == source.Rule {
+			tags = append(tags, rule.Rule.Name)
+		}
+	}
+
+	if len(tags) > 0 {
+		return tags, nil
+	}
+
+	if source.Rule != "" {
+		return nil, models.NewBadRequestError(fmt.Sprintf("Source application [%s] has no rule [%s] in service project [%s]", source.Application, source.Rule, source.ServiceProject))
+	}
+	return nil, models.NewBadRequestError(fmt.Sprintf("Source application [%s] has no rules in service project [%s]", source.Application, source.ServiceProject))
+}
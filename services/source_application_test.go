@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestExpandSourceApplications(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "api", "https", compute.Firewall{})
+	CreateFirewallRule(context.Background(), manager, nil, nil, "host", "sp", "api", "grpc", compute.Firewall{})
+	CreateFirewallRule(context.Background(), manager, nil, nil, "host", "other-sp", "db", "mysql", compute.Firewall{})
+
+	// Without source application, rule is kept as is
+	request := models.FirewallRuleRequest{Firewall: compute.Firewall{SourceTags: []string{"bastion"}}}
+	dependency, err := ExpandSourceApplications(context.Background(), manager, "host", "sp", &request)
+	if err != nil || dependency != nil {
+		t.Fatalf("Unexpected result. Got %v, %v", dependency, err)
+	}
+
+	request = models.FirewallRuleRequest{
+		Firewall:           compute.Firewall{SourceTags: []string{"bastion", "sp-api-https"}},
+		SourceApplications: []models.SourceApplication{{Application: "api"}, {ServiceProject: "other-sp", Application: "db", Rule: "mysql"}},
+	}
+	dependency, err = ExpandSourceApplications(context.Background(), manager, "host", "sp", &request)
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+
+	expected := []string{"bastion", "sp-api-https", "sp-api-grpc", "other-sp-db-mysql"}
+	if !reflect.DeepEqual(request.SourceTags, expected) {
+		t.Errorf("Unexpected source tags. Got %v want %v", request.SourceTags, expected)
+	}
+
+	// Service project defaults to the rule's one
+	if dependency.SourceApplications[0].ServiceProject != "sp" {
+		t.Errorf("Unexpected dependency. Got %+v", dependency)
+	}
+
+	tests := []struct {
+		Title  string
+		Source models.SourceApplication
+	}{
+		{Title: "Missing application", Source: models.SourceApplication{}},
+		{Title: "Unknown application", Source: models.SourceApplication{Application: "front"}},
+		{Title: "Application of another service project", Source: models.SourceApplication{Application: "db"}},
+		{Title: "Unknown rule", Source: models.SourceApplication{Application: "api", Rule: "ssh"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			request := models.FirewallRuleRequest{SourceApplications: []models.SourceApplication{test.Source}}
+			_, err := ExpandSourceApplications(context.Background(), manager, "host", "sp", &request)
+			assertErrorCode(t, err, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestListDependentRules(t *testing.T) {
+	store := models.NewDependencyMemoryStore()
+	TrackSourceApplications(store, "host", "sp", "web", "to-api", &models.ApplicationDependency{SourceApplications: []models.SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+	TrackSourceApplications(store, "host", "sp", "batch", "to-api", &models.ApplicationDependency{SourceApplications: []models.SourceApplication{{ServiceProject: "sp", Application: "api", Rule: "grpc"}}})
+	TrackSourceApplications(store, "host", "sp", "api", "internal", &models.ApplicationDependency{SourceApplications: []models.SourceApplication{{ServiceProject: "sp", Application: "api"}}})
+
+	tests := []struct {
+		Title    string
+		Rule     string
+		Expected []string
+	}{
+		{Title: "Whole application", Expected: []string{"sp-batch-to-api", "sp-web-to-api"}},
+		{Title: "Referenced rule", Rule: "grpc", Expected: []string{"sp-batch-to-api", "sp-web-to-api"}},
+		{Title: "Other rule", Rule: "https", Expected: []string{"sp-web-to-api"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			names, err := ListDependentRules(store, "host", "sp", "api", test.Rule)
+			if err != nil || !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("Unexpected dependent rules. Got %v, %v want %v", names, err, test.Expected)
+			}
+		})
+	}
+
+	// Moved rules keep their dependency
+	CopySourceApplications(store, "host", "sp", "web", "to-api", "front", "to-api")
+	TrackSourceApplications(store, "host", "sp", "web", "to-api", nil)
+	names, _ := ListDependentRules(store, "host", "sp", "api", "")
+	if !reflect.DeepEqual(names, []string{"sp-batch-to-api", "sp-front-to-api"}) {
+		t.Errorf("Unexpected dependent rules after move. Got %v", names)
+	}
+
+	TrackSourceApplications(store, "host", "sp", "batch", "to-api", nil)
+	names, _ = ListDependentRules(store, "host", "sp", "api", "")
+	if !reflect.DeepEqual(names, []string{"sp-front-to-api"}) {
+		t.Errorf("Unexpected dependent rules after untrack. Got %v", names)
+	}
+}
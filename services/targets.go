@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/adeo/iwc-gcp-firewall-api/helpers"
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/compute/v1"
+)
+
+// ListRuleTargets returns instances of the service project carrying the matching rule's target tag
+func ListRuleTargets(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, project, serviceProject, application, ruleName string) (*models.RuleTargets, error) {
+	tag := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"target_tag":      tag,
+	}).Debugln("Listing rule targets")
+
+	// Ensure rule exists
+	_, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := instanceManager.ListInstancesWithTag(serviceProject, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]models.TargetInstance, 0, len(instances))
+	for _, instance := range instances {
+		targets = append(targets, newTargetInstance(instance))
+	}
+
+	return &models.RuleTargets{
+		Application:    application,
+		Project:        project,
+		ServiceProject: serviceProject,
+		CustomName:     ruleName,
+		TargetTag:      tag,
+		Instances:      targets,
+	}, nil
+}
+
+// AttachRuleTarget adds the matching rule's target tag to the given instance of the service project
+func AttachRuleTarget(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, project, serviceProject, application, ruleName, instanceName string) (*models.TargetInstance, error) {
+	return setRuleTarget(ctx, manager, instanceManager, project, serviceProject, application, ruleName, instanceName, true)
+}
+
+// DetachRuleTarget removes the matching rule's target tag from the given instance of the service project
+func DetachRuleTarget(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, project, serviceProject, application, ruleName, instanceName string) (*models.TargetInstance, error) {
+	return setRuleTarget(ctx, manager, instanceManager, project, serviceProject, application, ruleName, instanceName, false)
+}
+
+func setRuleTarget(ctx context.Context, manager models.FirewallRuleManager, instanceManager models.InstanceManager, project, serviceProject, application, ruleName, instanceName string, attach bool) (*models.TargetInstance, error) {
+	tag := fmt.Sprintf("%s-%s-%s", serviceProject, application, ruleName)
+	helpers.Logger(ctx).WithFields(logrus.Fields{
+		"project":         project,
+		"service_project": serviceProject,
+		"application":     application,
+		"target_tag":      tag,
+		"instance":        instanceName,
+		"attach":          attach,
+	}).Debugln("Setting rule target")
+
+	// Ensure rule exists
+	_, err := GetFirewallRule(ctx, manager, project, serviceProject, application, ruleName)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := instanceManager.GetInstance(serviceProject, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
+	var current []string
+	if instance.Tags != nil {
+		current = instance.Tags.Items
+	}
+
+	tags := make([]string, 0, len(current)+1)
+	found := false
+	for _, t := range current {
+		if t == tag {
+			found = true
+			if !attach {
+				continue
+			}
+		}
+		tags = append(tags, t)
+	}
+
+	target := newTargetInstance(instance)
+
+	// Nothing to do
+	if found == attach {
+		return &target, nil
+	}
+
+	if attach {
+		tags = append(tags, tag)
+	}
+
+	err = instanceManager.SetInstanceTags(serviceProject, instance, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+func newTargetInstance(instance *compute.Instance) models.TargetInstance {
+	return models.TargetInstance{
+		Name:     instance.Name,
+		Zone:     path.Base(instance.Zone),
+		Status:   instance.Status,
+		SelfLink: instance.SelfLink,
+	}
+}
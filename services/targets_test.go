@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/internal/fakes"
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestRuleTargets(t *testing.T) {
+	manager, _ := fakes.NewFirewallRuleDummyClient()
+	manager.Rules["host"] = []*compute.Firewall{&compute.Firewall{Name: "sp-web-https", TargetTags: []string{"sp-web-https"}}}
+	instanceManager := &fakes.InstanceDummyClient{Instances: map[string][]*compute.Instance{
+		"sp": []*compute.Instance{
+			&compute.Instance{Name: "web-1", Zone: "zones/europe-west1-b", Tags: &compute.Tags{Items: []string{"sp-web-https"}}},
+			&compute.Instance{Name: "web-2", Zone: "zones/europe-west1-c", Tags: &compute.Tags{Items: []string{"http-server"}}},
+		},
+	}}
+
+	assertTargets := func(expected int) {
+		t.Helper()
+		ruleTargets, err := ListRuleTargets(context.Background(), manager, instanceManager, "host", "sp", "web", "https")
+		if err != nil {
+			t.Fatalf("Unexpected error. Got %v", err)
+		}
+		if len(ruleTargets.Instances) != expected {
+			t.Errorf("Wrong targets count. Got %v want %d", ruleTargets.Instances, expected)
+		}
+	}
+
+	assertTargets(1)
+
+	target, err := AttachRuleTarget(context.Background(), manager, instanceManager, "host", "sp", "web", "https", "web-2")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	if target.Name != "web-2" || target.Zone != "europe-west1-c" {
+		t.Errorf("Unexpected target. Got %+v", target)
+	}
+	assertTargets(2)
+
+	// Other tags must be kept
+	if len(instanceManager.Instances["sp"][1].Tags.Items) != 2 {
+		t.Errorf("Existing tags should be kept. Got %v", instanceManager.Instances["sp"][1].Tags.Items)
+	}
+
+	// Attaching twice is a no-op
+	_, err = AttachRuleTarget(context.Background(), manager, instanceManager, "host", "sp", "web", "https", "web-2")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	assertTargets(2)
+
+	_, err = DetachRuleTarget(context.Background(), manager, instanceManager, "host", "sp", "web", "https", "web-1")
+	if err != nil {
+		t.Fatalf("Unexpected error. Got %v", err)
+	}
+	assertTargets(1)
+
+	// Unknown instance or rule
+	_, err = AttachRuleTarget(context.Background(), manager, instanceManager, "host", "sp", "web", "https", "web-3")
+	assertErrorCode(t, err, http.StatusNotFound)
+
+	_, err = ListRuleTargets(context.Background(), manager, instanceManager, "host", "sp", "web", "ssh")
+	assertErrorCode(t, err, http.StatusNotFound)
+}
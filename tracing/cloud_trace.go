@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CloudTraceHeader is the trace context header set by Google Cloud load balancers and Cloud Run
+// https://cloud.google.com/trace/docs/setup#force-trace
+const CloudTraceHeader = "X-Cloud-Trace-Context"
+
+// TRACE_ID/SPAN_ID;o=OPTIONS, with an hexadecimal trace ID and a decimal span ID
+var cloudTraceFormat = regexp.MustCompile(`^([0-9a-fA-F]{32})(?:/([0-9]+))?(?:;o=([0-9]+))?$`)
+
+// CloudTraceContext propagates trace context in Google Cloud Trace header. Implements propagation.TextMapPropagator
+type CloudTraceContext struct{}
+
+// Inject writes the span context of given context into given carrier
+func (CloudTraceContext) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	spanID := sc.SpanID()
+	options := 0
+	if sc.IsSampled() {
+		options = 1
+	}
+	carrier.Set(CloudTraceHeader, fmt.Sprintf("%s/%d;o=%d", sc.TraceID(), binary.BigEndian.Uint64(spanID[:]), options))
+}
+
+// Extract reads the span context of given carrier into given context, unless it already carries a valid
+// span context, as W3C traceparent header takes precedence
+func (CloudTraceContext) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	matches := cloudTraceFormat.FindStringSubmatch(carrier.Get(CloudTraceHeader))
+	if matches == nil {
+		return ctx
+	}
+
+	traceID, err := trace.TraceIDFromHex(matches[1])
+	if err != nil {
+		return ctx
+	}
+
+	// Span ID is optional, but a valid span context needs one
+	n, err := strconv.ParseUint(matches[2], 10, 64)
+	if err != nil || n == 0 {
+		return ctx
+	}
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], n)
+
+	var flags trace.TraceFlags
+	if matches[3] == "1" {
+		flags = trace.FlagsSampled
+	}
+
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// Fields returns the header written by Inject
+func (CloudTraceContext) Fields() []string {
+	return []string{CloudTraceHeader}
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCloudTraceContext(t *testing.T) {
+	tests := []struct {
+		Title   string
+		Header  string
+		TraceID string
+		SpanID  string
+		Sampled bool
+		// Header written back from the extracted span context
+		Injected string
+	}{
+		{Title: "Sampled", Header: "105445aa7843bc8bf206b12000100000/1;o=1", TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Sampled: true, Injected: "105445aa7843bc8bf206b12000100000/1;o=1"},
+		{Title: "Not sampled", Header: "105445aa7843bc8bf206b12000100000/12345678901234567890;o=0", TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "ab54a98ceb1f0ad2", Injected: "105445aa7843bc8bf206b12000100000/12345678901234567890;o=0"},
+		{Title: "Without options", Header: "105445aa7843bc8bf206b12000100000/1", TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Injected: "105445aa7843bc8bf206b12000100000/1;o=0"},
+		{Title: "Without span", Header: "105445aa7843bc8bf206b12000100000;o=1"},
+		{Title: "Invalid trace", Header: "105445aa7843bc8b/1;o=1"},
+		{Title: "Missing", Header: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Title, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(CloudTraceHeader, test.Header)
+			sc := trace.SpanContextFromContext(CloudTraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(header)))
+
+			if test.TraceID == "" {
+				if sc.IsValid() {
+					t.Errorf("Expected no span context, got %v", sc)
+				}
+				return
+			}
+
+			if sc.TraceID().String() != test.TraceID || sc.SpanID().String() != test.SpanID || sc.IsSampled() != test.Sampled || !sc.IsRemote() {
+				t.Errorf("Expected trace %s, span %s, sampled %v, got %v", test.TraceID, test.SpanID, test.Sampled, sc)
+			}
+
+			injected := http.Header{}
+			CloudTraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), propagation.HeaderCarrier(injected))
+			if injected.Get(CloudTraceHeader) != test.Injected {
+				t.Errorf("Expected header %s, got %s", test.Injected, injected.Get(CloudTraceHeader))
+			}
+		})
+	}
+}
+
+func TestCloudTraceContextPrecedence(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(CloudTraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, CloudTraceContext{})
+	sc := trace.SpanContextFromContext(propagator.Extract(context.Background(), propagation.HeaderCarrier(header)))
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected traceparent to take precedence, got trace %s", sc.TraceID())
+	}
+}
@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/adeo/iwc-gcp-firewall-api/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "gcp-firewall-api"
+	tracerName  = "github.com/adeo/iwc-gcp-firewall-api"
+)
+
+// Init registers the global tracer provider exporting spans as configured, and the propagators
+// reading and writing both W3C traceparent and Google Cloud Trace headers.
+// Returns a function flushing remaining spans on shutdown
+func Init(ctx context.Context, c config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+		CloudTraceContext{},
+	))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case "", config.TraceExporterNone:
+		// Keep the default no-op tracer provider. Incoming trace context is still propagated
+		return func(context.Context) error { return nil }, nil
+	case config.TraceExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint(), stdouttrace.WithWriter(os.Stdout))
+	case config.TraceExporterOTLP:
+		var opts []otlptracehttp.Option
+		opts, err = otlpOptions(c)
+		if err == nil {
+			spanExporter, err = otlptracehttp.New(ctx, opts...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %s", c.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Exporter options sending spans to the configured endpoint URL, such as http://collector:4318.
+// Without endpoint, the exporter defaults to https://localhost:4318
+func otlpOptions(c config.Tracing) ([]otlptracehttp.Option, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithHeaders(c.OTLPHeaders)}
+	if c.OTLPEndpoint == "" {
+		return opts, nil
+	}
+
+	endpoint, err := url.Parse(c.OTLPEndpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %s. Expected an URL such as http://collector:4318", c.OTLPEndpoint)
+	}
+
+	opts = append(opts, otlptracehttp.WithEndpoint(endpoint.Host))
+	if endpoint.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if endpoint.Path != "" && endpoint.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(endpoint.Path))
+	}
+	return opts, nil
+}
+
+// Start creates a span named after the given operation, child of the span of the given context if any
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// End records the given error on the given span, if any, then ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/compute/v1"
+)
+
+// FirewallRuleManager traces calls of a models.FirewallRuleManager as children of the span of a context,
+// usually the one of the served request. Implements models.FirewallRuleManager
+type FirewallRuleManager struct {
+	ctx  context.Context
+	next models.FirewallRuleManager
+}
+
+// NewFirewallRuleManager FirewallRuleManager constructor
+func NewFirewallRuleManager(ctx context.Context, next models.FirewallRuleManager) *FirewallRuleManager {
+	return &FirewallRuleManager{ctx: ctx, next: next}
+}
+
+// Start a client span for the given method of the given interface
+func startUpstream(ctx context.Context, iface, method string, attributes ...attribute.KeyValue) trace.Span {
+	_, span := Start(ctx, iface+"."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return span
+}
+
+// ListFirewallRule returns given project's firewall rule
+func (m *FirewallRuleManager) ListFirewallRule(project string) ([]*compute.Firewall, error) {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "ListFirewallRule", attribute.String("gcp.project", project))
+	rules, err := m.next.ListFirewallRule(project)
+	span.SetAttributes(attribute.Int("gcp.firewall.rules", len(rules)))
+	End(span, err)
+	return rules, err
+}
+
+// GetFirewallRule returns firewall rule matching given project and name
+func (m *FirewallRuleManager) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "GetFirewallRule", attribute.String("gcp.project", project), attribute.String("gcp.firewall.rule", name))
+	rule, err := m.next.GetFirewallRule(project, name)
+	End(span, err)
+	return rule, err
+}
+
+// CreateFirewallRule create given firewall rule on given project
+func (m *FirewallRuleManager) CreateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "CreateFirewallRule", attribute.String("gcp.project", project), attribute.String("gcp.firewall.rule", rule.Name))
+	rule, err := m.next.CreateFirewallRule(project, rule)
+	End(span, err)
+	return rule, err
+}
+
+// UpdateFirewallRule replace the firewall rule matching given rule's name on given project
+func (m *FirewallRuleManager) UpdateFirewallRule(project string, rule *compute.Firewall) (*compute.Firewall, error) {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "UpdateFirewallRule", attribute.String("gcp.project", project), attribute.String("gcp.firewall.rule", rule.Name))
+	rule, err := m.next.UpdateFirewallRule(project, rule)
+	End(span, err)
+	return rule, err
+}
+
+// DeleteFirewallRule delete firewall rule matching given project and name
+func (m *FirewallRuleManager) DeleteFirewallRule(project, name string) error {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "DeleteFirewallRule", attribute.String("gcp.project", project), attribute.String("gcp.firewall.rule", name))
+	err := m.next.DeleteFirewallRule(project, name)
+	End(span, err)
+	return err
+}
+
+// GetFirewallQuota returns the firewall rules quota of given project
+func (m *FirewallRuleManager) GetFirewallQuota(project string) (*compute.Quota, error) {
+	span := startUpstream(m.ctx, "FirewallRuleManager", "GetFirewallQuota", attribute.String("gcp.project", project))
+	quota, err := m.next.GetFirewallQuota(project)
+	End(span, err)
+	return quota, err
+}
+
+// GoogleClient traces calls of a models.GoogleClientInterface as children of the span of a context,
+// usually the one of the served request. Implements models.GoogleClientInterface
+type GoogleClient struct {
+	ctx  context.Context
+	next models.GoogleClientInterface
+}
+
+// NewGoogleClient GoogleClient constructor
+func NewGoogleClient(ctx context.Context, next models.GoogleClientInterface) *GoogleClient {
+	return &GoogleClient{ctx: ctx, next: next}
+}
+
+// IsProjectOwner return if a given user is owner of the given project
+func (c *GoogleClient) IsProjectOwner(user string, projectID string) error {
+	span := startUpstream(c.ctx, "GoogleClientInterface", "IsProjectOwner", attribute.String("gcp.project", projectID))
+	err := c.next.IsProjectOwner(user, projectID)
+	End(span, err)
+	return err
+}
+
+// IsAServiceProjectOf return if projectA is a service project of projectB
+func (c *GoogleClient) IsAServiceProjectOf(projectA, projectB string) error {
+	span := startUpstream(c.ctx, "GoogleClientInterface", "IsAServiceProjectOf", attribute.String("gcp.service_project", projectA), attribute.String("gcp.project", projectB))
+	err := c.next.IsAServiceProjectOf(projectA, projectB)
+	End(span, err)
+	return err
+}
+
+// ListServiceProjects returns service projects of given host project
+func (c *GoogleClient) ListServiceProjects(project string) ([]string, error) {
+	span := startUpstream(c.ctx, "GoogleClientInterface", "ListServiceProjects", attribute.String("gcp.project", project))
+	serviceProjects, err := c.next.ListServiceProjects(project)
+	End(span, err)
+	return serviceProjects, err
+}
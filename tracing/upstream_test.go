@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adeo/iwc-gcp-firewall-api/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// Firewall rule manager failing to delete rules
+type fakeFirewallRuleManager struct {
+	models.FirewallRuleManager
+}
+
+func (f *fakeFirewallRuleManager) GetFirewallRule(project, name string) (*compute.Firewall, error) {
+	return &compute.Firewall{Name: name}, nil
+}
+
+func (f *fakeFirewallRuleManager) DeleteFirewallRule(project, name string) error {
+	return errors.New("Rule not found")
+}
+
+func TestFirewallRuleManager(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := Start(context.Background(), "request")
+	manager := NewFirewallRuleManager(ctx, &fakeFirewallRuleManager{})
+	manager.GetFirewallRule("host", "sp-web-https")
+	manager.DeleteFirewallRule("host", "sp-web-https")
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+
+	for i, name := range []string{"FirewallRuleManager.GetFirewallRule", "FirewallRuleManager.DeleteFirewallRule"} {
+		if spans[i].Name() != name {
+			t.Errorf("Expected span %s, got %s", name, spans[i].Name())
+		}
+		if spans[i].Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected span %s to be a child of the request span", name)
+		}
+	}
+
+	if spans[0].Status().Code == codes.Error || spans[1].Status().Code != codes.Error {
+		t.Errorf("Expected only the failed call to have an error status, got %v and %v", spans[0].Status(), spans[1].Status())
+	}
+}